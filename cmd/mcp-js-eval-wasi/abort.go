@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// errorCodeAborted is the ErrorDto code of an evaluation canceled by POST /abort.
+const errorCodeAborted = -13
+
+var errAborted = errors.New("aborted by operator")
+
+// abortRegistry tracks the contexts of in-flight evaluations so that an
+// operator can cancel all of them at once.
+type abortRegistry struct {
+	mu      sync.Mutex
+	next    uint64
+	cancels map[uint64]context.CancelCauseFunc
+}
+
+// track returns a context canceled by abortAll, and a function to stop
+// tracking it once the evaluation is done.
+func (a *abortRegistry) track(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	a.mu.Lock()
+	if a.cancels == nil {
+		a.cancels = map[uint64]context.CancelCauseFunc{}
+	}
+	id := a.next
+	a.next++
+	a.cancels[id] = cancel
+	a.mu.Unlock()
+	return ctx, func() {
+		a.mu.Lock()
+		delete(a.cancels, id)
+		a.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// abortAll cancels every tracked evaluation and returns how many there were.
+func (a *abortRegistry) abortAll() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, cancel := range a.cancels {
+		cancel(errAborted)
+	}
+	aborted := len(a.cancels)
+	clear(a.cancels)
+	return aborted
+}
+
+// abort serves POST /abort, answering with the number of evaluations canceled.
+func (a *abortRegistry) abort(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	aborted := a.abortAll()
+	log.Printf("Aborted %d in-flight evaluations on operator request", aborted)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]int{"aborted": aborted}); err != nil {
+		log.Printf("failed to write the abort response: %v", err)
+	}
+}
+
+// abortedResult replaces the error of an evaluation ended by abortAll with
+// one naming it.
+func abortedResult(ctx context.Context, result jseval.JsEvalResultDto) jseval.JsEvalResultDto {
+	if result.Error == nil || !errors.Is(context.Cause(ctx), errAborted) {
+		return result
+	}
+	result.Error = &jseval.ErrorDto{Code: errorCodeAborted, Message: errAborted.Error(), Terminated: true}
+	return result
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+func TestAbortRegistry(t *testing.T) {
+	aborts := &abortRegistry{}
+	running := make([]context.Context, 3)
+	for i := range running {
+		ctx, untrack := aborts.track(context.Background())
+		defer untrack()
+		running[i] = ctx
+	}
+	done, untrackDone := aborts.track(context.Background())
+	untrackDone()
+
+	mux := http.NewServeMux()
+	mux.Handle("/abort", requireToken("secret", http.HandlerFunc(aborts.abort)))
+	do := func(method, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/abort", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodPost, ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected /abort without a token to be refused, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "secret"); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected GET /abort to be refused, got %d", rec.Code)
+	}
+	for _, ctx := range running {
+		if ctx.Err() != nil {
+			t.Fatal("expected refused requests to abort nothing")
+		}
+	}
+
+	rec := do(http.MethodPost, "secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected /abort to succeed, got %d", rec.Code)
+	}
+	var body struct{ Aborted int }
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Aborted != len(running) {
+		t.Errorf("expected %d aborted evaluations, got %s (%v)", len(running), rec.Body, err)
+	}
+	for _, ctx := range running {
+		if !errors.Is(context.Cause(ctx), errAborted) {
+			t.Errorf("expected the evaluation to be aborted, got cause %v", context.Cause(ctx))
+		}
+		result := abortedResult(ctx, jseval.JsEvalResultDto{Error: &jseval.ErrorDto{Code: -1, Message: "context canceled"}})
+		if result.Error.Code != errorCodeAborted || !result.Error.Terminated {
+			t.Errorf("unexpected result of an aborted evaluation: %+v", result.Error)
+		}
+	}
+	if errors.Is(context.Cause(done), errAborted) {
+		t.Error("expected a finished evaluation not to be aborted")
+	}
+	if got := aborts.abortAll(); got != 0 {
+		t.Errorf("expected nothing left to abort, aborted %d", got)
+	}
+}
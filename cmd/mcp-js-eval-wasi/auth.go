@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// authTokenEnv is the environment variable holding the auth token when
+// neither -auth-token nor -auth-token-file is given.
+const authTokenEnv = "JSEVAL_AUTH_TOKEN"
+
+// resolveAuthToken picks the auth token from, in this order, the file,
+// the flag and the environment value; giving both the file and the flag is
+// an error. The file is read once, its surrounding whitespace trimmed, and
+// must not be empty then, so a mounted secret missing its content does not
+// silently disable the admin endpoints.
+func resolveAuthToken(flagToken, file, envToken string) (string, error) {
+	if file == "" {
+		if flagToken != "" {
+			return flagToken, nil
+		}
+		return strings.TrimSpace(envToken), nil
+	}
+	if flagToken != "" {
+		return "", errors.New("-auth-token and -auth-token-file are mutually exclusive")
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read -auth-token-file: %w", err)
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("-auth-token-file %s is empty", file)
+	}
+	return token, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveAuthToken(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "token")
+	if err := os.WriteFile(file, []byte("  from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		flagToken, file, envToken string
+		want                      string
+		wantErr                   bool
+	}{
+		{want: ""},
+		{envToken: "from-env\n", want: "from-env"},
+		{flagToken: "from-flag", envToken: "from-env", want: "from-flag"},
+		{file: file, envToken: "from-env", want: "from-file"},
+		{flagToken: "from-flag", file: file, wantErr: true},
+		{file: empty, wantErr: true},
+		{file: filepath.Join(dir, "missing"), wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := resolveAuthToken(tt.flagToken, tt.file, tt.envToken)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("resolveAuthToken(%q, %q, %q) = %q, %v, want %q (error: %v)", tt.flagToken, tt.file, tt.envToken, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// errorCodeEngineUnavailable is the ErrorDto code of an evaluation refused
+// while the circuit breaker is open.
+const errorCodeEngineUnavailable = -17
+
+// Circuit breaker states, also the values of the breaker state metric.
+const (
+	breakerClosed   = 0
+	breakerOpen     = 1
+	breakerHalfOpen = 2
+)
+
+var breakerStateNames = [...]string{"closed", "open", "half-open"}
+
+// circuitBreaker stops sending evaluations to an engine which keeps failing.
+// After failures consecutive host failures, the first of them no longer ago
+// than window, it opens and refuses evaluations for cooldown. Then a single
+// evaluation probes the engine: its success closes the breaker, a failure
+// opens it for another cooldown.
+//
+// Only failures of the engine itself, jseval.ErrorCodeEngineFailure, count:
+// exceptions, timeouts, output which is no JSON and other errors of the code
+// say nothing about the health of the engine. An evaluation which panics
+// counts as a failure.
+type circuitBreaker struct {
+	failures int
+	window   time.Duration
+	cooldown time.Duration
+	now      func() time.Time
+	onState  func(state int)
+
+	mu          sync.Mutex
+	state       int
+	streak      int
+	streakSince time.Time
+	openedAt    time.Time
+}
+
+func newCircuitBreaker(failures int, window, cooldown time.Duration, onState func(int)) *circuitBreaker {
+	return &circuitBreaker{failures: failures, window: window, cooldown: cooldown, now: time.Now, onState: onState}
+}
+
+// evaluate runs evaluate unless the breaker is open, recording its outcome.
+// A nil *circuitBreaker always runs it.
+func (b *circuitBreaker) evaluate(ctx context.Context, code string, evaluate jseval.Evaluator) jseval.JsEvalResultDto {
+	if b == nil {
+		return evaluate(ctx, code)
+	}
+	probe, retryAt, ok := b.allow()
+	if !ok {
+		return jseval.JsEvalResultDto{Error: &jseval.ErrorDto{
+			Code:    errorCodeEngineUnavailable,
+			Message: fmt.Sprintf("engine unavailable after repeated failures, retry after %s", retryAt.UTC().Format(time.RFC3339)),
+			Details: map[string]any{"retry_at": retryAt.UTC().Format(time.RFC3339)},
+		}}
+	}
+	failed := true
+	defer func() { b.record(probe, failed) }()
+	result := evaluate(ctx, code)
+	failed = result.Error != nil && result.Error.Code == jseval.ErrorCodeEngineFailure
+	return result
+}
+
+// allow reports whether an evaluation may run and whether it is the probe,
+// or else when the next probe is due.
+func (b *circuitBreaker) allow() (probe bool, retryAt time.Time, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerClosed:
+		return false, time.Time{}, true
+	case breakerOpen:
+		retryAt = b.openedAt.Add(b.cooldown)
+		if b.now().Before(retryAt) {
+			return false, retryAt, false
+		}
+		b.setState(breakerHalfOpen)
+		return true, time.Time{}, true
+	default:
+		// Half-open: only the probe runs, the others wait for its outcome.
+		return false, b.now(), false
+	}
+}
+
+func (b *circuitBreaker) record(probe, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if probe {
+		if failed {
+			b.open(now)
+			return
+		}
+		b.streak = 0
+		b.setState(breakerClosed)
+		return
+	}
+	if b.state != breakerClosed {
+		return
+	}
+	if !failed {
+		b.streak = 0
+		return
+	}
+	if b.streak == 0 || now.Sub(b.streakSince) > b.window {
+		b.streak, b.streakSince = 0, now
+	}
+	b.streak++
+	if b.streak >= b.failures {
+		b.open(now)
+	}
+}
+
+func (b *circuitBreaker) open(now time.Time) {
+	b.openedAt = now
+	b.streak = 0
+	b.setState(breakerOpen)
+}
+
+func (b *circuitBreaker) setState(state int) {
+	if b.state == state {
+		return
+	}
+	log.Printf("Circuit breaker %s -> %s", breakerStateNames[b.state], breakerStateNames[state])
+	b.state = state
+	if b.onState != nil {
+		b.onState(state)
+	}
+}
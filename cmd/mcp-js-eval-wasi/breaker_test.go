@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var states []int
+	breaker := newCircuitBreaker(3, time.Minute, 30*time.Second, func(state int) { states = append(states, state) })
+	breaker.now = func() time.Time { return now }
+
+	var calls int
+	failing := func(context.Context, string) jseval.JsEvalResultDto {
+		calls++
+		return jseval.JsEvalResultDto{Error: &jseval.ErrorDto{Code: jseval.ErrorCodeEngineFailure, Message: "WASM execution failed"}}
+	}
+	throwing := func(context.Context, string) jseval.JsEvalResultDto {
+		calls++
+		return jseval.JsEvalResultDto{Error: &jseval.ErrorDto{Code: 1, Message: "ReferenceError"}}
+	}
+	notJSON := func(context.Context, string) jseval.JsEvalResultDto {
+		calls++
+		return jseval.JsEvalResultDto{Error: &jseval.ErrorDto{Code: -1, Message: "Failed to parse successful WASM output as JSON"}}
+	}
+	healthy := func(context.Context, string) jseval.JsEvalResultDto {
+		calls++
+		return jseval.JsEvalResultDto{Result: 2.0}
+	}
+
+	for range 5 {
+		breaker.evaluate(ctx, "1", throwing)
+		breaker.evaluate(ctx, "1", notJSON)
+	}
+	breaker.evaluate(ctx, "1", failing)
+	breaker.evaluate(ctx, "1", failing)
+	if len(states) != 0 {
+		t.Fatalf("expected errors of the code and two failures to keep the breaker closed, got %v", states)
+	}
+
+	// A failure outside the window starts a new streak.
+	now = now.Add(2 * time.Minute)
+	breaker.evaluate(ctx, "1", failing)
+	breaker.evaluate(ctx, "1", failing)
+	if len(states) != 0 {
+		t.Fatalf("expected a streak spanning more than the window to keep the breaker closed, got %v", states)
+	}
+	breaker.evaluate(ctx, "1", failing)
+	if len(states) != 1 || states[0] != breakerOpen {
+		t.Fatalf("expected the third failure to open the breaker, got %v", states)
+	}
+
+	calls = 0
+	result := breaker.evaluate(ctx, "1", healthy)
+	if calls != 0 || result.Error == nil || result.Error.Code != errorCodeEngineUnavailable {
+		t.Fatalf("expected the open breaker to refuse, got %+v after %d calls", result.Error, calls)
+	}
+
+	// After the cooldown a failing probe opens it again.
+	now = now.Add(30 * time.Second)
+	breaker.evaluate(ctx, "1", failing)
+	if calls != 1 || states[len(states)-1] != breakerOpen {
+		t.Fatalf("expected a failed probe to reopen the breaker, got %v", states)
+	}
+	if result := breaker.evaluate(ctx, "1", healthy); result.Error == nil {
+		t.Fatal("expected the reopened breaker to refuse")
+	}
+
+	now = now.Add(30 * time.Second)
+	if result := breaker.evaluate(ctx, "1", healthy); result.Error != nil {
+		t.Fatalf("expected the probe to run, got %+v", result.Error)
+	}
+	want := []int{breakerOpen, breakerHalfOpen, breakerOpen, breakerHalfOpen, breakerClosed}
+	if len(states) != len(want) {
+		t.Fatalf("unexpected state changes %v, want %v", states, want)
+	}
+	for i := range want {
+		if states[i] != want[i] {
+			t.Fatalf("unexpected state changes %v, want %v", states, want)
+		}
+	}
+
+	var disabled *circuitBreaker
+	if result := disabled.evaluate(ctx, "1", failing); result.Error.Code != jseval.ErrorCodeEngineFailure {
+		t.Errorf("expected a nil breaker to pass evaluations through, got %+v", result.Error)
+	}
+}
+
+func TestCircuitBreakerProbePanics(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	breaker := newCircuitBreaker(1, time.Minute, 30*time.Second, nil)
+	breaker.now = func() time.Time { return now }
+
+	breaker.evaluate(ctx, "1", func(context.Context, string) jseval.JsEvalResultDto {
+		return jseval.JsEvalResultDto{Error: &jseval.ErrorDto{Code: jseval.ErrorCodeEngineFailure}}
+	})
+	now = now.Add(30 * time.Second)
+	func() {
+		defer func() { _ = recover() }()
+		breaker.evaluate(ctx, "1", func(context.Context, string) jseval.JsEvalResultDto {
+			panic("boom")
+		})
+	}()
+	if breaker.state != breakerOpen {
+		t.Fatalf("expected the panicking probe to reopen the breaker, got state %d", breaker.state)
+	}
+
+	now = now.Add(30 * time.Second)
+	if result := breaker.evaluate(ctx, "1", func(context.Context, string) jseval.JsEvalResultDto {
+		return jseval.JsEvalResultDto{Result: 2.0}
+	}); result.Error != nil || breaker.state != breakerClosed {
+		t.Errorf("expected the next probe to close the breaker, got %+v in state %d", result.Error, breaker.state)
+	}
+}
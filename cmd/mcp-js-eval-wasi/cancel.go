@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+type requestCtxKey struct{}
+
+// withRequestContext makes the HTTP request context reachable from tool
+// handlers. The SDK runs handlers on a context which keeps the values of the
+// request context but not its cancellation, so a client going away would
+// otherwise not stop a running evaluation.
+func withRequestContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), requestCtxKey{}, r.Context())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// boundToRequest returns a context which is also canceled, with the same
+// cause, when the HTTP request carried by ctx ends. A deadline of the request
+// applies as well, so a later WithTimeout yields the earlier of the two.
+func boundToRequest(ctx context.Context) (context.Context, context.CancelFunc) {
+	reqCtx, ok := ctx.Value(requestCtxKey{}).(context.Context)
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+
+	cancelDeadline := context.CancelFunc(func() {})
+	if deadline, ok := reqCtx.Deadline(); ok {
+		ctx, cancelDeadline = context.WithDeadline(ctx, deadline)
+	}
+
+	bound, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(reqCtx, func() { cancel(context.Cause(reqCtx)) })
+	return bound, func() {
+		stop()
+		cancel(context.Canceled)
+		cancelDeadline()
+	}
+}
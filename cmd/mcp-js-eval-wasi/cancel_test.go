@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBoundToRequest(t *testing.T) {
+	started := make(chan struct{})
+	stopped := make(chan error, 1)
+	handler := withRequestContext(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Like the SDK, run on a context with the values but not the cancellation of the request.
+		ctx, cancel := boundToRequest(context.WithoutCancel(r.Context()))
+		defer cancel()
+
+		evalCtx, cancelTimeout := context.WithTimeout(ctx, 10*time.Second)
+		defer cancelTimeout()
+
+		close(started)
+		select {
+		case <-evalCtx.Done():
+			stopped <- evalCtx.Err()
+		case <-time.After(5 * time.Second):
+			stopped <- errors.New("evaluation context outlived the request")
+		}
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	reqCtx, cancelReq := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, server.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create the request: %v", err)
+	}
+	go func() {
+		<-started
+		cancelReq()
+	}()
+	if _, err := http.DefaultClient.Do(req); err == nil {
+		t.Fatal("expected the canceled request to fail")
+	}
+
+	if err := <-stopped; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the evaluation context to be canceled, got: %v", err)
+	}
+}
+
+func TestBoundToRequestDeadline(t *testing.T) {
+	reqCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ctx := context.WithValue(context.WithoutCancel(reqCtx), requestCtxKey{}, reqCtx)
+
+	bound, stop := boundToRequest(ctx)
+	defer stop()
+	evalCtx, cancelTimeout := context.WithTimeout(bound, time.Hour)
+	defer cancelTimeout()
+
+	want, _ := reqCtx.Deadline()
+	if got, ok := evalCtx.Deadline(); !ok || !got.Equal(want) {
+		t.Errorf("expected the earlier request deadline %v, got %v (set: %v)", want, got, ok)
+	}
+}
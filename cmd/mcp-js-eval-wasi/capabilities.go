@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+const (
+	serverVersion   = "v0.1.0"
+	transportName   = "streamable-http (stateless)"
+	capabilitiesURI = "js-eval://capabilities"
+)
+
+// serverCapabilities tells clients which optional behavior this server has,
+// derived from the flags it runs with, so that they need not probe for it.
+// MCP has no place for such details in the initialize handshake beyond the
+// experimental capabilities, which the SDK does not expose, so it is a
+// resource.
+type serverCapabilities struct {
+	Version   string `json:"version"`
+	Transport string `json:"transport"`
+
+	// Sessions is false: every request stands alone and no state outlives
+	// an evaluation.
+	Sessions bool `json:"sessions"`
+
+	// HostFetch is false: the engine has no network access.
+	HostFetch bool `json:"hostFetch"`
+
+	Languages      []string `json:"languages"`
+	ModuleTypes    []string `json:"moduleTypes"`
+	EngineProtocol int      `json:"engineProtocol"`
+
+	// Streaming names the ways output lines are streamed while code runs.
+	Streaming []string `json:"streaming"`
+
+	Limits capabilityLimits `json:"limits"`
+
+	// Features are the optional features enabled by flags, as in the startup summary.
+	Features []string `json:"features"`
+}
+
+type capabilityLimits struct {
+	MemoryMiB     uint `json:"memoryMiB"`
+	TimeoutMs     uint `json:"timeoutMs"`
+	CPUTimeoutMs  uint `json:"cpuTimeoutMs,omitempty"`
+	MaxConcurrent int  `json:"maxConcurrent,omitempty"`
+}
+
+func newServerCapabilities(moduleTypes []string) serverCapabilities {
+	languages := []string{jseval.LanguageJavaScript}
+	if *transpilerPath != "" {
+		languages = append(languages, jseval.LanguageTypeScript)
+	}
+	streaming := []string{}
+	if *streamLogs {
+		streaming = append(streaming, "progress")
+	}
+	if *evalSSE {
+		streaming = append(streaming, "sse")
+	}
+	return serverCapabilities{
+		Version:        serverVersion,
+		Transport:      transportName,
+		Languages:      languages,
+		ModuleTypes:    moduleTypes,
+		EngineProtocol: *engineProtocol,
+		Streaming:      streaming,
+		Limits: capabilityLimits{
+			MemoryMiB:     *mem,
+			TimeoutMs:     *timeout,
+			CPUTimeoutMs:  *cpuTimeout,
+			MaxConcurrent: *maxConcurrent,
+		},
+		Features: enabledFeatures(),
+	}
+}
+
+// registerCapabilities adds the capabilities resource.
+func registerCapabilities(server *mcp.Server, capabilities serverCapabilities) {
+	server.AddResource(&mcp.Resource{
+		URI:         capabilitiesURI,
+		Name:        "capabilities",
+		Title:       "Server Capabilities",
+		Description: "The optional features this server runs with: languages, module types, streaming, limits and enabled flags.",
+		MIMEType:    "application/json",
+	}, func(_ context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		encoded, err := json.MarshalIndent(capabilities, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode the capabilities: %w", err)
+		}
+		return &mcp.ReadResourceResult{
+			Contents: []*mcp.ResourceContents{{
+				URI:      req.Params.URI,
+				MIMEType: "application/json",
+				Text:     string(encoded),
+			}},
+		}, nil
+	})
+}
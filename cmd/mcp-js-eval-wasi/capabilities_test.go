@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+func TestCapabilitiesResource(t *testing.T) {
+	defer func(sse, logs bool, transpiler string) {
+		*evalSSE, *streamLogs, *transpilerPath = sse, logs, transpiler
+	}(*evalSSE, *streamLogs, *transpilerPath)
+	*evalSSE, *streamLogs, *transpilerPath = true, false, "ts.wasm"
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: serverVersion}, nil)
+	registerCapabilities(server, newServerCapabilities([]string{jseval.ModuleTypeScript}))
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	ctx := context.Background()
+	serverSession, err := server.Connect(ctx, serverTransport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serverSession.Close()
+	client := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "v0.0.1"}, nil)
+	session, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	read, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: capabilitiesURI})
+	if err != nil {
+		t.Fatalf("failed to read the capabilities: %v", err)
+	}
+	var got serverCapabilities
+	if err := json.Unmarshal([]byte(read.Contents[0].Text), &got); err != nil {
+		t.Fatalf("invalid capabilities: %v", err)
+	}
+	if got.Version != serverVersion || got.Sessions || got.HostFetch {
+		t.Errorf("unexpected capabilities: %+v", got)
+	}
+	if !slices.Equal(got.Languages, []string{"js", "ts"}) {
+		t.Errorf("languages = %v, want js and ts", got.Languages)
+	}
+	if !slices.Equal(got.Streaming, []string{"sse"}) {
+		t.Errorf("streaming = %v, want sse only", got.Streaming)
+	}
+	if !slices.Contains(got.Features, "eval-sse") {
+		t.Errorf("features %v lack eval-sse", got.Features)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// compileLimiter bounds how many modules compile at once. Compiling a large
+// engine takes a multiple of its size in memory, so workers, the transpiler
+// and reloads compiling together can exhaust a small host. A nil
+// *compileLimiter does not limit.
+type compileLimiter struct {
+	slots chan struct{}
+}
+
+// compiles is the limiter of all module compilations, set from -max-parallel-compiles.
+var compiles *compileLimiter
+
+// newCompileLimiter allows max compilations at once, or any number for 0.
+func newCompileLimiter(max int) *compileLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &compileLimiter{slots: make(chan struct{}, max)}
+}
+
+// run calls compile once a slot is free, logging when the compilation of
+// name waits, starts and ends so that the order can be followed.
+func (c *compileLimiter) run(name string, compile func() error) error {
+	if c != nil {
+		select {
+		case c.slots <- struct{}{}:
+		default:
+			log.Printf("Compile of %s queued: %d compilations running", name, cap(c.slots))
+			c.slots <- struct{}{}
+		}
+		defer func() { <-c.slots }()
+	}
+	log.Printf("Compile of %s started", name)
+	startedAt := time.Now()
+	err := compile()
+	log.Printf("Compile of %s finished in %v", name, time.Since(startedAt))
+	return err
+}
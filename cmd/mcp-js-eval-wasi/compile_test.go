@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCompileLimiter(t *testing.T) {
+	limiter := newCompileLimiter(2)
+	var running, peak atomic.Int32
+	var compiling sync.WaitGroup
+	for range 6 {
+		compiling.Go(func() {
+			_ = limiter.run("module", func() error {
+				n := running.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				running.Add(-1)
+				return nil
+			})
+		})
+	}
+	compiling.Wait()
+	if got := peak.Load(); got != 2 {
+		t.Errorf("expected at most 2 compilations at once, peaked at %d", got)
+	}
+
+	failed := errors.New("invalid module")
+	if err := limiter.run("broken", func() error { return failed }); !errors.Is(err, failed) {
+		t.Errorf("expected the compile error, got %v", err)
+	}
+	if err := newCompileLimiter(0).run("unlimited", func() error { return nil }); err != nil {
+		t.Errorf("unexpected error without a limit: %v", err)
+	}
+}
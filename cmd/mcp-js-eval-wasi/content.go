@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// encodeJSON marshals v like json.Marshal, or like json.MarshalIndent with
+// indent, except that <, > and & are kept as they are with -unescaped-html
+// instead of being escaped as \u003c, \u003e and \u0026.
+func encodeJSON(v any, indent bool) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(!*unescapedHTML)
+	if indent {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// withResultContent puts the result, as JSON text, in front of the content
+// blocks of res for clients which display text better than structured
+// content. Without other blocks it is only added for -pretty-text and
+// -unescaped-html, as the SDK fills in the compact, escaped JSON itself. The
+// structured result stays authoritative.
+func withResultContent(res *mcp.CallToolResult, result any) *mcp.CallToolResult {
+	if res == nil && !*prettyText && !*unescapedHTML {
+		return nil
+	}
+
+	encoded, err := encodeJSON(result, *prettyText)
+	if err != nil {
+		log.Printf("failed to render the result as text: %v", err)
+		return res
+	}
+
+	if res == nil {
+		res = &mcp.CallToolResult{}
+	}
+	res.Content = append([]mcp.Content{&mcp.TextContent{Text: string(encoded)}}, res.Content...)
+	return res
+}
+
+// logCollector gathers the stderr lines of an evaluation for a content block.
+// Stdout is left out as it is the result.
+type logCollector struct {
+	lines []string
+}
+
+func (c *logCollector) sink(stream, line string) {
+	if stream == "stderr" {
+		c.lines = append(c.lines, line)
+	}
+}
+
+// detailBlocks returns the content blocks shown next to the result with
+// -content-blocks: the captured logs, if any, and the timing and limits.
+func detailBlocks(logs *logCollector, elapsed time.Duration, result jseval.JsEvalResultDto) []mcp.Content {
+	var blocks []mcp.Content
+	if len(logs.lines) > 0 {
+		blocks = append(blocks, &mcp.TextContent{Text: "logs:\n" + strings.Join(logs.lines, "\n")})
+	}
+
+	timing := fmt.Sprintf("took %d ms, %d bytes of output (timeout %d ms, memory %d MiB",
+		elapsed.Milliseconds(), result.OutputBytes, *timeout, *mem)
+	if *cpuTimeout > 0 {
+		timing += fmt.Sprintf(", cpu timeout %d ms", *cpuTimeout)
+	}
+	return append(blocks, &mcp.TextContent{Text: timing + ")"})
+}
+
+// errorBlock returns the content block shown for a failed evaluation with
+// -error-text: the error code and message, followed by the stderr lines the
+// engine wrote unless the message already carries them, as it does for an
+// engine exiting with a failure code.
+func errorBlock(logs *logCollector, result jseval.JsEvalResultDto) mcp.Content {
+	message := strings.TrimRight(result.Error.Message, "\n")
+	text := fmt.Sprintf("error %d: %s", result.Error.Code, message)
+	if stderr := strings.Join(logs.lines, "\n"); stderr != "" && !strings.Contains(message, stderr) {
+		text += "\nstderr:\n" + stderr
+	}
+	return &mcp.TextContent{Text: text}
+}
+
+// transcript renders the evaluation like a notebook cell for -transcript:
+// the code lines prefixed with "> ", the logged lines as they are, then the
+// value after "=> " as compact JSON or the error after "!! ".
+func transcript(code string, logs []string, result jseval.JsEvalResultDto) string {
+	var b strings.Builder
+	for line := range strings.Lines(strings.TrimRight(code, "\n")) {
+		b.WriteString("> " + strings.TrimSuffix(line, "\n") + "\n")
+	}
+	for _, line := range logs {
+		b.WriteString(line + "\n")
+	}
+
+	if result.Error != nil {
+		fmt.Fprintf(&b, "!! error %d: %s", result.Error.Code, strings.TrimRight(result.Error.Message, "\n"))
+		return b.String()
+	}
+	value, err := encodeJSON(result.Result, false)
+	if err != nil {
+		value = []byte(fmt.Sprintf("%v", result.Result))
+	}
+	b.WriteString("=> " + string(value))
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+func TestContentBlocks(t *testing.T) {
+	logs := &logCollector{}
+	logs.sink("stdout", "42")
+	logs.sink("stderr", "warning: slow")
+
+	result := jseval.JsEvalResultDto{Result: 42.0, OutputBytes: 2}
+	res := withResultContent(&mcp.CallToolResult{Content: detailBlocks(logs, 5*time.Millisecond, result)}, result)
+
+	var texts []string
+	for _, content := range res.Content {
+		text, ok := content.(*mcp.TextContent)
+		if !ok {
+			t.Fatalf("unexpected content type %T", content)
+		}
+		texts = append(texts, text.Text)
+	}
+	if len(texts) != 3 {
+		t.Fatalf("expected result, logs and timing blocks, got: %q", texts)
+	}
+	if texts[0] != `{"result":42,"outputBytes":2}` {
+		t.Errorf("unexpected result block: %s", texts[0])
+	}
+	if texts[1] != "logs:\nwarning: slow" {
+		t.Errorf("unexpected logs block: %q", texts[1])
+	}
+	if !strings.HasPrefix(texts[2], "took 5 ms, 2 bytes of output") {
+		t.Errorf("unexpected timing block: %q", texts[2])
+	}
+}
+
+func TestWithResultContentDefault(t *testing.T) {
+	if res := withResultContent(nil, jseval.JsEvalResultDto{}); res != nil {
+		t.Errorf("expected the SDK to fill in the content, got: %+v", res)
+	}
+}
+
+func TestUnescapedHTML(t *testing.T) {
+	result := map[string]any{"result": "<div>a & b</div>"}
+
+	if got, _ := encodeJSON(result, false); string(got) != `{"result":"\u003cdiv\u003ea \u0026 b\u003c/div\u003e"}` {
+		t.Errorf("expected escaped HTML by default, got: %s", got)
+	}
+
+	*unescapedHTML = true
+	t.Cleanup(func() { *unescapedHTML = false })
+	res := withResultContent(nil, result)
+	if res == nil || len(res.Content) != 1 {
+		t.Fatalf("expected a result text block, got: %+v", res)
+	}
+	if text := res.Content[0].(*mcp.TextContent).Text; text != `{"result":"<div>a & b</div>"}` {
+		t.Errorf("unexpected result block: %s", text)
+	}
+	if got := transcript("html()", nil, jseval.JsEvalResultDto{Result: result["result"]}); got != "> html()\n=> \"<div>a & b</div>\"" {
+		t.Errorf("unexpected transcript: %q", got)
+	}
+}
+
+// fixtureEnginePath is the fixture engine of the jseval tests: it writes
+// "fixture: started" to stderr, echoes stdin and exits with the code in the
+// EXIT environment variable.
+const fixtureEnginePath = "../../jseval/testdata/fixture.wasm"
+
+func TestErrorBlock(t *testing.T) {
+	evaluate := wasmEvaluator(t, fixtureEnginePath)
+	for name, tt := range map[string]struct {
+		code string
+		env  map[string]string
+		want string
+	}{
+		// The message of a failing exit is the stderr itself.
+		"FailingExit": {code: "1", env: map[string]string{"EXIT": "1"}, want: "error 1: fixture: started"},
+		"ParseFailure": {
+			code: "not json",
+			want: "error -1: Failed to parse successful WASM output as JSON\nstderr:\nfixture: started",
+		},
+	} {
+		logs := &logCollector{}
+		ctx := jseval.ContextWithLogSink(jseval.ContextWithEnv(context.Background(), tt.env), logs.sink)
+		result := evaluate(ctx, tt.code)
+		if result.Error == nil {
+			t.Fatalf("%s: expected the evaluation to fail, got: %+v", name, result)
+		}
+		if got := errorBlock(logs, result).(*mcp.TextContent).Text; got != tt.want {
+			t.Errorf("%s: unexpected error block: %q, want %q", name, got, tt.want)
+		}
+	}
+}
+
+func TestTranscript(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		logs   []string
+		result jseval.JsEvalResultDto
+		want   string
+	}{
+		{
+			name:   "Value",
+			code:   "console.error('hi');\n({ a: [1, 2] })\n",
+			logs:   []string{"hi"},
+			result: jseval.JsEvalResultDto{Result: map[string]any{"a": []any{1.0, 2.0}}},
+			want:   "> console.error('hi');\n> ({ a: [1, 2] })\nhi\n=> {\"a\":[1,2]}",
+		},
+		{
+			name:   "Error",
+			code:   "x",
+			result: jseval.JsEvalResultDto{Error: &jseval.ErrorDto{Code: 1, Message: "ReferenceError: x is not defined\n"}},
+			want:   "> x\n!! error 1: ReferenceError: x is not defined",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transcript(tt.code, tt.logs, tt.result); got != tt.want {
+				t.Errorf("unexpected transcript.\nGot:\n%s\nWant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
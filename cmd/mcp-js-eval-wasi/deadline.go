@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// errorCodeRequestDeadline is the ErrorDto code of an evaluation stopped by
+// -request-deadline rather than by the -timeout of its run.
+const errorCodeRequestDeadline = -9
+
+var errRequestDeadline = errors.New("request deadline exceeded")
+
+// withRequestDeadline bounds everything the handler does from now on, queuing
+// included. Without a limit it returns ctx unchanged.
+func withRequestDeadline(ctx context.Context, limit time.Duration) (context.Context, context.CancelFunc) {
+	if limit <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, limit, errRequestDeadline)
+}
+
+// requestDeadlineResult replaces the error of an evaluation ended by the
+// request deadline of ctx with one naming it.
+func requestDeadlineResult(ctx context.Context, limit time.Duration, result jseval.JsEvalResultDto) jseval.JsEvalResultDto {
+	if result.Error == nil || !errors.Is(context.Cause(ctx), errRequestDeadline) {
+		return result
+	}
+	result.Error = &jseval.ErrorDto{
+		Code:       errorCodeRequestDeadline,
+		Message:    fmt.Sprintf("request deadline of %v exceeded", limit),
+		Terminated: true,
+		Details:    map[string]any{"deadline_ms": limit.Milliseconds()},
+	}
+	return result
+}
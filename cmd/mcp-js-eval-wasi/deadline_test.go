@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+func TestRequestDeadline(t *testing.T) {
+	failed := jseval.JsEvalResultDto{Error: &jseval.ErrorDto{Code: 2, Message: "terminated by host: deadline exceeded", Terminated: true}}
+
+	ctx, cancel := withRequestDeadline(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	if got := requestDeadlineResult(ctx, time.Millisecond, failed); got.Error.Code != errorCodeRequestDeadline {
+		t.Errorf("expected a request deadline error, got: %+v", got.Error)
+	}
+
+	// The -timeout of the run expiring first keeps its own error.
+	ctx, cancel = withRequestDeadline(context.Background(), time.Hour)
+	defer cancel()
+	if got := requestDeadlineResult(ctx, time.Hour, failed); got.Error.Code != 2 {
+		t.Errorf("expected the evaluation error to be kept, got: %+v", got.Error)
+	}
+
+	if ctx, cancel := withRequestDeadline(context.Background(), 0); ctx != context.Background() {
+		t.Error("expected no deadline without a limit")
+	} else {
+		cancel()
+	}
+}
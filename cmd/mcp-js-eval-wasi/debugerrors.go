@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+const (
+	// maxDebugErrorMessage bounds the message kept per error; messages may
+	// quote the code or its output.
+	maxDebugErrorMessage = 256
+
+	// debugCodeHashChars is the length of the hex SHA-256 prefix identifying
+	// the code of an error: enough to tell retries of one script apart from
+	// others, too short to confirm a guess of secret code.
+	debugCodeHashChars = 12
+)
+
+// debugError is one failed evaluation as GET /debug/errors reports it. The
+// code itself, stderr and the error details are left out.
+type debugError struct {
+	Time     time.Time `json:"time"`
+	Code     int       `json:"code"`
+	Message  string    `json:"message"`
+	CodeHash string    `json:"codeHash"`
+}
+
+// errorLog keeps the last errors of evaluations in a ring buffer for
+// -debug-error-buffer. A nil *errorLog records nothing.
+type errorLog struct {
+	mu      sync.Mutex
+	entries []debugError // ring buffer of cap entries
+	next    int          // index written next, the oldest entry once full
+}
+
+func newErrorLog(size int) *errorLog {
+	return &errorLog{entries: make([]debugError, 0, size)}
+}
+
+// record keeps the error of result, if it has one.
+func (l *errorLog) record(code string, result jseval.JsEvalResultDto) {
+	if l == nil || result.Error == nil {
+		return
+	}
+	sum := sha256.Sum256([]byte(code))
+	entry := debugError{
+		Time:     time.Now().UTC(),
+		Code:     result.Error.Code,
+		Message:  truncateMessage(result.Error.Message, maxDebugErrorMessage),
+		CodeHash: hex.EncodeToString(sum[:])[:debugCodeHashChars],
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) < cap(l.entries) {
+		l.entries = append(l.entries, entry)
+	} else {
+		l.entries[l.next] = entry
+	}
+	l.next = (l.next + 1) % cap(l.entries)
+}
+
+// recent returns the kept errors, newest first.
+func (l *errorLog) recent() []debugError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	recent := make([]debugError, 0, len(l.entries))
+	for i := range len(l.entries) {
+		recent = append(recent, l.entries[(l.next-1-i+len(l.entries))%len(l.entries)])
+	}
+	return recent
+}
+
+// handler serves GET /debug/errors.
+func (l *errorLog) handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(map[string][]debugError{"errors": l.recent()}); err != nil {
+		log.Printf("failed to write the debug errors: %v", err)
+	}
+}
+
+// truncateMessage cuts s to at most limit bytes at a rune boundary, marking
+// the cut with "…".
+func truncateMessage(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "…"
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+func failed(code int, message string) jseval.JsEvalResultDto {
+	return jseval.JsEvalResultDto{Error: &jseval.ErrorDto{Code: code, Message: message}}
+}
+
+func TestErrorLog(t *testing.T) {
+	l := newErrorLog(2)
+	l.record("1", jseval.JsEvalResultDto{Result: 1.0})
+	l.record("secret = 'hunter2'; throw 1", failed(1, "first"))
+	l.record("b", failed(-3, "second"))
+	l.record("c", failed(-2, strings.Repeat("é", maxDebugErrorMessage)))
+
+	recent := l.recent()
+	if len(recent) != 2 {
+		t.Fatalf("expected the last 2 errors, got: %+v", recent)
+	}
+	if recent[0].Code != -2 || recent[1].Code != -3 || recent[1].Message != "second" {
+		t.Errorf("expected the newest error first, got: %+v", recent)
+	}
+	if m := recent[0].Message; len(m) > maxDebugErrorMessage+len("…") || !strings.HasSuffix(m, "é…") {
+		t.Errorf("expected the message truncated at a rune boundary, got %d bytes: %q", len(m), m)
+	}
+	if len(recent[1].CodeHash) != debugCodeHashChars {
+		t.Errorf("unexpected code hash %q", recent[1].CodeHash)
+	}
+
+	var nilLog *errorLog
+	nilLog.record("a", failed(1, "ignored"))
+}
+
+func TestErrorLogHandler(t *testing.T) {
+	l := newErrorLog(4)
+	l.record("secret = 'hunter2'; throw 1", failed(1, "Uncaught 1"))
+	handler := requireToken("token", http.HandlerFunc(l.handler))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/errors", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without the token, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/errors", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), "hunter2") {
+		t.Errorf("the code leaked: %s", rec.Body)
+	}
+	var body struct {
+		Errors []debugError `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+	if len(body.Errors) != 1 || body.Errors[0].Code != 1 || body.Errors[0].Message != "Uncaught 1" {
+		t.Errorf("unexpected errors: %+v", body.Errors)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/debug/errors", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	examplesURI       = "js-eval://examples"
+	writeCodePrompt   = "write-eval-js-code"
+	codeGuidelineText = `Write JavaScript for the eval-js tool following these rules:
+- Send the whole script as the "code" string of the tool input.
+- The value of the last expression is the result; it is returned as JSON.
+- Make that value JSON-serializable: objects, arrays, strings, numbers, booleans or null.
+- Functions, undefined, symbols and cyclic objects can not be returned; convert them first.
+- There is no network, file system or DOM access; only the standard built-ins are available.
+- Keep the script short; it runs with tight memory and time limits.`
+)
+
+type jsExample struct {
+	Title string `json:"title"`
+	Code  string `json:"code"`
+}
+
+var jsExamples = []jsExample{
+	{Title: "Arithmetic", Code: "1 + 2 * 3"},
+	{Title: "Object result", Code: "({ name: 'js-eval', ok: true })"},
+	{Title: "Array processing", Code: "[3, 1, 2].sort().map((x) => x * 10)"},
+	{Title: "String manipulation", Code: "'hello, world'.split(', ').map((s) => s.toUpperCase())"},
+	{Title: "Date to JSON", Code: "new Date(0).toISOString()"},
+}
+
+// registerDiscovery adds the examples resource and the code writing prompt
+// to help clients produce well-formed eval-js inputs.
+func registerDiscovery(server *mcp.Server) {
+	server.AddResource(&mcp.Resource{
+		URI:         examplesURI,
+		Name:        "examples",
+		Title:       "JavaScript Examples",
+		Description: "Common JavaScript snippets which can be passed to the eval-js tool as-is.",
+		MIMEType:    "application/json",
+	}, func(_ context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		encoded, err := json.MarshalIndent(jsExamples, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode examples: %w", err)
+		}
+		return &mcp.ReadResourceResult{
+			Contents: []*mcp.ResourceContents{{
+				URI:      req.Params.URI,
+				MIMEType: "application/json",
+				Text:     string(encoded),
+			}},
+		}, nil
+	})
+
+	server.AddPrompt(&mcp.Prompt{
+		Name:        writeCodePrompt,
+		Title:       "Write eval-js Code",
+		Description: "Guides the model to write code the eval-js tool can evaluate.",
+		Arguments: []*mcp.PromptArgument{{
+			Name:        "task",
+			Description: "What the code should compute.",
+			Required:    true,
+		}},
+	}, func(_ context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		task := req.Params.Arguments["task"]
+		return &mcp.GetPromptResult{
+			Description: "Write JavaScript for the eval-js tool",
+			Messages: []*mcp.PromptMessage{{
+				Role:    "user",
+				Content: &mcp.TextContent{Text: fmt.Sprintf("%s\n\nTask: %s", codeGuidelineText, task)},
+			}},
+		}, nil
+	})
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// engine is a loaded JavaScript engine with the evaluators of its runtimes.
+type engine struct {
+	evaluate jseval.Evaluator
+	info     *engineInfo
+	cleanups []func() error
+	inFlight sync.WaitGroup
+}
+
+// loadEngine loads and compiles the engine and checks that it answers.
+//
+// With -max-stack-depth the engine gets the stack depth argument; an engine
+// failing with it, exiting non-zero or writing to stderr as it does not know
+// the argument, is loaded again without, logging a warning.
+func loadEngine(ctx context.Context, path string, evalOpts []jseval.Option) (*engine, error) {
+	probeTimeout := time.Duration(*timeout) * time.Millisecond
+	stackOpts := stackDepthOptions()
+	e, err := compileEngine(ctx, path, append(slices.Clip(evalOpts), stackOpts...))
+	if err != nil {
+		return nil, err
+	}
+	if len(stackOpts) > 0 {
+		if argErr := checkEngineArgs(ctx, e.evaluate, probeTimeout); argErr == nil {
+			e.info.MaxStackDepth = *maxStackDepth
+		} else {
+			log.Printf("warning: the engine fails with %s, running it without a stack depth limit: %v", stackDepthArg(), argErr)
+			e.close()
+			if e, err = compileEngine(ctx, path, evalOpts); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if protocolErr := jseval.CheckEngineProtocol(ctx, e.evaluate, probeTimeout); protocolErr != nil {
+		log.Printf("warning: the engine may not speak protocol version %d: %v", *engineProtocol, protocolErr)
+	}
+
+	if *probe {
+		e.info.Capabilities = jseval.ProbeCapabilities(ctx, e.evaluate, jseval.DefaultFeatureProbes, probeTimeout)
+		log.Printf("Engine capabilities: %v", e.info.Capabilities)
+	}
+
+	return e, nil
+}
+
+// checkEngineArgs evaluates a trivial expression and reports an error when
+// the engine fails on its arguments: it exits non-zero or writes to stderr.
+// Other failures, e.g. of the protocol, say nothing about the arguments.
+func checkEngineArgs(ctx context.Context, evaluate jseval.Evaluator, timeout time.Duration) error {
+	probeCtx, cancel := context.WithTimeout(jseval.ContextWithProbe(ctx), timeout)
+	defer cancel()
+	failure := evaluate(probeCtx, "1 + 1").Error
+	if failure == nil || failure.Terminated {
+		return nil
+	}
+	if failure.ExitCode != nil && *failure.ExitCode != 0 || failure.Stderr != "" || failure.Code == jseval.ErrorCodeUnexpectedStderr {
+		return fmt.Errorf("error %d: %s", failure.Code, failure.Message)
+	}
+	return nil
+}
+
+// stackDepthArg is the engine argument setting -max-stack-depth.
+func stackDepthArg() string {
+	return fmt.Sprintf("%s=%d", *stackDepthFlag, *maxStackDepth)
+}
+
+// stackDepthOptions pass -max-stack-depth to the engine, if set.
+func stackDepthOptions() []jseval.Option {
+	if *maxStackDepth <= 0 {
+		return nil
+	}
+	return []jseval.Option{jseval.WithArgs(stackDepthArg()), jseval.WithStackOverflowCode()}
+}
+
+// maxPreloadBytes bounds the -preload-dir files, which are kept in memory.
+const maxPreloadBytes = 64 << 20
+
+// compileEngine loads and compiles the engine, creating one runtime per worker
+// or, with -pool-max, the elastic pool of runtimes.
+// The -preload-dir files are read along with it, so a reload picks up changes.
+func compileEngine(ctx context.Context, path string, evalOpts []jseval.Option) (*engine, error) {
+	wasmBinary, err := jseval.LoadWasmBinary(path, *maxWasmSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load WASM binary: %w", err)
+	}
+
+	if detected := jseval.DetectWASIVersion(wasmBinary); *wasiVersion != "auto" && detected != *wasiVersion {
+		return nil, fmt.Errorf("the engine is built for WASI %q, not the configured %s", detected, *wasiVersion)
+	}
+
+	memoryLimitPages, err := memoryPages(*mem)
+	if err != nil {
+		return nil, err
+	}
+
+	if *preloadDir != "" {
+		shared, err := jseval.LoadSharedFiles(*preloadDir, maxPreloadBytes)
+		if err != nil {
+			return nil, err
+		}
+		evalOpts = append(slices.Clip(evalOpts), jseval.WithSharedFiles(shared, *preloadMount))
+	}
+
+	e := &engine{info: newEngineInfo(path, wasmBinary)}
+	var (
+		mu            sync.Mutex
+		compileTime   time.Duration
+		hostFunctions []jseval.HostFunction
+		surface       jseval.ModuleSurface
+		errs          []error
+		compiling     sync.WaitGroup
+	)
+	workerOpts := append(slices.Clip(evalOpts), jseval.WithOnCompiled(func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		compileTime += d
+	}), jseval.WithOnHostFunctions(func(functions []jseval.HostFunction) {
+		mu.Lock()
+		defer mu.Unlock()
+		hostFunctions = functions
+	}), jseval.WithOnModuleSurface(func(s jseval.ModuleSurface) {
+		mu.Lock()
+		defer mu.Unlock()
+		surface = s
+	}))
+	// publish copies what the runtimes compiled so far reported into the info.
+	publish := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		e.info.CompileMs = compileTime.Milliseconds()
+		e.info.HostFunctions = hostFunctions
+		e.info.Imports, e.info.Exports = surface.Imports, surface.Exports
+		logHostFunctions(hostFunctions)
+		warnMissingStart(surface)
+	}
+	if *poolMax > 0 {
+		// The pool replaces the fixed workers, compiling runtimes on demand.
+		pool, err := newElasticPool(*poolMin, *poolMax, *poolIdleTimeout, func() (jseval.Evaluator, func() error, error) {
+			var evaluate jseval.Evaluator
+			var cleanup func() error
+			err := compiles.run("pooled runtime of "+path, func() error {
+				var err error
+				evaluate, cleanup, err = jseval.NewEvaluator(ctx, wasmBinary, memoryLimitPages, workerOpts...)
+				return err
+			})
+			return evaluate, cleanup, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create WASI JavaScript evaluator: %w", err)
+		}
+		e.evaluate = pool.evaluate
+		e.cleanups = []func() error{pool.close}
+		publish()
+		return e, nil
+	}
+
+	// Workers compile in parallel as far as -max-parallel-compiles allows.
+	evaluators := make([]jseval.Evaluator, *workers)
+	cleanups := make([]func() error, *workers)
+	for i := range evaluators {
+		compiling.Go(func() {
+			err := compiles.run(fmt.Sprintf("worker %d of %s", i, path), func() error {
+				var err error
+				evaluators[i], cleanups[i], err = jseval.NewEvaluator(ctx, wasmBinary, memoryLimitPages, workerOpts...)
+				return err
+			})
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		})
+	}
+	compiling.Wait()
+	for _, cleanup := range cleanups {
+		if cleanup != nil {
+			e.cleanups = append(e.cleanups, cleanup)
+		}
+	}
+	if len(errs) > 0 {
+		e.close()
+		return nil, fmt.Errorf("failed to create WASI JavaScript evaluator: %w", errors.Join(errs...))
+	}
+	e.evaluate = jseval.LeastBusy(evaluators...)
+	publish()
+	return e, nil
+}
+
+// logHostFunctions logs the host functions the engine imports and how many
+// more it could, for auditing what the sandbox grants.
+func logHostFunctions(functions []jseval.HostFunction) {
+	var imported []string
+	for _, f := range functions {
+		if f.Imported {
+			imported = append(imported, f.Module+"."+f.Name)
+		}
+	}
+	log.Printf("Host functions: the engine imports %d of %d: %s", len(imported), len(functions), strings.Join(imported, ", "))
+}
+
+// warnMissingStart warns of an engine which exports no _start function, as
+// WASI commands are run by calling it and every evaluation would fail. A pool
+// without runtimes yet has not compiled the engine and reports nothing.
+func warnMissingStart(surface jseval.ModuleSurface) {
+	if surface.Exports == nil {
+		return
+	}
+	for _, export := range surface.Exports {
+		if export.Name == "_start" && export.Kind == "function" {
+			return
+		}
+	}
+	log.Printf("Warning: the engine exports no _start function; it is not a WASI command")
+}
+
+// close releases the runtimes. Evaluations must not be running.
+func (e *engine) close() {
+	for _, cleanup := range e.cleanups {
+		if err := cleanup(); err != nil {
+			log.Printf("failed to cleanup WASI evaluator: %v", err)
+		}
+	}
+}
+
+// validationProbes must all pass before a reloaded engine replaces the live one.
+var validationProbes = []jseval.FeatureProbe{
+	{Name: "arithmetic", Code: "1 + 1 === 2"},
+	{Name: "strings", Code: "'a'.concat('b') === 'ab'"},
+	{Name: "json", Code: "JSON.stringify({ a: [1] }) === '{\"a\":[1]}'"},
+}
+
+// validate runs validationProbes. ProbeCapabilities runs them as probes, so
+// the options shaping results do not make them fail.
+func (e *engine) validate(ctx context.Context) error {
+	probeTimeout := time.Duration(*timeout) * time.Millisecond
+	passed := jseval.ProbeCapabilities(ctx, e.evaluate, validationProbes, probeTimeout)
+	for _, probe := range validationProbes {
+		if !passed[probe.Name] {
+			return fmt.Errorf("validation probe %q failed", probe.Name)
+		}
+	}
+	return nil
+}
+
+// errorCodeEngineNotReady is the ErrorDto code of an evaluation arriving
+// while no engine is loaded. Like a 503 it is worth retrying.
+const errorCodeEngineNotReady = -10
+
+// liveEngine holds the engine serving evaluations and replaces it on reload.
+// Until an engine is set, evaluations fail as not ready.
+type liveEngine struct {
+	mu      sync.RWMutex
+	current *engine
+
+	// lazy, when set, loads the engine on the first evaluation instead.
+	lazy     func() (*engine, error)
+	lazyOnce sync.Once
+	lazyErr  error
+}
+
+// loadLazily loads the engine with lazy once, unless a reload set one first.
+// A failed load is not retried; a reload can still set the engine.
+func (l *liveEngine) loadLazily() {
+	l.lazyOnce.Do(func() {
+		if l.info() != nil {
+			return
+		}
+		log.Printf("Loading the engine for the first evaluation")
+		loaded, err := l.lazy()
+		if err != nil {
+			log.Printf("failed to load the engine: %v", err)
+			l.lazyErr = err
+			return
+		}
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.current != nil {
+			loaded.close()
+			return
+		}
+		l.current = loaded
+	})
+}
+
+// acquire returns the current engine, counted as in flight, or nil.
+func (l *liveEngine) acquire() *engine {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.current == nil {
+		return nil
+	}
+	l.current.inFlight.Add(1)
+	return l.current
+}
+
+func (l *liveEngine) evaluate(ctx context.Context, code string) jseval.JsEvalResultDto {
+	e := l.acquire()
+	if e == nil && l.lazy != nil {
+		l.loadLazily()
+		e = l.acquire()
+	}
+	if e == nil {
+		message := "engine not ready, try again later"
+		if l.lazyErr != nil {
+			message = fmt.Sprintf("engine not ready: %v", l.lazyErr)
+		}
+		return jseval.JsEvalResultDto{Error: &jseval.ErrorDto{Code: errorCodeEngineNotReady, Message: message}}
+	}
+	defer e.inFlight.Done()
+	return e.evaluate(ctx, code)
+}
+
+// info describes the current engine, or is nil without one.
+func (l *liveEngine) info() *engineInfo {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.current == nil {
+		return nil
+	}
+	return l.current.info
+}
+
+// close closes the live engine. Evaluations must not be running.
+func (l *liveEngine) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.current != nil {
+		l.current.close()
+	}
+}
+
+// reload replaces the live engine in two phases. The new engine is loaded,
+// compiled and validated while the old one keeps serving; only if that all
+// succeeds is it swapped in. The old engine is closed once its in-flight
+// evaluations finish. On failure the old engine stays live.
+func (l *liveEngine) reload(ctx context.Context, load func(context.Context) (*engine, error)) error {
+	log.Printf("Reload phase 1: loading and validating the new engine")
+	next, err := load(ctx)
+	if err != nil {
+		return fmt.Errorf("reload aborted, keeping the current engine: %w", err)
+	}
+	if err := next.validate(ctx); err != nil {
+		next.close()
+		return fmt.Errorf("reload aborted, keeping the current engine: %w", err)
+	}
+
+	log.Printf("Reload phase 2: switching to engine %s", next.info.SHA256)
+	l.mu.Lock()
+	previous := l.current
+	l.current = next
+	l.mu.Unlock()
+
+	if previous == nil {
+		return nil
+	}
+	go func() {
+		previous.inFlight.Wait()
+		previous.close()
+		log.Printf("Reload complete: closed engine %s", previous.info.SHA256)
+	}()
+	return nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// fakeEngine answers every evaluation with the given result.
+func fakeEngine(sha string, result any, closed chan<- string) *engine {
+	return &engine{
+		evaluate: func(context.Context, string) jseval.JsEvalResultDto {
+			return jseval.JsEvalResultDto{Result: result}
+		},
+		info: &engineInfo{SHA256: sha},
+		cleanups: []func() error{func() error {
+			closed <- sha
+			return nil
+		}},
+	}
+}
+
+func TestLiveEngineReload(t *testing.T) {
+	closed := make(chan string, 2)
+	live := &liveEngine{current: fakeEngine("old", true, closed)}
+
+	t.Run("FailedValidationKeepsCurrentEngine", func(t *testing.T) {
+		err := live.reload(context.Background(), func(context.Context) (*engine, error) {
+			return fakeEngine("broken", false, closed), nil
+		})
+		if err == nil || !strings.Contains(err.Error(), "validation probe") {
+			t.Fatalf("reload() was expected to fail validation, got: %v", err)
+		}
+		if got := live.info().SHA256; got != "old" {
+			t.Errorf("the live engine changed to %q", got)
+		}
+		if got := <-closed; got != "broken" {
+			t.Errorf("expected the rejected engine to be closed, closed %q", got)
+		}
+	})
+
+	t.Run("FailedLoadKeepsCurrentEngine", func(t *testing.T) {
+		err := live.reload(context.Background(), func(context.Context) (*engine, error) {
+			return nil, errors.New("no such file")
+		})
+		if err == nil {
+			t.Fatal("reload() was expected to fail")
+		}
+		if got := live.info().SHA256; got != "old" {
+			t.Errorf("the live engine changed to %q", got)
+		}
+	})
+
+	t.Run("ValidEngineIsSwappedIn", func(t *testing.T) {
+		err := live.reload(context.Background(), func(context.Context) (*engine, error) {
+			return fakeEngine("new", true, closed), nil
+		})
+		if err != nil {
+			t.Fatalf("reload() returned an unexpected error: %v", err)
+		}
+		if got := live.info().SHA256; got != "new" {
+			t.Errorf("the live engine is %q, want new", got)
+		}
+
+		select {
+		case got := <-closed:
+			if got != "old" {
+				t.Errorf("expected the previous engine to be closed, closed %q", got)
+			}
+		case <-time.After(time.Second):
+			t.Error("the previous engine was not closed")
+		}
+	})
+}
+
+// wasmEvaluator runs the test engine at path with opts until the test ends.
+func wasmEvaluator(t *testing.T, path string, opts ...jseval.Option) jseval.Evaluator {
+	t.Helper()
+	wasm, err := jseval.LoadWasmBinary(path, 1)
+	if err != nil {
+		t.Fatalf("failed to load the engine: %v", err)
+	}
+	evaluate, cleanup, err := jseval.NewEvaluator(context.Background(), wasm, 1, opts...)
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = cleanup() })
+	return evaluate
+}
+
+// wasmEngine runs trueEnginePath with opts.
+func wasmEngine(t *testing.T, sha string, opts ...jseval.Option) *engine {
+	t.Helper()
+	return &engine{evaluate: wasmEvaluator(t, trueEnginePath, opts...), info: &engineInfo{SHA256: sha}}
+}
+
+func TestCheckEngineArgs(t *testing.T) {
+	ctx := context.Background()
+	// Result options make "1 + 1" fail the protocol check, not the arguments.
+	for _, opts := range [][]jseval.Option{nil, {jseval.WithTextResult()}, {jseval.WithRequireObjectResult()}} {
+		if err := checkEngineArgs(ctx, wasmEvaluator(t, trueEnginePath, opts...), time.Second); err != nil {
+			t.Errorf("expected the arguments to be accepted, got: %v", err)
+		}
+	}
+	for _, path := range []string{usageEnginePath, exitEnginePath} {
+		if err := checkEngineArgs(ctx, wasmEvaluator(t, path), time.Second); err == nil {
+			t.Errorf("%s: expected the arguments to be rejected", path)
+		}
+	}
+	if err := checkEngineArgs(ctx, wasmEvaluator(t, usageEnginePath, jseval.WithFailOnStderr()), time.Second); err == nil {
+		t.Error("expected stderr output to reject the arguments WithFailOnStderr")
+	}
+}
+
+func TestLiveEngineReloadWithResultOptions(t *testing.T) {
+	store, err := jseval.NewTempFileStore(t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, opts := range map[string][]jseval.Option{
+		"raw-output":            {jseval.WithTextResult()},
+		"output-dir":            {jseval.WithOutputStore(store)},
+		"require-object-result": {jseval.WithRequireObjectResult()},
+	} {
+		live := &liveEngine{current: fakeEngine("old", true, make(chan string, 1))}
+		err := live.reload(context.Background(), func(context.Context) (*engine, error) {
+			return wasmEngine(t, "new", opts...), nil
+		})
+		if err != nil {
+			t.Errorf("%s: reload() returned an unexpected error: %v", name, err)
+		}
+		if got := live.info().SHA256; got != "new" {
+			t.Errorf("%s: the live engine is %q, want new", name, got)
+		}
+		live.close()
+	}
+}
+
+func TestLiveEngineNotReady(t *testing.T) {
+	live := &liveEngine{}
+
+	result := live.evaluate(context.Background(), "1")
+	if result.Error == nil || result.Error.Code != errorCodeEngineNotReady {
+		t.Errorf("expected an engine not ready error, got: %+v", result.Error)
+	}
+	if info := live.info(); info != nil {
+		t.Errorf("expected no engine info, got: %+v", info)
+	}
+	live.close()
+
+	closed := make(chan string, 1)
+	if err := live.reload(context.Background(), func(context.Context) (*engine, error) {
+		return fakeEngine("first", true, closed), nil
+	}); err != nil {
+		t.Fatalf("reload() returned an unexpected error: %v", err)
+	}
+	if result := live.evaluate(context.Background(), "1"); result.Error != nil {
+		t.Errorf("expected the loaded engine to serve, got: %+v", result.Error)
+	}
+}
+
+func TestLiveEngineReloadRace(t *testing.T) {
+	closed := make(chan string, 64)
+	live := &liveEngine{current: fakeEngine("0", true, closed)}
+
+	ctx, stop := context.WithCancel(context.Background())
+	var evaluations sync.WaitGroup
+	for range 4 {
+		evaluations.Go(func() {
+			for ctx.Err() == nil {
+				if result := live.evaluate(ctx, "1"); result.Error != nil || result.Result != true {
+					t.Errorf("unexpected result during reload: %+v", result)
+					return
+				}
+			}
+		})
+	}
+
+	for i := range 20 {
+		err := live.reload(ctx, func(context.Context) (*engine, error) {
+			return fakeEngine(fmt.Sprint(i+1), true, closed), nil
+		})
+		if err != nil {
+			t.Fatalf("reload() returned an unexpected error: %v", err)
+		}
+	}
+	stop()
+	evaluations.Wait()
+
+	for range 20 {
+		select {
+		case <-closed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("a replaced engine was not closed")
+		}
+	}
+}
+
+func TestLiveEngineLazyLoad(t *testing.T) {
+	closed := make(chan string, 1)
+	var loads int
+	live := &liveEngine{lazy: func() (*engine, error) {
+		loads++
+		return fakeEngine("lazy", true, closed), nil
+	}}
+	if info := live.info(); info != nil {
+		t.Fatalf("expected no engine before the first evaluation, got: %+v", info)
+	}
+
+	var evaluations sync.WaitGroup
+	for range 8 {
+		evaluations.Go(func() {
+			if result := live.evaluate(context.Background(), "1"); result.Error != nil || result.Result != true {
+				t.Errorf("unexpected result of a lazily loaded engine: %+v", result)
+			}
+		})
+	}
+	evaluations.Wait()
+	if loads != 1 {
+		t.Errorf("expected the engine to be loaded once, loaded %d times", loads)
+	}
+	if got := live.info().SHA256; got != "lazy" {
+		t.Errorf("the live engine is %q, want lazy", got)
+	}
+}
+
+func TestLiveEngineLazyLoadFailure(t *testing.T) {
+	live := &liveEngine{lazy: func() (*engine, error) { return nil, errors.New("no such file") }}
+
+	result := live.evaluate(context.Background(), "1")
+	if result.Error == nil || result.Error.Code != errorCodeEngineNotReady || !strings.Contains(result.Error.Message, "no such file") {
+		t.Fatalf("expected the load error as not ready, got: %+v", result.Error)
+	}
+
+	closed := make(chan string, 1)
+	if err := live.reload(context.Background(), func(context.Context) (*engine, error) {
+		return fakeEngine("reloaded", true, closed), nil
+	}); err != nil {
+		t.Fatalf("reload() returned an unexpected error: %v", err)
+	}
+	if result := live.evaluate(context.Background(), "1"); result.Error != nil {
+		t.Errorf("expected a reload to recover from the failed lazy load, got: %+v", result.Error)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// engineInfo describes the loaded JavaScript engine.
+type engineInfo struct {
+	Path   string `json:"path"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+
+	// CompileMs is the time spent compiling the engine, summed over the workers.
+	CompileMs int64 `json:"compileMs"`
+
+	// MaxStackDepth is the -max-stack-depth the engine accepted, if any.
+	MaxStackDepth int `json:"maxStackDepth,omitempty"`
+
+	// HostFunctions are all functions the host offers the engine, marking
+	// those it imports: the complete list of what the sandbox grants.
+	HostFunctions []jseval.HostFunction `json:"hostFunctions"`
+
+	// Imports and Exports are the functions and memories of the compiled
+	// engine module, for telling why an engine file does not work.
+	Imports []jseval.ModuleItem `json:"imports"`
+	Exports []jseval.ModuleItem `json:"exports"`
+
+	// Capabilities is the result of the startup feature probe, if enabled.
+	Capabilities map[string]bool `json:"capabilities,omitempty"`
+}
+
+type engineInfoInput struct{}
+
+func newEngineInfo(path string, wasmBinary []byte) *engineInfo {
+	sum := sha256.Sum256(wasmBinary)
+	return &engineInfo{
+		Path:   path,
+		Size:   len(wasmBinary),
+		SHA256: hex.EncodeToString(sum[:]),
+	}
+}
+
+func registerEngineInfo(server *mcp.Server, info func() *engineInfo) {
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "engine-info",
+		Title:       "JavaScript Engine Info",
+		Description: "Describes the JavaScript engine: its identity and the language features it supports.",
+	}, func(context.Context, *mcp.CallToolRequest, engineInfoInput) (*mcp.CallToolResult, *engineInfo, error) {
+		return nil, info(), nil
+	})
+}
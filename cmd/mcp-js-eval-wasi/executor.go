@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// execution is an evaluation submitted to an executor.
+type execution struct {
+	ctx      context.Context
+	code     string
+	evaluate jseval.Evaluator
+	done     chan jseval.JsEvalResultDto
+}
+
+// executor runs evaluations on a fixed set of worker goroutines instead of
+// the goroutine of each request, so that however many requests arrive, no
+// more evaluations run at once than there are workers. A panic of an
+// evaluation is recovered by its worker and reported as a host failure, and
+// the worker goes on with the next one.
+type executor struct {
+	executions chan execution
+	stop       chan struct{}
+	running    sync.WaitGroup
+}
+
+// newExecutor starts workers goroutines.
+func newExecutor(workers int) *executor {
+	x := &executor{executions: make(chan execution), stop: make(chan struct{})}
+	for range workers {
+		x.running.Go(x.work)
+	}
+	return x
+}
+
+func (x *executor) work() {
+	for {
+		select {
+		case e := <-x.executions:
+			e.done <- runRecovered(e)
+		case <-x.stop:
+			return
+		}
+	}
+}
+
+// runRecovered runs e, turning a panic into an error result.
+func runRecovered(e execution) (result jseval.JsEvalResultDto) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Evaluation panicked: %v\n%s", r, debug.Stack())
+			result = jseval.JsEvalResultDto{Error: &jseval.ErrorDto{Code: -1, Message: fmt.Sprintf("evaluation panicked: %v", r)}}
+		}
+	}()
+	return e.evaluate(e.ctx, e.code)
+}
+
+// evaluate runs code with evaluate on a worker, waiting for a free one until
+// ctx is done. Once a worker runs it, it waits for the result: the evaluation
+// itself stops when ctx is done, and reports how. A nil executor runs it on
+// the calling goroutine.
+func (x *executor) evaluate(ctx context.Context, code string, evaluate jseval.Evaluator) jseval.JsEvalResultDto {
+	if x == nil {
+		return evaluate(ctx, code)
+	}
+	e := execution{ctx: ctx, code: code, evaluate: evaluate, done: make(chan jseval.JsEvalResultDto, 1)}
+	select {
+	case x.executions <- e:
+		return <-e.done
+	case <-ctx.Done():
+		return jseval.JsEvalResultDto{Error: &jseval.ErrorDto{
+			Code:       jseval.ErrorCodeServerBusy,
+			Message:    fmt.Sprintf("server busy: gave up waiting for a free worker: %v", context.Cause(ctx)),
+			Terminated: true,
+		}}
+	}
+}
+
+// close stops the workers once their current evaluations are done.
+// Evaluations must no longer be submitted.
+func (x *executor) close() {
+	if x == nil {
+		return
+	}
+	close(x.stop)
+	x.running.Wait()
+}
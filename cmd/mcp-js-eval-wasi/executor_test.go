@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+func TestExecutorBoundsConcurrency(t *testing.T) {
+	x := newExecutor(2)
+	defer x.close()
+
+	var running, peak atomic.Int64
+	evaluate := func(context.Context, string) jseval.JsEvalResultDto {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(5 * time.Millisecond)
+		return jseval.JsEvalResultDto{Result: 1}
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			if result := x.evaluate(context.Background(), "1", evaluate); result.Error != nil {
+				t.Errorf("unexpected error: %+v", result.Error)
+			}
+		})
+	}
+	wg.Wait()
+	if got := peak.Load(); got > 2 {
+		t.Errorf("peak concurrency = %d, want at most 2", got)
+	}
+}
+
+func TestExecutorRecoversPanics(t *testing.T) {
+	x := newExecutor(1)
+	defer x.close()
+
+	result := x.evaluate(context.Background(), "", func(context.Context, string) jseval.JsEvalResultDto {
+		panic("boom")
+	})
+	if result.Error == nil || result.Error.Code != -1 || result.Error.Message != "evaluation panicked: boom" {
+		t.Fatalf("expected the panic as a host failure, got: %+v", result.Error)
+	}
+
+	// The worker survives the panic.
+	result = x.evaluate(context.Background(), "", func(context.Context, string) jseval.JsEvalResultDto {
+		return jseval.JsEvalResultDto{Result: 2}
+	})
+	if result.Error != nil || result.Result != 2 {
+		t.Errorf("unexpected result after the panic: %+v", result)
+	}
+}
+
+func TestExecutorGivesUpWaiting(t *testing.T) {
+	x := newExecutor(1)
+	defer x.close()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go x.evaluate(context.Background(), "", func(context.Context, string) jseval.JsEvalResultDto {
+		close(started)
+		<-release
+		return jseval.JsEvalResultDto{}
+	})
+	<-started
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	result := x.evaluate(ctx, "", func(context.Context, string) jseval.JsEvalResultDto {
+		t.Error("expected the evaluation not to run")
+		return jseval.JsEvalResultDto{}
+	})
+	if result.Error == nil || result.Error.Code != jseval.ErrorCodeServerBusy {
+		t.Errorf("expected a busy error, got: %+v", result.Error)
+	}
+}
+
+func TestNilExecutor(t *testing.T) {
+	var x *executor
+	result := x.evaluate(context.Background(), "3", func(_ context.Context, code string) jseval.JsEvalResultDto {
+		return jseval.JsEvalResultDto{Result: code}
+	})
+	if result.Result != "3" {
+		t.Errorf("result = %v, want 3", result.Result)
+	}
+	x.close()
+}
+
+// BenchmarkExecutor compares running evaluations on the goroutine of each
+// request with handing them to a fixed set of workers.
+func BenchmarkExecutor(b *testing.B) {
+	evaluate := func(context.Context, string) jseval.JsEvalResultDto {
+		return jseval.JsEvalResultDto{Result: 1}
+	}
+	b.Run("GoroutinePerRequest", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				var x *executor
+				x.evaluate(context.Background(), "1", evaluate)
+			}
+		})
+	})
+	b.Run("Workers", func(b *testing.B) {
+		x := newExecutor(4)
+		defer x.close()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				x.evaluate(context.Background(), "1", evaluate)
+			}
+		})
+	})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+)
+
+// The test engines in testdata are WASI commands which ignore the code. They
+// are assembled by testEngines; after changing it, regenerate the committed
+// files with:
+//
+//	go test ./cmd/mcp-js-eval-wasi -run TestEngineFixtures -update
+var updateFixtures = flag.Bool("update", false, "regenerate the test engines in testdata")
+
+const (
+	// trueEnginePath answers every evaluation with true.
+	trueEnginePath = "testdata/true.wasm"
+	// usageEnginePath writes an unknown option error to stderr and exits 0.
+	usageEnginePath = "testdata/usage.wasm"
+	// exitEnginePath exits with code 2 without any output.
+	exitEnginePath = "testdata/exit2.wasm"
+)
+
+// testEngines returns the test engines by path.
+func testEngines() map[string][]byte {
+	return map[string][]byte{
+		trueEnginePath:  writeAndExitWasm(1, "true", -1),
+		usageEnginePath: writeAndExitWasm(2, "unknown option --max-stack\n", -1),
+		exitEnginePath:  writeAndExitWasm(1, "", 2),
+	}
+}
+
+func TestEngineFixtures(t *testing.T) {
+	for path, want := range testEngines() {
+		if *updateFixtures {
+			if err := os.WriteFile(path, want, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read the test engine: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s is outdated; regenerate it with -update", path)
+		}
+	}
+}
+
+// writeAndExitWasm builds a WASI command module which writes output to the
+// file descriptor fd and then calls proc_exit(exitCode), as the helper of the
+// same name in the jseval tests. A negative exitCode returns normally from
+// _start instead. Lengths are encoded in one byte, so output must stay below
+// about 90 bytes.
+func writeAndExitWasm(fd byte, output string, exitCode int8) []byte {
+	const dataOffset = 16
+	name := func(s string) []byte { return append([]byte{byte(len(s))}, s...) }
+	section := func(id byte, payload []byte) []byte {
+		return append([]byte{id, byte(len(payload))}, payload...)
+	}
+
+	types := []byte{3}
+	types = append(types, 0x60, 4, 0x7f, 0x7f, 0x7f, 0x7f, 1, 0x7f) // fd_write
+	types = append(types, 0x60, 1, 0x7f, 0)                         // proc_exit
+	types = append(types, 0x60, 0, 0)                               // _start
+
+	imports := []byte{2}
+	imports = append(imports, name("wasi_snapshot_preview1")...)
+	imports = append(imports, name("fd_write")...)
+	imports = append(imports, 0x00, 0)
+	imports = append(imports, name("wasi_snapshot_preview1")...)
+	imports = append(imports, name("proc_exit")...)
+	imports = append(imports, 0x00, 1)
+
+	exports := []byte{2}
+	exports = append(exports, name("memory")...)
+	exports = append(exports, 0x02, 0)
+	exports = append(exports, name("_start")...)
+	exports = append(exports, 0x00, 2)
+
+	// fd_write(fd, iovs=0, iovs_len=1, nwritten=8)
+	body := []byte{0}
+	body = append(body, 0x41, fd, 0x41, 0, 0x41, 1, 0x41, 8, 0x10, 0, 0x1a)
+	if exitCode >= 0 {
+		body = append(body, 0x41, byte(exitCode), 0x10, 1)
+	}
+	body = append(body, 0x0b)
+	code := append([]byte{1, byte(len(body))}, body...)
+
+	// iovec{buf: dataOffset, len: len(output)} followed by the payload.
+	segment := make([]byte, dataOffset, dataOffset+len(output))
+	segment[0] = dataOffset
+	segment[4], segment[5] = byte(len(output)), byte(len(output)>>8)
+	segment = append(segment, output...)
+	data := append([]byte{1, 0, 0x41, 0, 0x0b, byte(len(segment))}, segment...)
+
+	wasm := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	wasm = append(wasm, section(1, types)...)
+	wasm = append(wasm, section(2, imports)...)
+	wasm = append(wasm, section(3, []byte{1, 2})...)
+	wasm = append(wasm, section(5, []byte{1, 0, 1})...)
+	wasm = append(wasm, section(7, exports)...)
+	wasm = append(wasm, section(10, code)...)
+	wasm = append(wasm, section(11, data)...)
+	return wasm
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func largeResult(n int) string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = fmt.Sprintf(`{"id":%d,"name":"item-%d","ok":true}`, i, i)
+	}
+	return `{"result":[` + strings.Join(items, ",") + `]}`
+}
+
+func serveGzip(t testing.TB, body, acceptEncoding string, flush bool) *httptest.ResponseRecorder {
+	t.Helper()
+	handler := withGzip(1024, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, body)
+		if flush {
+			w.(http.Flusher).Flush()
+		}
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWithGzip(t *testing.T) {
+	large := largeResult(100)
+
+	for name, tt := range map[string]struct {
+		body           string
+		acceptEncoding string
+		flush          bool
+		wantGzip       bool
+	}{
+		"Large":             {body: large, acceptEncoding: "gzip, deflate", wantGzip: true},
+		"LargeFlushed":      {body: large, acceptEncoding: "gzip", flush: true, wantGzip: true},
+		"Small":             {body: `{"result":1}`, acceptEncoding: "gzip"},
+		"SmallFlushed":      {body: `{"result":1}`, acceptEncoding: "gzip", flush: true},
+		"NotAccepted":       {body: large},
+		"ExplicitlyRefused": {body: large, acceptEncoding: "gzip;q=0"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := serveGzip(t, tt.body, tt.acceptEncoding, tt.flush)
+			got := rec.Body.Bytes()
+			if tt.wantGzip {
+				if rec.Header().Get("Content-Encoding") != "gzip" {
+					t.Fatalf("expected a gzip response, got headers %v", rec.Header())
+				}
+				zr, err := gzip.NewReader(bytes.NewReader(got))
+				if err != nil {
+					t.Fatalf("invalid gzip stream: %v", err)
+				}
+				if got, err = io.ReadAll(zr); err != nil {
+					t.Fatalf("invalid gzip stream: %v", err)
+				}
+			} else if rec.Header().Get("Content-Encoding") != "" {
+				t.Fatalf("expected an uncompressed response, got headers %v", rec.Header())
+			}
+			if string(got) != tt.body {
+				t.Errorf("the body changed: got %d bytes, want %d", len(got), len(tt.body))
+			}
+		})
+	}
+}
+
+// BenchmarkGzip shows the CPU cost of compressing a large result against the
+// bytes it saves.
+func BenchmarkGzip(b *testing.B) {
+	body := largeResult(5000)
+	for _, acceptEncoding := range []string{"", "gzip"} {
+		name := "Identity"
+		if acceptEncoding != "" {
+			name = "Gzip"
+		}
+		b.Run(name, func(b *testing.B) {
+			var sent int
+			for b.Loop() {
+				sent = serveGzip(b, body, acceptEncoding, false).Body.Len()
+			}
+			b.ReportMetric(float64(sent), "bytes/response")
+		})
+	}
+}
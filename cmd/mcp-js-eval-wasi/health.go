@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// drainState tells the load balancer, through /healthz, whether to keep
+// sending requests. Draining does not refuse requests by itself; running and
+// newly arriving evaluations are still served until the server shuts down.
+type drainState struct {
+	draining atomic.Bool
+}
+
+func (d *drainState) healthz(w http.ResponseWriter, _ *http.Request) {
+	if d.draining.Load() {
+		writeJSONError(w, http.StatusServiceUnavailable, "draining")
+		return
+	}
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+func (d *drainState) drain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if d.draining.CompareAndSwap(false, true) {
+		log.Printf("Draining: /healthz now reports 503")
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// requireToken only passes requests carrying the bearer token.
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDrain(t *testing.T) {
+	state := &drainState{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", state.healthz)
+	mux.Handle("/drain", requireToken("secret", http.HandlerFunc(state.drain)))
+
+	do := func(method, path, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := do(http.MethodGet, "/healthz", ""); code != http.StatusOK {
+		t.Fatalf("expected a healthy instance, got %d", code)
+	}
+	if code := do(http.MethodPost, "/drain", ""); code != http.StatusUnauthorized {
+		t.Errorf("expected /drain without a token to be refused, got %d", code)
+	}
+	if code := do(http.MethodPost, "/drain", "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("expected /drain with a wrong token to be refused, got %d", code)
+	}
+	if code := do(http.MethodGet, "/drain", "secret"); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected GET /drain to be refused, got %d", code)
+	}
+	if code := do(http.MethodGet, "/healthz", ""); code != http.StatusOK {
+		t.Fatalf("expected refused drain requests to keep the instance healthy, got %d", code)
+	}
+
+	if code := do(http.MethodPost, "/drain", "secret"); code != http.StatusAccepted {
+		t.Fatalf("expected /drain to be accepted, got %d", code)
+	}
+	if code := do(http.MethodGet, "/healthz", ""); code != http.StatusServiceUnavailable {
+		t.Errorf("expected a draining instance to be unhealthy, got %d", code)
+	}
+}
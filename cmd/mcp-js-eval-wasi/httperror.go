@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// httpErrorBody is the JSON body of every HTTP error response. Its error has
+// the shape of the ErrorDto of tool results, with the HTTP status as code.
+type httpErrorBody struct {
+	Error jseval.ErrorDto `json:"error"`
+}
+
+// writeJSONError replies with the status and a JSON error body. Handlers and
+// middleware use it instead of http.Error.
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(httpErrorBody{Error: jseval.ErrorDto{Code: status, Message: message}}); err != nil {
+		log.Printf("failed to write the error response: %v", err)
+	}
+}
+
+// withJSONErrors rewrites the plain text error responses of handlers which
+// use http.Error, such as those of the MCP SDK, into writeJSONError bodies.
+func withJSONErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jw := &jsonErrorWriter{ResponseWriter: w}
+		defer jw.finish()
+		next.ServeHTTP(jw, r)
+	})
+}
+
+// jsonErrorWriter holds back the body of a plain text error response to
+// send it as JSON once the handler is done.
+type jsonErrorWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	status      int
+	message     []byte
+}
+
+func (w *jsonErrorWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	if status >= http.StatusBadRequest && h.Get("Content-Encoding") == "" &&
+		strings.HasPrefix(h.Get("Content-Type"), "text/plain") {
+		w.status = status
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *jsonErrorWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.status != 0 {
+		w.message = append(w.message, p...)
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *jsonErrorWriter) Flush() {
+	if w.status != 0 {
+		return
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *jsonErrorWriter) finish() {
+	if w.status != 0 {
+		writeJSONError(w.ResponseWriter, w.status, strings.TrimSpace(string(w.message)))
+	}
+}
+
+func (w *jsonErrorWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// limitBody refuses bodies above limit with 413, up front when the request
+// declares its length and otherwise through http.MaxBytesReader.
+func limitBody(limit int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func decodeHTTPError(t *testing.T, rec *httptest.ResponseRecorder) httpErrorBody {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected a JSON error, got content type %q: %s", ct, rec.Body)
+	}
+	var body httpErrorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode the error %q: %v", rec.Body, err)
+	}
+	return body
+}
+
+func TestWithJSONErrors(t *testing.T) {
+	handler := withJSONErrors(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/plain":
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+		case "/ok":
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("fine"))
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/plain", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected the status to be kept, got %d", rec.Code)
+	}
+	body := decodeHTTPError(t, rec)
+	if body.Error.Code != http.StatusBadRequest || body.Error.Message != "failed to read body" {
+		t.Errorf("unexpected error: %+v", body.Error)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "fine" {
+		t.Errorf("expected successful responses to pass through, got %d %q", rec.Code, rec.Body)
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	handler := withJSONErrors(limitBody(4, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Body.Read(make([]byte, 16)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	})))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("too long")))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for a declared oversized body, got %d", rec.Code)
+	}
+	if body := decodeHTTPError(t, rec); body.Error.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("unexpected error: %+v", body.Error)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("too long"))
+	req.ContentLength = -1
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected the handler to fail reading an undeclared oversized body, got %d", rec.Code)
+	}
+	decodeHTTPError(t, rec)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metricLabelName matches keys usable as Prometheus label names.
+var metricLabelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// parseMetricLabels parses the comma separated -metric-labels allowlist.
+func parseMetricLabels(spec string) ([]string, error) {
+	var names []string
+	for name := range strings.SplitSeq(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !metricLabelName.MatchString(name) || strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("%q is not a valid metric label name", name)
+		}
+		if slices.Contains(names, name) {
+			return nil, fmt.Errorf("metric label %q is listed twice", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// formatLabels renders evaluation labels for a log line as sorted key="value"
+// pairs, or "" without labels. Keys which are not plain names are quoted as
+// well so that no label can forge log content.
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		value := strconv.Quote(labels[key])
+		if !metricLabelName.MatchString(key) {
+			key = strconv.Quote(key)
+		}
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, " ")
+}
+
+// otherLabelValue stands in for the metric label values beyond the limit.
+const otherLabelValue = "other"
+
+// labelValues bounds the distinct values of each metric label: the first max
+// values seen of a label are kept, later ones become otherLabelValue. The
+// allowlist bounds the label names, this their values, as every value
+// clients send would otherwise be a series of its own.
+type labelValues struct {
+	max int
+
+	mu   sync.Mutex
+	seen map[string]map[string]struct{}
+}
+
+func newLabelValues(max int) *labelValues {
+	return &labelValues{max: max, seen: map[string]map[string]struct{}{}}
+}
+
+// value returns the value of the label name to record for value. An empty
+// value, for evaluations without the label, is always kept.
+func (l *labelValues) value(name, value string) string {
+	if value == "" {
+		return value
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	seen := l.seen[name]
+	if _, ok := seen[value]; ok {
+		return value
+	}
+	if len(seen) >= l.max {
+		return otherLabelValue
+	}
+	if seen == nil {
+		seen = map[string]struct{}{}
+		l.seen[name] = seen
+	}
+	seen[value] = struct{}{}
+	return value
+}
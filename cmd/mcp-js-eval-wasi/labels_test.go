@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+func TestParseMetricLabels(t *testing.T) {
+	names, err := parseMetricLabels(" workflow, step ,")
+	if err != nil || strings.Join(names, ",") != "workflow,step" {
+		t.Fatalf("parseMetricLabels() = %v, %v", names, err)
+	}
+	for _, spec := range []string{"a-b", "1a", "__name", "a,a"} {
+		if _, err := parseMetricLabels(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	got := formatLabels(map[string]string{"workflow": "nightly", "step": "3", "odd key\n": "x\ny"})
+	if want := `"odd key\n"="x\ny" step="3" workflow="nightly"`; got != want {
+		t.Errorf("formatLabels() = %s, want %s", got, want)
+	}
+	if got := formatLabels(nil); got != "" {
+		t.Errorf("expected no labels to format empty, got %q", got)
+	}
+}
+
+func TestMetricLabels(t *testing.T) {
+	m, err := newEvalMetrics(defaultMetricsPrefix, []string{"workflow"}, 100, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := jseval.JsEvalResultDto{OutputBytes: 1}
+	m.observe("1", map[string]string{"workflow": "a", "request": "unique-1"}, result)
+	m.observe("1", map[string]string{"workflow": "a", "request": "unique-2"}, result)
+	m.observe("1", nil, result)
+
+	// Only the allowlisted label makes series: workflow="a" and workflow="".
+	if got := testutil.CollectAndCount(m.inputBytes); got != 2 {
+		t.Errorf("expected 2 series, got %d", got)
+	}
+}
+
+func TestMetricLabelValues(t *testing.T) {
+	m, err := newEvalMetrics(defaultMetricsPrefix, []string{"workflow"}, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := jseval.JsEvalResultDto{OutputBytes: 1}
+	for _, workflow := range []string{"a", "b", "c", "d", "a", ""} {
+		m.observe("1", map[string]string{"workflow": workflow}, result)
+	}
+
+	// a, b, other for c and d, and the empty value.
+	if got := testutil.CollectAndCount(m.inputBytes); got != 4 {
+		t.Errorf("expected 4 series, got %d", got)
+	}
+	if got := m.labelValues.value("workflow", "d"); got != otherLabelValue {
+		t.Errorf("value beyond the limit = %q, want %q", got, otherLabelValue)
+	}
+}
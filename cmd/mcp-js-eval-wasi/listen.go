@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+)
+
+// unixPrefix marks a -listen address as the path of a Unix domain socket.
+const unixPrefix = "unix:"
+
+// socketMode restricts a Unix socket to the user running the server.
+const socketMode = 0o600
+
+// listen opens the listener of a -listen address: "unix:/path/to.sock" for a
+// Unix domain socket, anything else a TCP address such as ":8080".
+//
+// A socket file left behind by a previous run is replaced; any other file at
+// the path is kept and fails the listen. The socket file is removed when the
+// listener is closed on shutdown.
+func listen(address string) (net.Listener, error) {
+	path, isUnix := strings.CutPrefix(address, unixPrefix)
+	if !isUnix {
+		return net.Listen("tcp", address)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("%q has no socket path", address)
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode().Type() == fs.ModeSocket {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove the stale socket: %w", err)
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, socketMode); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to restrict the socket permissions: %w", err)
+	}
+	return listener, nil
+}
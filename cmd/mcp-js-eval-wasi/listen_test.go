@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "eval.sock")
+
+	// A socket left behind by a crashed run is replaced.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("failed to create a stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	listener, err := listen(unixPrefix + path)
+	if err != nil {
+		t.Fatalf("listen() returned an unexpected error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected the socket file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != socketMode {
+		t.Errorf("expected permissions %o, got %o", socketMode, perm)
+	}
+
+	if err := listener.Close(); err != nil {
+		t.Fatalf("failed to close the listener: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the socket file to be removed on close, got %v", err)
+	}
+}
+
+func TestListenUnixKeepsRegularFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "not-a-socket")
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if listener, err := listen(unixPrefix + path); err == nil {
+		_ = listener.Close()
+		t.Fatal("expected listening over a regular file to fail")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected the regular file to be kept: %v", err)
+	}
+}
@@ -2,26 +2,36 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/google/jsonschema-go/jsonschema"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
 )
 
 const (
-	defaultPort         = 12040
-	readTimeoutSeconds  = 10
-	writeTimeoutSeconds = 10
-	maxHeaderExponent   = 20
-	maxBodyBytes        = 1 * 1024 * 1024 // 1 MiB
-	wasmPageSizeKiB     = 64
-	kiBytesInMiByte     = 1024
-	wasmPagesInMiB      = kiBytesInMiByte / wasmPageSizeKiB
+	defaultPort        = 12040
+	readTimeoutSeconds = 10
+	maxHeaderExponent  = 20
+	maxBodyBytes       = 1 * 1024 * 1024 // 1 MiB
+	wasmPageSizeKiB    = 64
+	kiBytesInMiByte    = 1024
+	wasmPagesInMiB     = kiBytesInMiByte / wasmPageSizeKiB
+	bytesInMiB         = 1024 * 1024
+	maxWasmPages       = 65536 // 4 GiB, the most 32-bit linear memory can address
 )
 
 var (
@@ -31,77 +41,651 @@ var (
 		os.ExpandEnv("${HOME}/.cargo/bin/js-eval-boa.wasm"),
 		"path to the WASM JavaScript engine",
 	)
-	mem         = flag.Uint("mem", 64, "WASM memory limit in MiB")
-	timeout     = flag.Uint("timeout", 100, "WASM execution timeout in milliseconds")
+	mem          = flag.Uint("mem", 64, "WASM memory limit in MiB")
+	maxCode      = flag.Int("max-code-bytes", 0, "maximum code size in bytes fed to the engine (0: only the HTTP body limit)")
+	totalMem     = flag.Uint("total-mem-mib", 0, "memory budget in MiB shared by all running evaluations (0: unlimited)")
+	timeout      = flag.Uint("timeout", 100, "WASM execution timeout in milliseconds")
+	cpuTimeout   = flag.Uint("cpu-timeout", 0, "WASM execution CPU time limit in milliseconds (0: off, linux only)")
+	writeTimeout = flag.Uint(
+		"write-timeout",
+		10000,
+		"HTTP response write timeout in milliseconds; must be larger than -timeout",
+	)
+	transpilerPath = flag.String(
+		"path2transpiler",
+		"",
+		"path to a WASI TypeScript-to-JavaScript transpiler enabling language \"ts\" (stdin: TS, stdout: JS)",
+	)
 	maxWasmSize = flag.Uint("max-wasm-size", 16, "Maximum WASM file size in MiB")
+	metricsOn   = flag.Bool("metrics", false, "expose Prometheus metrics on /metrics")
+	denyPattern = flag.String(
+		"deny-pattern",
+		"",
+		"reject code matching this regexp (best-effort guardrail, not a security boundary)",
+	)
+	lockThread   = flag.Bool("lock-os-thread", false, "pin each evaluation to a single OS thread (slower)")
+	workers      = flag.Int("workers", 1, "number of independent WASM runtimes to dispatch evaluations to")
+	probe        = flag.Bool("probe", false, "probe the engine for language features at startup")
+	streamLogs   = flag.Bool("stream-logs", false, "forward engine output lines as MCP progress notifications")
+	timestamp    = flag.Bool("timestamp", false, "stamp each result with the time of the evaluation")
+	showLimits   = flag.Bool("applied-limits", false, "include the limits each evaluation ran under in its result")
+	renameFields = flag.String(
+		"field-names",
+		"",
+		"rename result envelope keys, e.g. result=value,error=err,error.code=status; error. scopes a key to the error, plain keys are the envelope (the evaluated value is untouched)",
+	)
+	prettyText    = flag.Bool("pretty-text", false, "also return the result as pretty-printed JSON text content")
+	contentBlocks = flag.Bool(
+		"content-blocks",
+		false,
+		"return the captured logs and the timing as extra text content next to the result",
+	)
+	engineProtocol = flag.Int(
+		"engine-protocol",
+		jseval.EngineProtocolV1,
+		"protocol version the engine speaks (1: code on stdin, JSON on stdout; 2: JSON envelopes)",
+	)
+	transcriptOn  = flag.Bool("transcript", false, "also return a notebook-like text transcript of the code, its logs and its value")
+	maxConcurrent = flag.Int("max-concurrent", 0, "maximum evaluations running at once (0: unlimited)")
+	maxQueue      = flag.Int(
+		"max-queue",
+		-1,
+		"maximum evaluations waiting for -max-concurrent, beyond which they are rejected as busy (-1: unbounded)",
+	)
+	parseErrorDetails   = flag.Bool("parse-error-details", false, "add the exit code and a stderr excerpt to errors for non-JSON output")
+	wasiVersion         = flag.String("wasi-version", "auto", "WASI version of the engine: auto (detect), preview1 or preview2")
+	keepANSI            = flag.Bool("keep-ansi", false, "keep terminal escape sequences of the engine stderr in error messages")
+	engineMode          = flag.String("engine-mode", "compiler", "how wazero runs the engine: compiler or interpreter")
+	tenantQuota         = flag.Int64("tenant-quota", 0, "evaluations allowed per tenant and -tenant-quota-window, an advisory limit as clients name their own tenant (0: unlimited)")
+	quotaWindow         = flag.Duration("tenant-quota-window", 24*time.Hour, "length of the -tenant-quota reset window, e.g. 24h or 720h")
+	quotaMaxTenants     = flag.Int("tenant-quota-max-tenants", 10000, "tenants counted apart per -tenant-quota-window; further tenants share one count")
+	tenantHeader        = flag.String("tenant-header", "X-Tenant-ID", "HTTP header identifying the tenant for -tenant-quota; unauthenticated, so the quota is advisory: clients can change it to get around the quota")
+	diagnostics         = flag.String("diagnostics", "", "parse error locations from the engine stderr in this format: boa (empty: off)")
+	requestDeadline     = flag.Uint("request-deadline", 0, "deadline in milliseconds for the whole request, queuing included (0: only -timeout)")
+	gzipOn              = flag.Bool("gzip", false, "gzip compress MCP responses for clients accepting it")
+	gzipMinBytes        = flag.Int("gzip-min-bytes", 1024, "smallest response in bytes compressed with -gzip")
+	outputDir           = flag.String("output-dir", "", "stream engine stdout to files in this directory, returning a reference served on /outputs/{ref}")
+	outputTTL           = flag.Duration("output-ttl", 10*time.Minute, "how long -output-dir outputs are kept")
+	echoCode            = flag.Bool("echo-code", false, "include the evaluated code, truncated, in each result")
+	codeHash            = flag.Bool("code-hash", false, "include the SHA-256 of the evaluated code in each result")
+	listenAddr          = flag.String("listen", "", "address to listen on: :PORT for TCP or unix:/path/to.sock for a Unix domain socket; overrides -port")
+	resultSchema        = flag.String("result-schema", "", "path of a JSON Schema every result must match")
+	lazyLoad            = flag.Bool("lazy-load", false, "load and compile the engine on the first evaluation instead of at startup")
+	metricLabels        = flag.String("metric-labels", "", "comma separated evaluation label keys to add as metric labels, e.g. workflow,step")
+	metricLabelValues   = flag.Int("metric-label-values", 100, "distinct values recorded per -metric-labels key; later values are recorded as \"other\"")
+	maxStackDepth       = flag.Int("max-stack-depth", 0, "call depth limit passed to engines which support one (0: engine default)")
+	stackDepthFlag      = flag.String("stack-depth-flag", "--max-stack", "engine argument -max-stack-depth is passed as, in the form FLAG=N")
+	evalSSE             = flag.Bool("eval-sse", false, "serve /eval-sse, streaming the logs and the result of an evaluation as server-sent events")
+	maxParallelCompiles = flag.Int("max-parallel-compiles", 1, "modules compiled at once across workers, the transpiler and reloads (0: no limit)")
+	pagesUsed           = flag.Bool("pages-used", false, "report the memory pages each evaluation used, to tune -mem")
+	failOnStderr        = flag.Bool("fail-on-stderr", false, "report successful evaluations which wrote to stderr as errors")
+	metricsPrefix       = flag.String("metrics-prefix", defaultMetricsPrefix, "prefix of all metric names")
+	moduleTypes         = flag.String("module-types", jseval.ModuleTypeScript, "comma separated module types the engine supports: script, module")
+	preloadDir          = flag.String("preload-dir", "", "directory whose files are mounted read-only into every evaluation, read at startup and on reload")
+	preloadMount        = flag.String("preload-mount", "/lib", "path the -preload-dir files are mounted at")
+	breakerFailures     = flag.Int("breaker-failures", 0, "consecutive engine failures opening the circuit breaker (0: disabled)")
+	breakerWindow       = flag.Duration("breaker-window", time.Minute, "time within which -breaker-failures must occur")
+	breakerCooldown     = flag.Duration("breaker-cooldown", 30*time.Second, "time the open circuit breaker refuses evaluations before probing the engine")
+	rawOutputEncoding   = flag.String("raw-output-encoding", jseval.ResultEncodingUTF8, "encoding of -raw-output results: utf8, base64, or auto for base64 only when stdout is not valid UTF-8")
+	selftest            = flag.Bool("selftest", false, "load the engine, run a battery of snippets against it, print a pass/fail report and exit, nonzero on failure")
+	mcpPath             = flag.String("mcp-path", "/", "HTTP path the MCP endpoint is served at; a trailing slash serves the whole subtree")
+	poolMin             = flag.Int("pool-min", 1, "runtimes the elastic pool keeps warm, with -pool-max")
+	poolMax             = flag.Int("pool-max", 0, "run an elastic pool of up to this many runtimes, one evaluation each, instead of -workers (0: disabled)")
+	poolIdleTimeout     = flag.Duration("pool-idle-timeout", 5*time.Minute, "close pooled runtimes beyond -pool-min unused for this long (0: never)")
+	sniffContentType    = flag.Bool("sniff-content-type", false, "report the detected media type of -raw-output results, unless the input declares a contentType")
+	compileTimeout      = flag.Duration("compile-timeout", 5*time.Minute, "fail loading the engine when compiling it takes longer (0: no limit)")
+	queueWait           = flag.Bool("queue-wait", false, "report how long each evaluation waited for a -max-concurrent slot")
+	reuseBuffers        = flag.Bool("reuse-buffers", false, "reuse the output buffers of evaluations to reduce allocations of large outputs")
+	timeoutMode         = flag.String("timeout-mode", "kill", "what a -timeout does to a running evaluation: kill it, or soft: let it finish within -soft-timeout-grace")
+	softTimeoutGrace    = flag.Duration("soft-timeout-grace", time.Second, "how long -timeout-mode soft lets an evaluation run past its timeout before killing it")
+	unescapedHTML       = flag.Bool("unescaped-html", false, "keep <, > and & of results as they are in JSON text instead of escaping them as \\u003c, \\u003e and \\u0026")
+	errorText           = flag.Bool("error-text", false, "also return the error message and the engine stderr as text content when an evaluation fails")
+	jsonLinesFallback   = flag.Bool("json-lines-fallback", false, "parse engine output which is not a single JSON value as JSON lines, returning the array of values")
+	debugErrorBuffer    = flag.Int("debug-error-buffer", 0, "keep the last N evaluation errors for GET /debug/errors, which requires -auth-token (0: disabled)")
+	requireObjectResult = flag.Bool("require-object-result", false, "fail evaluations whose result is valid JSON but not an object")
+	mapEval             = flag.Bool("map-eval", false, "add the map-eval tool, running the same code once per item of its inputs")
+	mapEvalMaxInputs    = flag.Int("map-eval-max-inputs", 100, "maximum number of inputs of one map-eval call")
+	deterministic       = flag.Bool("deterministic", false, "make the engine randomness (Math.random, crypto.getRandomValues) reproducible from -random-seed or the seed input; not for security sensitive code")
+	randomSeed          = flag.Uint64("random-seed", 0, "the seed of the randomness with -deterministic")
+	stdoutBufferSize    = flag.Int("stdout-buffer-size", jseval.DefaultStdoutBufferSize, "initial capacity in bytes of the stdout buffer of each evaluation")
+	maxOutputLines      = flag.Int("max-output-lines", 0, "stop evaluations writing more than this many lines to stdout (0: unlimited)")
+	instanceName        = flag.String("name", "", "name of this instance, prefixed to the evaluation log lines and added to the metrics as the evaluator label")
+	maxResultElements   = flag.Int("max-result-elements", 0, "reject results with more array elements and object members in total (0: unlimited)")
+	rejectDuplicateKeys = flag.Bool("reject-duplicate-keys", false, "reject results with an object repeating a member name, at any depth")
+	executorWorkers     = flag.Int("executor-workers", 0, "run evaluations on this many worker goroutines, recovering their panics, instead of the goroutine of each request (0: disabled)")
+	corsOriginList      = flag.String("cors-origins", "", "comma-separated origins browsers may call the endpoints from, or * for any (empty: no CORS)")
+	flushGrace          = flag.Duration("flush-grace", 0, "keep collecting stdout after the engine returns until it was quiet for this long, for engines writing from goroutines of their own (0: read at once)")
+	authToken           = flag.String("auth-token", "", "bearer token for the admin endpoints such as /drain (empty: disabled)")
+	authTokenFile       = flag.String("auth-token-file", "", "read the -auth-token from this file, keeping it out of process listings; "+authTokenEnv+" is used when neither is set")
+	rawOutput           = flag.Bool("raw-output", false, "return the engine stdout as a plain string result without parsing it as JSON")
 )
 
 func main() {
 	flag.Parse()
 
+	switch *timeoutMode {
+	case "kill":
+	case "soft":
+		if *softTimeoutGrace <= 0 {
+			log.Fatalf("-soft-timeout-grace must be positive, got %v", *softTimeoutGrace)
+		}
+	default:
+		log.Fatalf("invalid -timeout-mode: %q (want kill or soft)", *timeoutMode)
+	}
+
+	// The write deadline starts when the request headers are read, so it has to
+	// cover the whole evaluation, grace included, plus the serialization of the
+	// result.
+	evalBudget := time.Duration(*timeout) * time.Millisecond
+	if *timeoutMode == "soft" {
+		evalBudget += *softTimeoutGrace
+	}
+	if time.Duration(*writeTimeout)*time.Millisecond <= evalBudget {
+		log.Fatalf("-write-timeout (%d ms) must be larger than -timeout plus any -soft-timeout-grace (%v)", *writeTimeout, evalBudget)
+	}
+
+	switch *wasiVersion {
+	case "auto", jseval.WASIPreview1:
+	case jseval.WASIPreview2:
+		log.Fatalf("-wasi-version %s: %v", *wasiVersion, jseval.ErrWASIPreview2Unsupported)
+	default:
+		log.Fatalf("invalid -wasi-version %q: must be auto, preview1 or preview2", *wasiVersion)
+	}
+
+	memoryLimitPages, err := memoryPages(*mem)
+	if err != nil {
+		log.Fatalf("invalid -mem: %v", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	wasmBinary, err := jseval.LoadWasmBinary(*enginePath, *maxWasmSize)
-	if err != nil {
-		log.Fatalf("failed to load WASM binary: %v", err)
+	if *workers < 1 {
+		log.Fatalf("-workers must be at least 1, got %d", *workers)
+	}
+
+	evalOpts := []jseval.Option{
+		jseval.WithCompileTimeout(*compileTimeout),
+		jseval.WithStdoutBufferSize(*stdoutBufferSize),
+	}
+	if *denyPattern != "" {
+		pattern, err := regexp.Compile(*denyPattern)
+		if err != nil {
+			log.Fatalf("invalid -deny-pattern: %v", err)
+		}
+		evalOpts = append(evalOpts, jseval.WithCodePolicy(jseval.DenyPattern(pattern)))
+	}
+	if *resultSchema != "" {
+		encoded, err := os.ReadFile(*resultSchema)
+		if err != nil {
+			log.Fatalf("failed to read -result-schema: %v", err)
+		}
+		var schema jsonschema.Schema
+		if err := json.Unmarshal(encoded, &schema); err != nil {
+			log.Fatalf("invalid -result-schema: %v", err)
+		}
+		evalOpts = append(evalOpts, jseval.WithResultSchema(&schema))
+	}
+
+	if *cpuTimeout > 0 {
+		evalOpts = append(evalOpts, jseval.WithCPUTimeLimit(time.Duration(*cpuTimeout)*time.Millisecond))
+	}
+
+	if *timeoutMode == "soft" {
+		evalOpts = append(evalOpts, jseval.WithSoftTimeout(*softTimeoutGrace))
+	}
+
+	if *timestamp {
+		evalOpts = append(evalOpts, jseval.WithTimestamp())
+	}
+	supportedModuleTypes := strings.Split(*moduleTypes, ",")
+	for _, moduleType := range supportedModuleTypes {
+		if err := jseval.ValidateModuleType(moduleType); err != nil || moduleType == "" {
+			log.Fatalf("invalid -module-types: %q", *moduleTypes)
+		}
+	}
+	evalOpts = append(evalOpts, jseval.WithModuleTypes(supportedModuleTypes...))
+	if *failOnStderr {
+		evalOpts = append(evalOpts, jseval.WithFailOnStderr())
+	}
+	if *pagesUsed {
+		evalOpts = append(evalOpts, jseval.WithPagesUsed())
+	}
+	if *echoCode {
+		evalOpts = append(evalOpts, jseval.WithEchoCode())
+	}
+	if *codeHash {
+		evalOpts = append(evalOpts, jseval.WithCodeHash())
+	}
+
+	if *maxCode > 0 {
+		evalOpts = append(evalOpts, jseval.WithMaxStdinBytes(*maxCode))
+	}
+
+	if *totalMem > 0 {
+		budget := jseval.NewMemoryBudget(uint64(*totalMem) * bytesInMiB)
+		evalOpts = append(evalOpts, jseval.WithMemoryBudget(budget))
 	}
 
-	memoryLimitPages := uint32(*mem) * wasmPagesInMiB
-	evaluator, cleanup, err := jseval.NewEvaluator(ctx, wasmBinary, memoryLimitPages)
+	if *maxConcurrent > 0 {
+		queue := *maxQueue
+		if queue < 0 {
+			queue = math.MaxInt
+		}
+		evalOpts = append(evalOpts, jseval.WithConcurrencyLimit(jseval.NewConcurrencyLimit(*maxConcurrent, queue)))
+		if *queueWait {
+			evalOpts = append(evalOpts, jseval.WithQueueWait())
+		}
+	}
+
+	if *showLimits {
+		evalOpts = append(evalOpts, jseval.WithAppliedLimits())
+	}
+
+	if *reuseBuffers {
+		evalOpts = append(evalOpts, jseval.WithReusedBuffers())
+	}
+
+	if *jsonLinesFallback {
+		evalOpts = append(evalOpts, jseval.WithJSONLinesFallback())
+	}
+
+	if *requireObjectResult {
+		evalOpts = append(evalOpts, jseval.WithRequireObjectResult())
+	}
+
+	if *deterministic {
+		evalOpts = append(evalOpts, jseval.WithDeterministic(*randomSeed))
+	}
+
+	if *maxResultElements > 0 {
+		evalOpts = append(evalOpts, jseval.WithMaxResultElements(*maxResultElements))
+	}
+
+	if *maxOutputLines > 0 {
+		evalOpts = append(evalOpts, jseval.WithMaxOutputLines(*maxOutputLines))
+	}
+
+	if *instanceName != "" {
+		evalOpts = append(evalOpts, jseval.WithName(*instanceName))
+	}
+
+	if *rejectDuplicateKeys {
+		evalOpts = append(evalOpts, jseval.WithRejectDuplicateKeys())
+	}
+	if *flushGrace > 0 {
+		evalOpts = append(evalOpts, jseval.WithFlushGrace(*flushGrace))
+	}
+
+	if *unescapedHTML {
+		evalOpts = append(evalOpts, jseval.WithUnescapedHTML())
+	}
+
+	if *rawOutput {
+		evalOpts = append(evalOpts, jseval.WithTextResult(), jseval.WithResultEncoding(*rawOutputEncoding))
+		if *sniffContentType {
+			evalOpts = append(evalOpts, jseval.WithContentTypeSniffing())
+		}
+	}
+
+	if *parseErrorDetails {
+		evalOpts = append(evalOpts, jseval.WithParseErrorDetails())
+	}
+
+	switch *diagnostics {
+	case "":
+	case "boa":
+		evalOpts = append(evalOpts, jseval.WithDiagnosticParser(jseval.BoaDiagnostics))
+	default:
+		log.Fatalf("invalid -diagnostics %q: must be boa or empty", *diagnostics)
+	}
+
+	var outputs *jseval.TempFileStore
+	if *outputDir != "" {
+		outputs, err = jseval.NewTempFileStore(*outputDir, *outputTTL)
+		if err != nil {
+			log.Fatalf("invalid -output-dir: %v", err)
+		}
+		evalOpts = append(evalOpts, jseval.WithOutputStore(outputs))
+	}
+
+	if *keepANSI {
+		evalOpts = append(evalOpts, jseval.WithKeepANSI())
+	}
+
+	switch *engineMode {
+	case "compiler":
+	case "interpreter":
+		evalOpts = append(evalOpts, jseval.WithInterpreter())
+	default:
+		log.Fatalf("invalid -engine-mode %q: must be compiler or interpreter", *engineMode)
+	}
+
+	if *engineProtocol != jseval.EngineProtocolV1 {
+		evalOpts = append(evalOpts, jseval.WithEngineProtocol(*engineProtocol))
+	}
+
+	if *lockThread {
+		evalOpts = append(evalOpts, jseval.WithLockOSThread())
+	}
+
+	if *preloadDir != "" && (!strings.HasPrefix(*preloadMount, "/") || *preloadMount == "/") {
+		log.Fatalf("-preload-mount must be an absolute path other than /, got %q", *preloadMount)
+	}
+	if err := validateMCPPath(*mcpPath); err != nil {
+		log.Fatalf("invalid -mcp-path: %v", err)
+	}
+	if *poolMax > 0 && (*poolMin < 0 || *poolMin > *poolMax) {
+		log.Fatalf("-pool-min must be between 0 and -pool-max (%d), got %d", *poolMax, *poolMin)
+	}
+	token, err := resolveAuthToken(*authToken, *authTokenFile, os.Getenv(authTokenEnv))
+	if err != nil {
+		log.Fatalf("invalid auth token: %v", err)
+	}
+	*authToken = token
+	cors, err := parseCORSOrigins(*corsOriginList)
 	if err != nil {
-		log.Fatalf("failed to create WASI JavaScript evaluator: %v", err)
+		log.Fatalf("invalid -cors-origins: %v", err)
 	}
-	defer func() {
-		if err := cleanup(); err != nil {
-			log.Printf("failed to cleanup WASI evaluator: %v", err)
+	compiles = newCompileLimiter(*maxParallelCompiles)
+
+	var metrics *evalMetrics
+	if *metricsOn {
+		labelNames, err := parseMetricLabels(*metricLabels)
+		if err != nil {
+			log.Fatalf("invalid -metric-labels: %v", err)
+		}
+		if *metricLabelValues <= 0 {
+			log.Fatalf("-metric-label-values must be positive, got %d", *metricLabelValues)
+		}
+		if *instanceName != "" && slices.Contains(labelNames, "evaluator") {
+			log.Fatalf("invalid -metric-labels: evaluator is the label of -name")
+		}
+		if metrics, err = newEvalMetrics(*metricsPrefix, labelNames, *metricLabelValues, instanceLabels()); err != nil {
+			log.Fatalf("invalid -metrics-prefix: %v", err)
+		}
+	}
+	poolMetrics = metrics
+	if metrics != nil && *maxConcurrent > 0 {
+		evalOpts = append(evalOpts, jseval.WithOnQueueWait(metrics.observeQueueWait))
+	}
+
+	if *selftest {
+		if err := selftestEngine(ctx, *enginePath, evalOpts, os.Stdout); err != nil {
+			log.Fatalf("selftest failed: %v", err)
+		}
+		return
+	}
+	live := &liveEngine{}
+	if *lazyLoad {
+		live.lazy = func() (*engine, error) { return loadEngine(ctx, *enginePath, evalOpts) }
+	} else {
+		loaded, err := loadEngine(ctx, *enginePath, evalOpts)
+		if err != nil {
+			log.Fatalf("failed to load the engine: %v", err)
+		}
+		live.current = loaded
+	}
+	defer live.close()
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	go func() {
+		for range hangups {
+			err := live.reload(ctx, func(ctx context.Context) (*engine, error) {
+				return loadEngine(ctx, *enginePath, evalOpts)
+			})
+			if err != nil {
+				log.Printf("%v", err)
+			}
 		}
 	}()
 
+	transpilers := map[string]jseval.Transpiler{}
+	if *transpilerPath != "" {
+		transpilerBinary, err := jseval.LoadWasmBinary(*transpilerPath, *maxWasmSize)
+		if err != nil {
+			log.Fatalf("failed to load the transpiler: %v", err)
+		}
+		var transpile jseval.Transpiler
+		var cleanupTranspiler func() error
+		err = compiles.run("transpiler "+*transpilerPath, func() error {
+			var err error
+			transpile, cleanupTranspiler, err = jseval.NewWasmTranspiler(ctx, transpilerBinary, memoryLimitPages)
+			return err
+		})
+		if err != nil {
+			log.Fatalf("failed to create the transpiler: %v", err)
+		}
+		defer func() {
+			if err := cleanupTranspiler(); err != nil {
+				log.Printf("failed to cleanup the transpiler: %v", err)
+			}
+		}()
+		transpilers[jseval.LanguageTypeScript] = transpile
+	}
+
+	fieldNames, err := jseval.ParseFieldNames(*renameFields)
+	if err != nil {
+		log.Fatalf("invalid -field-names: %v", err)
+	}
+
+	var quotas quotaStore
+	if *tenantQuota > 0 {
+		if *quotaMaxTenants <= 0 {
+			log.Fatalf("-tenant-quota-max-tenants must be positive, got %d", *quotaMaxTenants)
+		}
+		quotas = newWindowQuota(*tenantQuota, *quotaWindow, *quotaMaxTenants)
+	}
+
 	server := mcp.NewServer(&mcp.Implementation{
 		Name:    "js-eval",
-		Version: "v0.1.0",
+		Version: serverVersion,
 		Title:   "JavaScript Evaluator",
 	}, nil)
 
-	mcp.AddTool(server, &mcp.Tool{
-		Name:         "eval-js",
-		Title:        "Evaluate JavaScript",
-		Description:  "Tool to evaluate JavaScript code, provided as a raw string inside an object.",
-		InputSchema:  nil,
-		OutputSchema: nil,
-	}, func(toolCtx context.Context, req *mcp.CallToolRequest, input jseval.JsEvalToolInput) (
+	aborts := &abortRegistry{}
+	var recentErrors *errorLog
+	if *debugErrorBuffer > 0 {
+		if *authToken == "" {
+			log.Fatalf("-debug-error-buffer requires -auth-token to protect /debug/errors")
+		}
+		recentErrors = newErrorLog(*debugErrorBuffer)
+	}
+	var breaker *circuitBreaker
+	if *breakerFailures > 0 {
+		breaker = newCircuitBreaker(*breakerFailures, *breakerWindow, *breakerCooldown, metrics.setBreakerState)
+	}
+	var evaluations *executor
+	if *executorWorkers > 0 {
+		evaluations = newExecutor(*executorWorkers)
+		defer evaluations.close()
+	}
+	evaluateLive := func(ctx context.Context, code string) jseval.JsEvalResultDto {
+		return evaluations.evaluate(ctx, code, live.evaluate)
+	}
+	evaluate := func(toolCtx context.Context, req *mcp.CallToolRequest, input jseval.JsEvalToolInput, sinks ...jseval.LogSink) jseval.JsEvalResultDto {
+		if quotaErr := checkQuota(toolCtx, quotas, req); quotaErr != nil {
+			log.Printf("Evaluation rejected: %s", quotaErr.Message)
+			return jseval.JsEvalResultDto{Error: quotaErr}
+		}
+
+		requestCtx, cancelRequest := boundToRequest(toolCtx)
+		defer cancelRequest()
+
+		abortCtx, untrack := aborts.track(requestCtx)
+		defer untrack()
+
+		deadlineLimit := time.Duration(*requestDeadline) * time.Millisecond
+		deadlineCtx, cancelDeadline := withRequestDeadline(abortCtx, deadlineLimit)
+		defer cancelDeadline()
+
+		timeoutCtx, cancelTimeout := context.WithTimeout(deadlineCtx, time.Duration(*timeout)*time.Millisecond)
+		defer cancelTimeout()
+
+		evalCtx := jseval.ContextWithFiles(timeoutCtx, input.Files)
+		if input.ModuleType != "" {
+			evalCtx = jseval.ContextWithModuleType(evalCtx, input.ModuleType)
+		}
+		evalCtx = jseval.ContextWithContentType(evalCtx, input.ContentType)
+		if input.Seed != nil {
+			evalCtx = jseval.ContextWithSeed(evalCtx, *input.Seed)
+		}
+		evalCtx = withLogSinks(evalCtx, sinks...)
+
+		var result jseval.JsEvalResultDto
+		code, transpileErr := jseval.TranspileInput(timeoutCtx, input, transpilers)
+		if transpileErr != nil {
+			result = jseval.JsEvalResultDto{Error: transpileErr}
+		} else {
+			result = breaker.evaluate(evalCtx, code, evaluateLive)
+		}
+		result = requestDeadlineResult(deadlineCtx, deadlineLimit, result)
+		result = abortedResult(abortCtx, result)
+		metrics.observe(input.Code, input.Labels, result)
+		recentErrors.record(input.Code, result)
+		if labels := formatLabels(input.Labels); labels != "" {
+			outcome := "ok"
+			if result.Error != nil {
+				outcome = fmt.Sprintf("error %d", result.Error.Code)
+			}
+			log.Printf("Evaluated %s: %s", labels, outcome)
+		}
+		if result.Error != nil {
+			log.Printf("Error evaluating JavaScript: %v", result.Error.Message)
+		}
+		return result
+	}
+
+	evalJs := func(toolCtx context.Context, req *mcp.CallToolRequest, input jseval.JsEvalToolInput) (
 		*mcp.CallToolResult,
 		jseval.JsEvalResultDto,
 		error,
 	) {
-		timeoutCtx, cancelTimeout := context.WithTimeout(toolCtx, time.Duration(*timeout)*time.Millisecond)
-		defer cancelTimeout()
+		if err := validateInput(input); err != nil {
+			return nil, jseval.JsEvalResultDto{}, err
+		}
 
-		result := evaluator(timeoutCtx, input.Code)
-		if result.Error != nil {
-			log.Printf("Error evaluating JavaScript: %v", result.Error.Message)
+		var sinks []jseval.LogSink
+		if *streamLogs {
+			sinks = append(sinks, progressSink(toolCtx, req))
+		}
+		logs := &logCollector{}
+		if *contentBlocks || *transcriptOn || *errorText {
+			sinks = append(sinks, logs.sink)
+		}
+		startedAt := time.Now()
+		result := evaluate(toolCtx, req, input, sinks...)
+
+		var blocks []mcp.Content
+		if *errorText && result.Error != nil {
+			blocks = append(blocks, errorBlock(logs, result))
+		}
+		if *contentBlocks {
+			blocks = append(blocks, detailBlocks(logs, time.Since(startedAt), result)...)
+		}
+		if *transcriptOn {
+			blocks = append(blocks, &mcp.TextContent{Text: transcript(input.Code, logs.lines, result)})
+		}
+		if len(blocks) > 0 {
+			return &mcp.CallToolResult{Content: blocks}, result, nil
 		}
 		return nil, result, nil
-	})
+	}
+	registerEvalTool(server, evalJs, fieldNames)
+	if *mapEval {
+		registerMapEval(server, evaluate, fieldNames, *mapEvalMaxInputs, time.Duration(*writeTimeout)*time.Millisecond-evalBudget)
+	}
+	registerEngineInfo(server, live.info)
+	registerDiscovery(server)
+	registerCapabilities(server, newServerCapabilities(supportedModuleTypes))
 
 	address := fmt.Sprintf(":%d", *port)
+	if *listenAddr != "" {
+		address = *listenAddr
+	}
 	mcpHandler := mcp.NewStreamableHTTPHandler(
 		func(req *http.Request) *mcp.Server { return server },
 		&mcp.StreamableHTTPOptions{Stateless: true},
 	)
 
+	mux := http.NewServeMux()
+	// handle mounts an auxiliary endpoint, refusing methods it does not serve
+	// before the token is checked, so CORS preflights need none.
+	handle := func(pattern string, handler http.Handler) {
+		mux.Handle(pattern, allowMethods(cors, endpointMethods[pattern], handler))
+	}
+	var rootHandler http.Handler = limitBody(maxBodyBytes, mcpHandler)
+	if *gzipOn {
+		rootHandler = withGzip(*gzipMinBytes, rootHandler)
+	}
+	mux.Handle(*mcpPath, allowMethods(cors, mcpMethods, withRequestContext(rootHandler)))
+	if metrics != nil {
+		handle("/metrics", metrics.handler())
+	}
+	if outputs != nil {
+		var outputsRoute http.Handler = outputsHandler(outputs)
+		if *authToken != "" {
+			outputsRoute = requireToken(*authToken, outputsRoute)
+		}
+		handle("/outputs/{ref}", outputsRoute)
+	}
+	if *evalSSE {
+		handle("/eval-sse", withRequestContext(limitBody(maxBodyBytes, sseHandler(evaluate, fieldNames))))
+	}
+	drain := &drainState{}
+	handle("/healthz", http.HandlerFunc(drain.healthz))
+	if *authToken != "" {
+		handle("/drain", requireToken(*authToken, http.HandlerFunc(drain.drain)))
+		handle("/abort", requireToken(*authToken, http.HandlerFunc(aborts.abort)))
+		if recentErrors != nil {
+			handle("/debug/errors", requireToken(*authToken, http.HandlerFunc(recentErrors.handler)))
+		}
+	}
+
 	httpServer := &http.Server{
-		Addr:           address,
-		Handler:        http.MaxBytesHandler(mcpHandler, maxBodyBytes),
+		Handler:        withJSONErrors(mux),
 		ReadTimeout:    readTimeoutSeconds * time.Second,
-		WriteTimeout:   writeTimeoutSeconds * time.Second,
+		WriteTimeout:   time.Duration(*writeTimeout) * time.Millisecond,
 		MaxHeaderBytes: 1 << maxHeaderExponent,
 	}
 
-	log.Printf("Ready to start HTTP MCP server. Listening on %s\n", address)
-	err = httpServer.ListenAndServe()
+	logStartupSummary(address, live.info())
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		stops := make(chan os.Signal, 1)
+		signal.Notify(stops, syscall.SIGTERM, syscall.SIGINT)
+		<-stops
+		log.Printf("Shutting down: waiting for running evaluations")
+		shutdownCtx, cancelShutdown := context.WithTimeout(ctx, time.Duration(*writeTimeout)*time.Millisecond)
+		defer cancelShutdown()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("failed to shut down gracefully: %v", err)
+		}
+	}()
+
+	listener, err := listen(address)
 	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", address, err)
+	}
+	log.Printf("Ready to start HTTP MCP server. Listening on %s\n", address)
+	err = httpServer.Serve(listener)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("Failed to listen and serve: %v", err)
 	}
+	<-shutdownDone
+}
+
+// memoryPages converts a memory limit in MiB to WASM pages, rejecting limits
+// which are zero or beyond what 32-bit linear memory can address rather than
+// letting the page count wrap around.
+func memoryPages(mib uint) (uint32, error) {
+	if mib == 0 {
+		return 0, errors.New("memory limit must be at least 1 MiB")
+	}
+	if mib > maxWasmPages/wasmPagesInMiB {
+		return 0, fmt.Errorf("memory limit of %d MiB exceeds the WASM maximum of %d MiB", mib, maxWasmPages/wasmPagesInMiB)
+	}
+	return uint32(mib) * wasmPagesInMiB, nil
 }
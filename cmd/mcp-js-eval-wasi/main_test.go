@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestMemoryPages(t *testing.T) {
+	tests := []struct {
+		mib     uint
+		want    uint32
+		wantErr bool
+	}{
+		{mib: 0, wantErr: true},
+		{mib: 1, want: 16},
+		{mib: 64, want: 1024},
+		{mib: 4096, want: maxWasmPages},
+		{mib: 4097, wantErr: true},
+		{mib: 300000, wantErr: true},    // far beyond the 4 GiB limit
+		{mib: 1<<28 + 1, wantErr: true}, // would wrap to 16 pages
+	}
+	for _, tt := range tests {
+		got, err := memoryPages(tt.mib)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("memoryPages(%d) = %d, want an error", tt.mib, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("memoryPages(%d) = %d, %v, want %d", tt.mib, got, err, tt.want)
+		}
+	}
+}
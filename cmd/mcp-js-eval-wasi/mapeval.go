@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// mapEvalInput is the input of the map-eval tool.
+type mapEvalInput struct {
+	Code       string `json:"code"`
+	Language   string `json:"language,omitempty"`
+	ModuleType string `json:"moduleType,omitempty"`
+
+	// Inputs are the items the code runs over, each available to it as the
+	// constant input.
+	Inputs []any `json:"inputs"`
+}
+
+// mapEvalOutput holds one result per input, in order, each as eval-js
+// returns it.
+type mapEvalOutput struct {
+	Results []any `json:"results"`
+}
+
+// withInput prepends the declaration of input to code. The declaration does
+// not change the completion value, so the value of the code is still its
+// result; error positions shift by one line.
+func withInput(code string, input any) (string, error) {
+	encoded, err := json.Marshal(input)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("const input = (%s);\n%s", encoded, code), nil
+}
+
+// mapEvaluate runs the code once per input with evaluate, so each item gets
+// its own timeout, quota check and error. Items are run one after another on
+// the already compiled engine; the items left once the response could no
+// longer be written within budget, i.e. -write-timeout less one evaluation,
+// are not run and fail with a request deadline error.
+func mapEvaluate(ctx context.Context, req *mcp.CallToolRequest, input mapEvalInput, evaluate evaluateFunc, budget time.Duration) []jseval.JsEvalResultDto {
+	startedAt := time.Now()
+	results := make([]jseval.JsEvalResultDto, len(input.Inputs))
+	for i, item := range input.Inputs {
+		if time.Since(startedAt) >= budget {
+			results[i] = jseval.JsEvalResultDto{Error: &jseval.ErrorDto{
+				Code:    errorCodeRequestDeadline,
+				Message: fmt.Sprintf("not run: the map-eval time budget of %v is used up", budget),
+				Details: map[string]any{"deadline_ms": budget.Milliseconds()},
+			}}
+			continue
+		}
+		code, err := withInput(input.Code, item)
+		if err != nil {
+			results[i] = jseval.JsEvalResultDto{Error: &jseval.ErrorDto{Code: -1, Message: fmt.Sprintf("failed to encode input %d: %v", i, err)}}
+			continue
+		}
+		results[i] = evaluate(ctx, req, jseval.JsEvalToolInput{
+			Code:       code,
+			Language:   input.Language,
+			ModuleType: input.ModuleType,
+		})
+	}
+	return results
+}
+
+// registerMapEval adds the map-eval tool, accepting up to maxInputs inputs
+// per call. With field names configured each result is re-keyed as eval-js
+// results are.
+func registerMapEval(server *mcp.Server, evaluate evaluateFunc, names jseval.FieldNames, maxInputs int, budget time.Duration) {
+	mcp.AddTool(server, &mcp.Tool{
+		Name:  "map-eval",
+		Title: "Evaluate JavaScript per Input",
+		Description: "Runs the same JavaScript code once for each item of inputs, available to the code as the constant `input`, " +
+			"and returns the results in order, each like a result of eval-js. Items fail independently.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input mapEvalInput) (*mcp.CallToolResult, mapEvalOutput, error) {
+		if err := validateInput(jseval.JsEvalToolInput{Code: input.Code, Language: input.Language, ModuleType: input.ModuleType}); err != nil {
+			return nil, mapEvalOutput{}, err
+		}
+		if len(input.Inputs) > maxInputs {
+			return nil, mapEvalOutput{}, jsonrpcError(codeInvalidParams, fmt.Sprintf("invalid params: %d inputs exceed the limit of %d", len(input.Inputs), maxInputs))
+		}
+
+		results := mapEvaluate(ctx, req, input, evaluate, budget)
+		output := mapEvalOutput{Results: make([]any, len(results))}
+		for i, result := range results {
+			if len(names) == 0 {
+				output.Results[i] = result
+				continue
+			}
+			renamed, err := names.Rename(result)
+			if err != nil {
+				return nil, mapEvalOutput{}, err
+			}
+			output.Results[i] = renamed
+		}
+		return nil, output, nil
+	})
+}
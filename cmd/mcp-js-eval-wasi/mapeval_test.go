@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// inputEvaluate returns the code it was given, failing code which declares
+// the input "fail".
+func inputEvaluate(_ context.Context, _ *mcp.CallToolRequest, input jseval.JsEvalToolInput, _ ...jseval.LogSink) jseval.JsEvalResultDto {
+	if strings.HasPrefix(input.Code, `const input = ("fail");`) {
+		return jseval.JsEvalResultDto{Error: &jseval.ErrorDto{Code: 1, Message: "Uncaught fail"}}
+	}
+	return jseval.JsEvalResultDto{Result: input.Code}
+}
+
+func TestMapEvaluate(t *testing.T) {
+	input := mapEvalInput{Code: "input.n * 2", Inputs: []any{map[string]any{"n": 1}, "fail", nil}}
+	results := mapEvaluate(context.Background(), nil, input, inputEvaluate, time.Minute)
+	if len(results) != 3 {
+		t.Fatalf("expected one result per input, got: %+v", results)
+	}
+	if results[0].Result != "const input = ({\"n\":1});\ninput.n * 2" {
+		t.Errorf("unexpected code of the first input: %q", results[0].Result)
+	}
+	if results[1].Error == nil || results[1].Error.Code != 1 {
+		t.Errorf("expected the second input to fail, got: %+v", results[1])
+	}
+	if results[2].Error != nil || results[2].Result != "const input = (null);\ninput.n * 2" {
+		t.Errorf("expected the failure to be isolated, got: %+v", results[2])
+	}
+
+	t.Run("BudgetUsedUp", func(t *testing.T) {
+		slow := func(ctx context.Context, req *mcp.CallToolRequest, input jseval.JsEvalToolInput, sinks ...jseval.LogSink) jseval.JsEvalResultDto {
+			time.Sleep(20 * time.Millisecond)
+			return inputEvaluate(ctx, req, input, sinks...)
+		}
+		results := mapEvaluate(context.Background(), nil, mapEvalInput{Code: "input", Inputs: []any{1, 2, 3}}, slow, 10*time.Millisecond)
+		if results[0].Error != nil {
+			t.Errorf("expected the first input to run, got: %+v", results[0])
+		}
+		for _, result := range results[1:] {
+			if result.Error == nil || result.Error.Code != errorCodeRequestDeadline {
+				t.Errorf("expected the remaining inputs not to run, got: %+v", result)
+			}
+		}
+	})
+}
+
+func TestMapEvalTool(t *testing.T) {
+	server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: serverVersion}, nil)
+	registerMapEval(server, inputEvaluate, nil, 2, time.Minute)
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	ctx := context.Background()
+	serverSession, err := server.Connect(ctx, serverTransport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serverSession.Close()
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "v0.0.1"}, nil).Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	res, err := session.CallTool(ctx, &mcp.CallToolParams{
+		Name:      "map-eval",
+		Arguments: map[string]any{"code": "input", "inputs": []any{1, "fail"}},
+	})
+	if err != nil {
+		t.Fatalf("map-eval failed: %v", err)
+	}
+	encoded, err := json.Marshal(res.StructuredContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"results":[{"outputBytes":0,"result":"const input = (1);\ninput"},{"error":{"code":1,"message":"Uncaught fail"},"outputBytes":0,"result":null}]}`
+	if string(encoded) != want {
+		t.Errorf("unexpected results:\n%s\nwant:\n%s", encoded, want)
+	}
+
+	res, err = session.CallTool(ctx, &mcp.CallToolParams{
+		Name:      "map-eval",
+		Arguments: map[string]any{"code": "input", "inputs": []any{1, 2, 3}},
+	})
+	if err == nil && !res.IsError {
+		t.Errorf("expected more inputs than the limit to be rejected, got: %+v", res)
+	}
+}
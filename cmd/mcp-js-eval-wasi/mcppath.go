@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// auxiliaryPaths are the endpoints served next to MCP, which -mcp-path must
+// not shadow.
+var auxiliaryPaths = []string{"/metrics", "/outputs/", "/eval-sse", "/healthz", "/drain", "/abort", "/debug/errors"}
+
+// validateMCPPath rejects -mcp-path values which are not a plain absolute
+// path or collide with an auxiliary endpoint. The MCP handler is mounted as
+// a ServeMux pattern: the root path catches every request no other endpoint
+// handles, a path ending in a slash its subtree and any other path only
+// itself.
+func validateMCPPath(path string) error {
+	if !strings.HasPrefix(path, "/") || strings.ContainsAny(path, " {}") {
+		return fmt.Errorf("%q is not an absolute path", path)
+	}
+	if path == "/" {
+		return nil
+	}
+	for _, reserved := range auxiliaryPaths {
+		if strings.TrimSuffix(path, "/") == strings.TrimSuffix(reserved, "/") ||
+			strings.HasSuffix(reserved, "/") && strings.HasPrefix(path, reserved) {
+			return fmt.Errorf("%q collides with the %s endpoint", path, reserved)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestValidateMCPPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{path: "/"},
+		{path: "/mcp"},
+		{path: "/mcp/"},
+		{path: "/m"},
+		{path: "mcp", wantErr: true},
+		{path: "", wantErr: true},
+		{path: "POST /mcp", wantErr: true},
+		{path: "/mcp/{id}", wantErr: true},
+		{path: "/healthz", wantErr: true},
+		{path: "/metrics/", wantErr: true},
+		{path: "/outputs/x", wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := validateMCPPath(tt.path); (err != nil) != tt.wantErr {
+			t.Errorf("validateMCPPath(%q) = %v, want error: %v", tt.path, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMCPUnderPrefix(t *testing.T) {
+	server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "v0.0.1"}, nil)
+	registerDiscovery(server)
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", mcp.NewStreamableHTTPHandler(
+		func(*http.Request) *mcp.Server { return server },
+		&mcp.StreamableHTTPOptions{Stateless: true},
+	))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "v0.0.1"}, nil)
+	session, err := client.Connect(context.Background(), &mcp.StreamableClientTransport{Endpoint: ts.URL + "/mcp"}, nil)
+	if err != nil {
+		t.Fatalf("failed to connect under /mcp: %v", err)
+	}
+	defer session.Close()
+	if _, err := session.ListTools(context.Background(), nil); err != nil {
+		t.Errorf("failed to list the tools under /mcp: %v", err)
+	}
+
+	resp, err := http.Post(ts.URL+"/", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status of / = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// mcpMethods are the methods of the MCP endpoint. Being stateless, it has no
+// session to stream server messages over GET.
+var mcpMethods = []string{http.MethodPost, http.MethodDelete}
+
+// endpointMethods are the methods of each auxiliary endpoint, by pattern.
+var endpointMethods = map[string][]string{
+	"/metrics":       {http.MethodGet, http.MethodHead},
+	"/outputs/{ref}": {http.MethodGet, http.MethodHead},
+	"/eval-sse":      {http.MethodGet, http.MethodPost},
+	"/healthz":       {http.MethodGet, http.MethodHead},
+	"/drain":         {http.MethodPost},
+	"/abort":         {http.MethodPost},
+	"/debug/errors":  {http.MethodGet},
+}
+
+// corsRequestHeaders are the request headers browsers may send cross-origin.
+const corsRequestHeaders = "Authorization, Content-Type, Last-Event-ID, Mcp-Protocol-Version, Mcp-Session-Id"
+
+// corsOrigins are the origins browsers may call the endpoints from, "*" for
+// any. Without any, no CORS headers are sent and browsers keep pages of other
+// origins from reading the responses.
+type corsOrigins []string
+
+// parseCORSOrigins parses a comma-separated list of origins such as
+// https://app.example.com, or "*".
+func parseCORSOrigins(s string) (corsOrigins, error) {
+	var origins corsOrigins
+	for origin := range strings.SplitSeq(s, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+		if origin != "*" {
+			u, err := url.Parse(origin)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+				return nil, fmt.Errorf("%q is not an origin such as https://example.com", origin)
+			}
+		}
+		origins = append(origins, origin)
+	}
+	return origins, nil
+}
+
+func (o corsOrigins) allows(origin string) bool {
+	return origin != "" && (slices.Contains(o, "*") || slices.Contains(o, origin))
+}
+
+// allowMethods serves requests with one of methods by next. OPTIONS requests
+// are answered with 204 and the Allow header, and when from an allowed origin
+// as a CORS preflight; other methods are refused with 405 and the Allow
+// header.
+func allowMethods(cors corsOrigins, methods []string, next http.Handler) http.Handler {
+	allow := strings.Join(append(slices.Clip(methods), http.MethodOptions), ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		if len(cors) > 0 {
+			h.Add("Vary", "Origin")
+			if origin := r.Header.Get("Origin"); cors.allows(origin) {
+				h.Set("Access-Control-Allow-Origin", origin)
+				h.Set("Access-Control-Expose-Headers", "Mcp-Session-Id")
+				if r.Method == http.MethodOptions {
+					h.Set("Access-Control-Allow-Methods", allow)
+					h.Set("Access-Control-Allow-Headers", corsRequestHeaders)
+					h.Set("Access-Control-Max-Age", "600")
+				}
+			}
+		}
+		switch {
+		case r.Method == http.MethodOptions:
+			h.Set("Allow", allow)
+			w.WriteHeader(http.StatusNoContent)
+		case !slices.Contains(methods, r.Method):
+			h.Set("Allow", allow)
+			writeJSONError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestAllowMethodsMCP(t *testing.T) {
+	server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: serverVersion}, nil)
+	handler := allowMethods(nil, mcpMethods, mcp.NewStreamableHTTPHandler(
+		func(*http.Request) *mcp.Server { return server },
+		&mcp.StreamableHTTPOptions{Stateless: true},
+	))
+
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodPatch} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, "/", nil))
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s: status = %d, want 405", method, rec.Code)
+		}
+		if allow := rec.Header().Get("Allow"); allow != "POST, DELETE, OPTIONS" {
+			t.Errorf("%s: Allow = %q", method, allow)
+		}
+		if failure := decodeHTTPError(t, rec).Error; failure.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s: unexpected error body: %+v", method, failure)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/", nil))
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Allow") != "POST, DELETE, OPTIONS" {
+		t.Errorf("OPTIONS: status = %d, Allow = %q", rec.Code, rec.Header().Get("Allow"))
+	}
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("expected no CORS headers without -cors-origins")
+	}
+
+	// POST reaches the SDK, which answers the invalid request itself.
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{")))
+	if rec.Code == http.StatusMethodNotAllowed {
+		t.Errorf("POST: status = %d", rec.Code)
+	}
+}
+
+func TestAllowMethodsAuxiliary(t *testing.T) {
+	for _, path := range auxiliaryPaths {
+		found := false
+		for pattern := range endpointMethods {
+			found = found || strings.HasPrefix(pattern, path)
+		}
+		if !found {
+			t.Errorf("%s has no methods", path)
+		}
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	for pattern, methods := range endpointMethods {
+		mux := http.NewServeMux()
+		mux.Handle(pattern, allowMethods(nil, methods, requireToken("secret", ok)))
+		target := strings.Replace(pattern, "{ref}", "0123", 1)
+
+		for _, method := range methods {
+			req := httptest.NewRequest(method, target, nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Errorf("%s %s: status = %d, want 200", method, pattern, rec.Code)
+			}
+		}
+
+		// Methods are checked before the token.
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, target, nil))
+		want := strings.Join(methods, ", ") + ", OPTIONS"
+		if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != want {
+			t.Errorf("PATCH %s: status = %d, Allow = %q, want 405 and %q", pattern, rec.Code, rec.Header().Get("Allow"), want)
+		}
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, target, nil))
+		if rec.Code != http.StatusNoContent || rec.Header().Get("Allow") != want {
+			t.Errorf("OPTIONS %s: status = %d, Allow = %q, want 204 and %q", pattern, rec.Code, rec.Header().Get("Allow"), want)
+		}
+	}
+}
+
+func TestAllowMethodsCORS(t *testing.T) {
+	cors, err := parseCORSOrigins("https://app.example.com, http://localhost:3000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	handler := allowMethods(cors, []string{http.MethodPost}, requireToken("secret", ok))
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/abort", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := preflight("https://app.example.com")
+	h := rec.Header()
+	if rec.Code != http.StatusNoContent || h.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		h.Get("Access-Control-Allow-Methods") != "POST, OPTIONS" || !strings.Contains(h.Get("Access-Control-Allow-Headers"), "Authorization") {
+		t.Errorf("unexpected preflight response: %d %v", rec.Code, h)
+	}
+	if h := preflight("https://evil.example.com").Header(); h.Get("Access-Control-Allow-Origin") != "" || h.Get("Vary") != "Origin" {
+		t.Errorf("expected no CORS grant for another origin: %v", h)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/abort", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "http://localhost:3000" {
+		t.Errorf("unexpected response: %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestParseCORSOrigins(t *testing.T) {
+	if origins, err := parseCORSOrigins(""); err != nil || len(origins) != 0 {
+		t.Errorf("parseCORSOrigins(\"\") = %v, %v", origins, err)
+	}
+	if origins, err := parseCORSOrigins("*"); err != nil || !origins.allows("https://any.example") {
+		t.Errorf("parseCORSOrigins(*) = %v, %v", origins, err)
+	}
+	for _, invalid := range []string{"example.com", "https://example.com/app", "ftp://example.com", "https://"} {
+		if _, err := parseCORSOrigins(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
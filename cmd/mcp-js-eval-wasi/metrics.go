@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// defaultMetricsPrefix starts every metric name unless -metrics-prefix is set.
+const defaultMetricsPrefix = "jseval_"
+
+// metricsPrefixPattern matches prefixes which keep metric names valid.
+var metricsPrefixPattern = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)?$`)
+
+// evalMetrics holds the Prometheus collectors for evaluations.
+// A nil *evalMetrics records nothing.
+type evalMetrics struct {
+	registry    *prometheus.Registry
+	labelNames  []string
+	labelValues *labelValues
+	inputBytes  *prometheus.HistogramVec
+	outputBytes *prometheus.HistogramVec
+	breaker     prometheus.Gauge
+	poolSize    prometheus.Gauge
+	poolWait    prometheus.Histogram
+	queueWait   prometheus.Histogram
+}
+
+// newEvalMetrics creates the collectors, named with prefix and labeled with
+// the evaluation labels of labelNames. Only allowlisted labels become metric
+// labels, with at most maxLabelValues values each, bounding the cardinality
+// clients can cause. constLabels, such as the -name of the instance, are
+// added to every metric.
+func newEvalMetrics(prefix string, labelNames []string, maxLabelValues int, constLabels prometheus.Labels) (*evalMetrics, error) {
+	if !metricsPrefixPattern.MatchString(prefix) {
+		return nil, fmt.Errorf("%q is not a valid metric name prefix", prefix)
+	}
+	byteBuckets := prometheus.ExponentialBuckets(64, 4, 10) // 64 B .. 16 MiB
+	m := &evalMetrics{
+		registry:    prometheus.NewRegistry(),
+		labelNames:  labelNames,
+		labelValues: newLabelValues(maxLabelValues),
+		inputBytes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    prefix + "input_bytes",
+			Help:    "Size of the evaluated JavaScript code in bytes.",
+			Buckets: byteBuckets,
+		}, labelNames),
+		outputBytes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    prefix + "output_bytes",
+			Help:    "Number of bytes the engine wrote to stdout per evaluation.",
+			Buckets: byteBuckets,
+		}, labelNames),
+	}
+	m.breaker = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: prefix + "breaker_state",
+		Help: "State of the engine circuit breaker: 0 closed, 1 open, 2 half-open.",
+	})
+	m.poolSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: prefix + "pool_size",
+		Help: "Number of runtimes of the elastic pool, idle, in use or being created.",
+	})
+	m.poolWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    prefix + "pool_checkout_wait_seconds",
+		Help:    "Time evaluations waited for a free runtime of the elastic pool.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 8), // 1 ms .. 16 s
+	})
+	m.queueWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    prefix + "queue_wait_seconds",
+		Help:    "Time evaluations waited for a slot of the -max-concurrent limit.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 8), // 1 ms .. 16 s
+	})
+	prometheus.WrapRegistererWith(constLabels, m.registry).MustRegister(m.inputBytes, m.outputBytes, m.breaker, m.poolSize, m.poolWait, m.queueWait)
+	return m, nil
+}
+
+// instanceLabels returns the constant labels of the metrics: evaluator with -name.
+func instanceLabels() prometheus.Labels {
+	if *instanceName == "" {
+		return nil
+	}
+	return prometheus.Labels{"evaluator": *instanceName}
+}
+
+func (m *evalMetrics) observe(code string, labels map[string]string, result jseval.JsEvalResultDto) {
+	if m == nil {
+		return
+	}
+	values := make([]string, len(m.labelNames))
+	for i, name := range m.labelNames {
+		values[i] = m.labelValues.value(name, labels[name])
+	}
+	m.inputBytes.WithLabelValues(values...).Observe(float64(len(code)))
+	m.outputBytes.WithLabelValues(values...).Observe(float64(result.OutputBytes))
+}
+
+func (m *evalMetrics) setBreakerState(state int) {
+	if m == nil {
+		return
+	}
+	m.breaker.Set(float64(state))
+}
+
+func (m *evalMetrics) setPoolSize(size int) {
+	if m == nil {
+		return
+	}
+	m.poolSize.Set(float64(size))
+}
+
+func (m *evalMetrics) observePoolWait(wait time.Duration) {
+	if m == nil {
+		return
+	}
+	m.poolWait.Observe(wait.Seconds())
+}
+
+func (m *evalMetrics) observeQueueWait(wait time.Duration) {
+	if m == nil {
+		return
+	}
+	m.queueWait.Observe(wait.Seconds())
+}
+
+func (m *evalMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
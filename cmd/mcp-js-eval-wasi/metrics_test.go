@@ -0,0 +1,59 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+func TestMetricsPrefix(t *testing.T) {
+	unprefixed := []string{"input_bytes", "output_bytes", "breaker_state", "pool_size", "pool_checkout_wait_seconds", "queue_wait_seconds"}
+	for _, prefix := range []string{defaultMetricsPrefix, "team_a_js_", ""} {
+		m, err := newEvalMetrics(prefix, nil, 100, nil)
+		if err != nil {
+			t.Fatalf("newEvalMetrics(%q) returned an unexpected error: %v", prefix, err)
+		}
+		m.observe("1", nil, jseval.JsEvalResultDto{})
+		families, err := m.registry.Gather()
+		if err != nil {
+			t.Fatalf("failed to gather the metrics: %v", err)
+		}
+		if len(families) == 0 {
+			t.Fatal("expected metrics")
+		}
+		for _, family := range families {
+			if name := family.GetName(); !strings.HasPrefix(name, prefix) || slices.Contains(unprefixed, name) == (prefix != "") {
+				t.Errorf("metric %q lacks the prefix %q", name, prefix)
+			}
+		}
+	}
+
+	if _, err := newEvalMetrics("team-a_", nil, 100, nil); err == nil {
+		t.Error("expected an invalid prefix to be rejected")
+	}
+}
+
+func TestMetricsConstLabels(t *testing.T) {
+	m, err := newEvalMetrics(defaultMetricsPrefix, nil, 100, map[string]string{"evaluator": "boa"})
+	if err != nil {
+		t.Fatalf("newEvalMetrics() returned an unexpected error: %v", err)
+	}
+	m.observe("1", nil, jseval.JsEvalResultDto{})
+	families, err := m.registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather the metrics: %v", err)
+	}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labeled := false
+			for _, label := range metric.GetLabel() {
+				labeled = labeled || label.GetName() == "evaluator" && label.GetValue() == "boa"
+			}
+			if !labeled {
+				t.Errorf("metric %s lacks the evaluator label: %v", family.GetName(), metric.GetLabel())
+			}
+		}
+	}
+}
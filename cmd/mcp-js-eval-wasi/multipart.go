@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// isMultipart reports whether the request body is multipart/form-data, as an
+// HTML form uploading a file sends it.
+func isMultipart(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// multipartInput reads the tool input from a multipart/form-data body: the
+// code from the code field, an uploaded file or a plain value, the language,
+// moduleType and contentType fields as plain values, and every file uploaded
+// as files as one of the Files, keyed by its file name. The body is limited
+// by limitBody, so it is parsed in memory.
+func multipartInput(r *http.Request) (jseval.JsEvalToolInput, int, error) {
+	var input jseval.JsEvalToolInput
+	if err := r.ParseMultipartForm(maxBodyBytes); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return input, http.StatusRequestEntityTooLarge, errors.New("request body too large")
+		}
+		return input, http.StatusBadRequest, fmt.Errorf("invalid multipart input: %w", err)
+	}
+	form := r.MultipartForm
+
+	switch uploads := form.File["code"]; len(uploads) {
+	case 0:
+		input.Code = r.FormValue("code")
+	case 1:
+		code, err := readUpload(uploads[0])
+		if err != nil {
+			return input, http.StatusBadRequest, err
+		}
+		input.Code = code
+	default:
+		return input, http.StatusBadRequest, errors.New("invalid multipart input: more than one code file")
+	}
+	input.Language = r.FormValue("language")
+	input.ModuleType = r.FormValue("moduleType")
+	input.ContentType = r.FormValue("contentType")
+
+	for _, upload := range form.File["files"] {
+		content, err := readUpload(upload)
+		if err != nil {
+			return input, http.StatusBadRequest, err
+		}
+		if input.Files == nil {
+			input.Files = map[string]string{}
+		}
+		input.Files[upload.Filename] = content
+	}
+	return input, 0, nil
+}
+
+func readUpload(upload *multipart.FileHeader) (string, error) {
+	f, err := upload.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the upload %q: %w", upload.Filename, err)
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the upload %q: %w", upload.Filename, err)
+	}
+	return string(content), nil
+}
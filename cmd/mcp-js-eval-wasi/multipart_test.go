@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// multipartRequest builds a POST to /eval-sse with the fields as plain values
+// and the uploads as files, keyed by field name and then file name.
+func multipartRequest(t *testing.T, fields map[string]string, uploads map[string]map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, value := range fields {
+		if err := mw.WriteField(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for field, files := range uploads {
+		for filename, content := range files {
+			fw, err := mw.CreateFormFile(field, filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _ = fw.Write([]byte(content))
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/eval-sse", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestMultipartInput(t *testing.T) {
+	t.Run("CodeFile", func(t *testing.T) {
+		req := multipartRequest(t,
+			map[string]string{"language": "ts", "moduleType": "module"},
+			map[string]map[string]string{
+				"code":  {"main.js": "1 + 1"},
+				"files": {"data.json": `{"a":1}`, "lib.js": "export const b = 2"},
+			})
+		input, _, err := multipartInput(req)
+		if err != nil {
+			t.Fatalf("multipartInput() returned an unexpected error: %v", err)
+		}
+		if input.Code != "1 + 1" || input.Language != "ts" || input.ModuleType != "module" {
+			t.Errorf("unexpected input: %+v", input)
+		}
+		if len(input.Files) != 2 || input.Files["data.json"] != `{"a":1}` || input.Files["lib.js"] != "export const b = 2" {
+			t.Errorf("unexpected files: %v", input.Files)
+		}
+	})
+
+	t.Run("CodeValue", func(t *testing.T) {
+		input, _, err := multipartInput(multipartRequest(t, map[string]string{"code": "2"}, nil))
+		if err != nil || input.Code != "2" || input.Files != nil {
+			t.Errorf("unexpected input %+v, error %v", input, err)
+		}
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := multipartRequest(t, nil, map[string]map[string]string{"code": {"big.js": strings.Repeat("1", maxBodyBytes)}})
+		limitBody(maxBodyBytes, sseHandler(echoEvaluate, nil)).ServeHTTP(rec, req)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected 413, got %d", rec.Code)
+		}
+		decodeHTTPError(t, rec)
+	})
+
+	t.Run("TooLargeUndeclared", func(t *testing.T) {
+		req := multipartRequest(t, nil, map[string]map[string]string{"code": {"big.js": strings.Repeat("1", maxBodyBytes)}})
+		req.ContentLength = -1
+		rec := httptest.NewRecorder()
+		limitBody(maxBodyBytes, sseHandler(echoEvaluate, nil)).ServeHTTP(rec, req)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected 413, got %d", rec.Code)
+		}
+	})
+}
+
+func TestSSEHandlerMultipart(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sseHandler(echoEvaluate, nil).ServeHTTP(rec, multipartRequest(t, nil, map[string]map[string]string{"code": {"main.js": "3"}}))
+	if !strings.Contains(rec.Body.String(), "event: result\ndata: {\"result\":\"3\"") {
+		t.Errorf("unexpected events: %s", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	sseHandler(echoEvaluate, nil).ServeHTTP(rec, multipartRequest(t, map[string]string{"language": "ts"}, nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a form without code to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// outputsHandler serves GET /outputs/{ref}: the stdout an evaluation wrote to
+// the -output-dir store, as referenced by the outputRef of its result.
+func outputsHandler(store *jseval.TempFileStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, err := store.Open(r.PathValue("ref"))
+		if errors.Is(err, jseval.ErrOutputNotFound) {
+			writeJSONError(w, http.StatusNotFound, "output not found or expired")
+			return
+		}
+		if err != nil {
+			log.Printf("failed to open the output: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "failed to open the output")
+			return
+		}
+		defer func() { _ = f.Close() }()
+
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := io.Copy(w, f); err != nil {
+			log.Printf("failed to send the output: %v", err)
+		}
+	})
+}
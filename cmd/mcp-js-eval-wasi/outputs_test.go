@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+func TestOutputsHandler(t *testing.T) {
+	store, err := jseval.NewTempFileStore(t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatalf("NewTempFileStore() returned an unexpected error: %v", err)
+	}
+	out, err := store.Create(context.Background())
+	if err != nil {
+		t.Fatalf("Create() returned an unexpected error: %v", err)
+	}
+	_, _ = io.WriteString(out, `[1,2,3]`)
+	ref, err := out.Commit()
+	if err != nil {
+		t.Fatalf("Commit() returned an unexpected error: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /outputs/{ref}", outputsHandler(store))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/outputs/"+ref, nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != `[1,2,3]` {
+		t.Errorf("unexpected response %d: %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/outputs/0123456789abcdef0123456789abcdef", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected an unknown reference to be not found, got %d", rec.Code)
+	}
+}
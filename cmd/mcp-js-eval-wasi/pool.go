@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// poolMetrics receives the pool size and checkout waits, set in main like
+// compiles. A nil *evalMetrics records nothing.
+var poolMetrics *evalMetrics
+
+// pooledRuntime is one runtime of an elasticPool.
+type pooledRuntime struct {
+	evaluate jseval.Evaluator
+	cleanup  func() error
+	lastUsed time.Time
+}
+
+// elasticPool keeps between min and max runtimes of one engine, each serving
+// one evaluation at a time. Runtimes are compiled when all are busy and
+// evaluations wait once max are, so bursts are served without keeping the
+// memory of max runtimes while idle; runtimes unused for idleTimeout are
+// closed again down to min.
+type elasticPool struct {
+	min         int
+	idleTimeout time.Duration
+	create      func() (jseval.Evaluator, func() error, error)
+	now         func() time.Time
+
+	// slots holds one token per running evaluation, at most max.
+	slots chan struct{}
+
+	mu     sync.Mutex
+	idle   []*pooledRuntime // least recently used first
+	size   int              // idle, in use and being created
+	closed bool
+
+	stop    chan struct{}
+	reaping sync.WaitGroup
+}
+
+// newElasticPool creates min runtimes with create up front.
+func newElasticPool(min, max int, idleTimeout time.Duration, create func() (jseval.Evaluator, func() error, error)) (*elasticPool, error) {
+	p := &elasticPool{
+		min:         min,
+		idleTimeout: idleTimeout,
+		create:      create,
+		now:         time.Now,
+		slots:       make(chan struct{}, max),
+		stop:        make(chan struct{}),
+	}
+	for range min {
+		evaluate, cleanup, err := create()
+		if err != nil {
+			return nil, errors.Join(err, p.close())
+		}
+		p.idle = append(p.idle, &pooledRuntime{evaluate: evaluate, cleanup: cleanup, lastUsed: p.now()})
+		p.size++
+	}
+	poolMetrics.setPoolSize(p.size)
+	if idleTimeout > 0 && max > min {
+		p.reaping.Go(p.reapLoop)
+	}
+	return p, nil
+}
+
+// evaluate runs code on a runtime of its own, waiting for one while max
+// evaluations run.
+func (p *elasticPool) evaluate(ctx context.Context, code string) jseval.JsEvalResultDto {
+	waitStarted := time.Now()
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return jseval.JsEvalResultDto{Error: &jseval.ErrorDto{
+			Code:       jseval.ErrorCodeServerBusy,
+			Message:    fmt.Sprintf("server busy: gave up waiting for a free runtime: %v", context.Cause(ctx)),
+			Terminated: true,
+		}}
+	}
+	poolMetrics.observePoolWait(time.Since(waitStarted))
+
+	rt, err := p.checkout(ctx)
+	if errors.Is(err, errCreateAbandoned) {
+		return jseval.JsEvalResultDto{Error: &jseval.ErrorDto{
+			Code:       jseval.ErrorCodeServerBusy,
+			Message:    fmt.Sprintf("server busy: gave up waiting for a new runtime: %v", context.Cause(ctx)),
+			Terminated: true,
+		}}
+	}
+	defer func() { <-p.slots }()
+	if err != nil {
+		return jseval.JsEvalResultDto{Error: &jseval.ErrorDto{Code: jseval.ErrorCodeEngineFailure, Message: fmt.Sprintf("failed to create a runtime: %v", err)}}
+	}
+	defer p.checkin(rt)
+	return rt.evaluate(ctx, code)
+}
+
+// errCreateAbandoned is returned by checkout when ctx ended while the runtime
+// was being created.
+var errCreateAbandoned = errors.New("gave up waiting for a new runtime")
+
+// checkout takes the most recently used idle runtime, or creates one. The
+// caller holds a slot, so fewer than max runtimes are in use and creating one
+// stays within max.
+//
+// A runtime is created in the background, as compiling may take much longer
+// than the request may wait. When ctx ends first, checkout returns
+// errCreateAbandoned and the creation takes over the slot: it releases it
+// once done, keeping the new runtime idle in the pool for the next request.
+func (p *elasticPool) checkout(ctx context.Context) (*pooledRuntime, error) {
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		rt := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return rt, nil
+	}
+	p.size++
+	poolMetrics.setPoolSize(p.size)
+	p.mu.Unlock()
+
+	created := make(chan error, 1)
+	var rt pooledRuntime
+	go func() {
+		var err error
+		rt.evaluate, rt.cleanup, err = p.create()
+		created <- err
+	}()
+	select {
+	case err := <-created:
+		if err != nil {
+			p.discard()
+			return nil, err
+		}
+		return &rt, nil
+	case <-ctx.Done():
+		go func() {
+			defer func() { <-p.slots }()
+			if err := <-created; err != nil {
+				log.Printf("failed to create a pooled runtime: %v", err)
+				p.discard()
+				return
+			}
+			p.checkin(&rt)
+		}()
+		return nil, errCreateAbandoned
+	}
+}
+
+// discard forgets a runtime which failed to be created.
+func (p *elasticPool) discard() {
+	p.mu.Lock()
+	p.size--
+	poolMetrics.setPoolSize(p.size)
+	p.mu.Unlock()
+}
+
+func (p *elasticPool) checkin(rt *pooledRuntime) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		if err := closeRuntimes([]*pooledRuntime{rt}); err != nil {
+			log.Printf("failed to close a pooled runtime: %v", err)
+		}
+		return
+	}
+	rt.lastUsed = p.now()
+	p.idle = append(p.idle, rt)
+	p.mu.Unlock()
+}
+
+func (p *elasticPool) reapLoop() {
+	ticker := time.NewTicker(p.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			if err := closeRuntimes(p.reap()); err != nil {
+				log.Printf("failed to close an idle runtime: %v", err)
+			}
+		}
+	}
+}
+
+// reap removes the runtimes idle for idleTimeout, keeping min runtimes.
+func (p *elasticPool) reap() []*pooledRuntime {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	expired := 0
+	for expired < len(p.idle) && p.size-expired > p.min && p.now().Sub(p.idle[expired].lastUsed) >= p.idleTimeout {
+		expired++
+	}
+	if expired == 0 {
+		return nil
+	}
+	reaped := p.idle[:expired:expired]
+	p.idle = p.idle[expired:]
+	p.size -= expired
+	poolMetrics.setPoolSize(p.size)
+	return reaped
+}
+
+// close releases the idle runtimes; runtimes still in use are released when
+// their evaluation ends.
+func (p *elasticPool) close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	close(p.stop)
+	p.reaping.Wait()
+	return closeRuntimes(idle)
+}
+
+func closeRuntimes(runtimes []*pooledRuntime) error {
+	var errs []error
+	for _, rt := range runtimes {
+		if err := rt.cleanup(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
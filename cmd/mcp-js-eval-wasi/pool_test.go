@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// countingRuntimes creates runtimes which block their evaluations until
+// release is closed, counting the runtimes alive. With compiling set, creating
+// runtimes blocks until it is closed.
+type countingRuntimes struct {
+	alive     atomic.Int32
+	created   atomic.Int32
+	release   chan struct{}
+	compiling chan struct{}
+	fail      bool
+}
+
+func (c *countingRuntimes) create() (jseval.Evaluator, func() error, error) {
+	if c.compiling != nil {
+		<-c.compiling
+	}
+	if c.fail {
+		return nil, nil, errors.New("compile failed")
+	}
+	c.alive.Add(1)
+	c.created.Add(1)
+	evaluate := func(ctx context.Context, _ string) jseval.JsEvalResultDto {
+		select {
+		case <-c.release:
+		case <-ctx.Done():
+		}
+		return jseval.JsEvalResultDto{Result: true}
+	}
+	return evaluate, func() error { c.alive.Add(-1); return nil }, nil
+}
+
+func TestElasticPoolScales(t *testing.T) {
+	runtimes := &countingRuntimes{release: make(chan struct{})}
+	pool, err := newElasticPool(1, 3, 0, runtimes.create)
+	if err != nil {
+		t.Fatalf("newElasticPool() returned an unexpected error: %v", err)
+	}
+	if got := runtimes.alive.Load(); got != 1 {
+		t.Fatalf("warm runtimes = %d, want 1", got)
+	}
+
+	var running sync.WaitGroup
+	for range 3 {
+		running.Go(func() { pool.evaluate(context.Background(), "") })
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtimes.alive.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := runtimes.alive.Load(); got != 3 {
+		t.Fatalf("runtimes under load = %d, want 3", got)
+	}
+
+	// A fourth evaluation waits for a runtime and gives up with its context.
+	waitCtx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if result := pool.evaluate(waitCtx, ""); result.Error == nil || result.Error.Code != jseval.ErrorCodeServerBusy {
+		t.Errorf("expected a busy error beyond -pool-max, got: %+v", result.Error)
+	}
+
+	close(runtimes.release)
+	running.Wait()
+	if got := runtimes.created.Load(); got != 3 {
+		t.Errorf("created runtimes = %d, want 3", got)
+	}
+	if err := pool.close(); err != nil {
+		t.Fatalf("close() returned an unexpected error: %v", err)
+	}
+	if got := runtimes.alive.Load(); got != 0 {
+		t.Errorf("runtimes alive after close = %d, want 0", got)
+	}
+}
+
+func TestElasticPoolReap(t *testing.T) {
+	runtimes := &countingRuntimes{}
+	now := time.Now()
+	// Without an idle timeout no reaper runs, so the test reaps by itself.
+	pool, err := newElasticPool(1, 3, 0, runtimes.create)
+	if err != nil {
+		t.Fatalf("newElasticPool() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = pool.close() }()
+	pool.idleTimeout = time.Minute
+	pool.now = func() time.Time { return now }
+
+	var checkedOut []*pooledRuntime
+	for range 3 {
+		rt, err := pool.checkout(context.Background())
+		if err != nil {
+			t.Fatalf("checkout() returned an unexpected error: %v", err)
+		}
+		checkedOut = append(checkedOut, rt)
+	}
+	for _, rt := range checkedOut {
+		pool.checkin(rt)
+	}
+
+	now = now.Add(30 * time.Second)
+	if reaped := pool.reap(); len(reaped) != 0 {
+		t.Errorf("reaped %d runtimes before the idle timeout", len(reaped))
+	}
+	now = now.Add(30 * time.Second)
+	if err := closeRuntimes(pool.reap()); err != nil {
+		t.Fatalf("failed to close the reaped runtimes: %v", err)
+	}
+	if got := runtimes.alive.Load(); got != 1 {
+		t.Errorf("runtimes after reaping = %d, want the minimum of 1", got)
+	}
+}
+
+func TestElasticPoolCreateFailure(t *testing.T) {
+	runtimes := &countingRuntimes{}
+	pool, err := newElasticPool(0, 1, 0, runtimes.create)
+	if err != nil {
+		t.Fatalf("newElasticPool() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = pool.close() }()
+	runtimes.fail = true
+	if result := pool.evaluate(context.Background(), ""); result.Error == nil || result.Error.Code != jseval.ErrorCodeEngineFailure {
+		t.Errorf("expected an engine failure, got: %+v", result.Error)
+	}
+	if pool.size != 0 {
+		t.Errorf("size after a failed create = %d, want 0", pool.size)
+	}
+
+	if _, err := newElasticPool(2, 2, 0, runtimes.create); err == nil {
+		t.Error("expected newElasticPool to fail when the warm runtimes fail")
+	}
+}
+
+func TestElasticPoolCreateOutlivesRequest(t *testing.T) {
+	runtimes := &countingRuntimes{release: make(chan struct{}), compiling: make(chan struct{})}
+	close(runtimes.release)
+	pool, err := newElasticPool(0, 1, 0, runtimes.create)
+	if err != nil {
+		t.Fatalf("newElasticPool() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = pool.close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan jseval.JsEvalResultDto)
+	go func() { done <- pool.evaluate(ctx, "") }()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if result := <-done; result.Error == nil || result.Error.Code != jseval.ErrorCodeServerBusy {
+		t.Fatalf("expected the request to give up while the runtime compiles, got: %+v", result.Error)
+	}
+
+	// The creation keeps the slot until it is done, then pools the runtime.
+	if len(pool.slots) != 1 {
+		t.Errorf("slots in use while compiling = %d, want 1", len(pool.slots))
+	}
+	close(runtimes.compiling)
+	if result := pool.evaluate(context.Background(), ""); result.Error != nil {
+		t.Fatalf("unexpected error: %+v", result.Error)
+	}
+	if got := runtimes.created.Load(); got != 1 {
+		t.Errorf("runtimes created = %d, want the abandoned one to be reused", got)
+	}
+}
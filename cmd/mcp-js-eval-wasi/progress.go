@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// progressSink forwards each engine output line to the client as a progress
+// notification. Clients opt in by sending a progress token; without one the
+// sink is nil.
+func progressSink(ctx context.Context, req *mcp.CallToolRequest) jseval.LogSink {
+	token := req.Params.GetProgressToken()
+	if token == nil {
+		return nil
+	}
+
+	var sent float64
+	return func(stream, line string) {
+		sent++
+		err := req.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{
+			ProgressToken: token,
+			Progress:      sent,
+			Message:       stream + ": " + line,
+		})
+		if err != nil {
+			log.Printf("failed to send a log line notification: %v", err)
+		}
+	}
+}
+
+// withLogSinks makes the evaluation pass each output line to all non-nil sinks.
+func withLogSinks(ctx context.Context, sinks ...jseval.LogSink) context.Context {
+	var active []jseval.LogSink
+	for _, sink := range sinks {
+		if sink != nil {
+			active = append(active, sink)
+		}
+	}
+	if len(active) == 0 {
+		return ctx
+	}
+	return jseval.ContextWithLogSink(ctx, func(stream, line string) {
+		for _, sink := range active {
+			sink(stream, line)
+		}
+	})
+}
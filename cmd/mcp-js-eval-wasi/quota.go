@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// errorCodeQuotaExceeded is the ErrorDto code of an evaluation rejected
+// because its tenant used up its quota. It continues the jseval error codes.
+const errorCodeQuotaExceeded = -8
+
+// errorCodeQuotaUnavailable is the ErrorDto code of an evaluation rejected
+// because the quota store failed, telling the client to retry rather than to
+// wait for its quota to reset.
+const errorCodeQuotaUnavailable = -24
+
+const anonymousTenant = "anonymous"
+
+// quotaStore counts evaluations per tenant. The in-memory windowQuota is the
+// default; an implementation backed by an external store lets several
+// instances share the counts.
+type quotaStore interface {
+	// consume counts one evaluation of the tenant unless it is over quota. It
+	// returns the evaluations left in the current window and when it resets.
+	consume(ctx context.Context, tenant string) (quotaStatus, error)
+}
+
+type quotaStatus struct {
+	allowed   bool
+	remaining int64
+	resetAt   time.Time
+}
+
+// windowQuota allows limit evaluations per tenant in fixed windows, aligned to
+// multiples of the window length since the zero time in UTC.
+//
+// Only the counts of the current window are kept, and of at most maxTenants
+// tenants: further tenants share the count of overflowTenant, so that clients
+// making up tenants neither grow the counts without bound nor escape the
+// quota.
+type windowQuota struct {
+	limit      int64
+	window     time.Duration
+	maxTenants int
+	now        func() time.Time
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int64
+}
+
+const overflowTenant = "overflow"
+
+func newWindowQuota(limit int64, window time.Duration, maxTenants int) *windowQuota {
+	return &windowQuota{limit: limit, window: window, maxTenants: maxTenants, now: time.Now, counts: map[string]int64{}}
+}
+
+func (q *windowQuota) consume(_ context.Context, tenant string) (quotaStatus, error) {
+	start := q.now().UTC().Truncate(q.window)
+	resetAt := start.Add(q.window)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.start.Equal(start) {
+		// The counts of the previous windows have expired.
+		clear(q.counts)
+		q.start = start
+	}
+	n, ok := q.counts[tenant]
+	if !ok && len(q.counts) >= q.maxTenants {
+		tenant = overflowTenant
+		n = q.counts[tenant]
+	}
+	if n >= q.limit {
+		return quotaStatus{remaining: 0, resetAt: resetAt}, nil
+	}
+	n++
+	q.counts[tenant] = n
+	return quotaStatus{allowed: true, remaining: q.limit - n, resetAt: resetAt}, nil
+}
+
+// tenantOf identifies the tenant of the request by the -tenant-header. Nothing
+// authenticates the header, so a client can claim any tenant, including a new
+// one to start over: the quota keeps cooperating clients in check, it does not
+// stop hostile ones.
+func tenantOf(req *mcp.CallToolRequest) string {
+	if req.Extra == nil || req.Extra.Header == nil {
+		return anonymousTenant
+	}
+	if tenant := req.Extra.Header.Get(*tenantHeader); tenant != "" {
+		return tenant
+	}
+	return anonymousTenant
+}
+
+// checkQuota returns the error result for a request over quota, or nil.
+func checkQuota(ctx context.Context, quotas quotaStore, req *mcp.CallToolRequest) *jseval.ErrorDto {
+	if quotas == nil {
+		return nil
+	}
+	tenant := tenantOf(req)
+	status, err := quotas.consume(ctx, tenant)
+	if err != nil {
+		return &jseval.ErrorDto{Code: errorCodeQuotaUnavailable, Message: fmt.Sprintf("failed to check the quota: %v", err)}
+	}
+	if status.allowed {
+		return nil
+	}
+	return &jseval.ErrorDto{
+		Code: errorCodeQuotaExceeded,
+		Message: fmt.Sprintf(
+			"evaluation quota of tenant %q exceeded: %d remaining, resets at %s",
+			tenant, status.remaining, status.resetAt.Format(time.RFC3339),
+		),
+		Details: map[string]any{
+			"tenant":    tenant,
+			"remaining": status.remaining,
+			"reset_at":  status.resetAt.Format(time.RFC3339),
+		},
+	}
+}
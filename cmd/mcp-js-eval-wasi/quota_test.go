@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestWindowQuota(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	quotas := newWindowQuota(2, 24*time.Hour, 10)
+	quotas.now = func() time.Time { return now }
+
+	for i, wantRemaining := range []int64{1, 0} {
+		status, err := quotas.consume(ctx, "a")
+		if err != nil || !status.allowed || status.remaining != wantRemaining {
+			t.Fatalf("evaluation %d: unexpected status %+v, %v", i, status, err)
+		}
+	}
+	status, _ := quotas.consume(ctx, "a")
+	if status.allowed {
+		t.Fatal("expected the third evaluation to be over quota")
+	}
+	if want := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC); !status.resetAt.Equal(want) {
+		t.Errorf("unexpected reset time %v, want %v", status.resetAt, want)
+	}
+
+	if status, _ := quotas.consume(ctx, "b"); !status.allowed {
+		t.Error("expected another tenant to have its own quota")
+	}
+
+	now = now.Add(14 * time.Hour)
+	if status, _ := quotas.consume(ctx, "a"); !status.allowed || status.remaining != 1 {
+		t.Errorf("expected the quota to reset with the window, got %+v", status)
+	}
+}
+
+func TestWindowQuotaBoundsTenants(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	quotas := newWindowQuota(2, time.Hour, 2)
+	quotas.now = func() time.Time { return now }
+
+	for _, tenant := range []string{"a", "b", "c", "d", "e"} {
+		_, _ = quotas.consume(ctx, tenant)
+	}
+	if len(quotas.counts) != 3 {
+		t.Fatalf("counts = %v, want a and b plus the shared overflow", quotas.counts)
+	}
+	if status, _ := quotas.consume(ctx, "f"); status.allowed {
+		t.Error("expected tenants beyond the limit to share one quota")
+	}
+	if status, _ := quotas.consume(ctx, "a"); !status.allowed {
+		t.Error("expected a tracked tenant to keep its own quota")
+	}
+
+	// Counts of earlier windows are dropped.
+	now = now.Add(time.Hour)
+	_, _ = quotas.consume(ctx, "c")
+	if len(quotas.counts) != 1 || quotas.counts["c"] != 1 {
+		t.Errorf("counts = %v, want only c of the new window", quotas.counts)
+	}
+}
+
+func TestCheckQuota(t *testing.T) {
+	ctx := context.Background()
+	quotas := newWindowQuota(1, time.Hour, 10)
+	header := http.Header{}
+	header.Set(*tenantHeader, "acme")
+	req := &mcp.CallToolRequest{Extra: &mcp.RequestExtra{Header: header}}
+
+	if errDto := checkQuota(ctx, quotas, req); errDto != nil {
+		t.Fatalf("unexpected rejection: %+v", errDto)
+	}
+	errDto := checkQuota(ctx, quotas, req)
+	if errDto == nil || errDto.Code != errorCodeQuotaExceeded || !strings.Contains(errDto.Message, `tenant "acme"`) {
+		t.Errorf("expected a quota error for acme, got: %+v", errDto)
+	}
+	if errDto := checkQuota(ctx, quotas, &mcp.CallToolRequest{}); errDto != nil {
+		t.Errorf("expected the anonymous tenant to have its own quota, got: %+v", errDto)
+	}
+	if errDto := checkQuota(ctx, nil, req); errDto != nil {
+		t.Errorf("expected no quota without a store, got: %+v", errDto)
+	}
+}
+
+type failingQuotaStore struct{}
+
+func (failingQuotaStore) consume(context.Context, string) (quotaStatus, error) {
+	return quotaStatus{}, errors.New("store down")
+}
+
+func TestCheckQuotaStoreFailure(t *testing.T) {
+	errDto := checkQuota(context.Background(), failingQuotaStore{}, &mcp.CallToolRequest{})
+	if errDto == nil || errDto.Code != errorCodeQuotaUnavailable {
+		t.Errorf("expected a store failure apart from an exceeded quota, got: %+v", errDto)
+	}
+}
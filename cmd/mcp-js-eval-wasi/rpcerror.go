@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// Errors are reported in one of three ways:
+//
+//   - Evaluation errors, where the request was fine but running the code failed
+//     (exception, non-success exit, timeout, non-JSON output, code policy), are
+//     returned in JsEvalResultDto.Error of a successful tool call.
+//   - Request errors, where the request itself is broken, are JSON-RPC errors:
+//     malformed or schema-violating arguments are rejected by the SDK with
+//     -32602 before the handler runs, the handler rejects blank code, unknown
+//     languages and module types and invalid file names with -32602 through
+//     validateInput, and bodies above the size limit are refused with HTTP 413
+//     before any JSON-RPC processing.
+//   - HTTP errors outside JSON-RPC (size limit, auth, unknown outputs) have a
+//     JSON body {"error": {"code": <status>, "message": ...}}, see
+//     writeJSONError.
+const codeInvalidParams = -32602
+
+// jsonrpcError returns an error which the SDK sends as a JSON-RPC error
+// response instead of wrapping it in a tool result.
+//
+// The SDK only passes its internal wire error type through, which is not
+// exported, so one is obtained by decoding an error response.
+func jsonrpcError(code int64, message string) error {
+	encoded, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      0,
+		"error":   map[string]any{"code": code, "message": message},
+	})
+	if err != nil {
+		return errors.New(message)
+	}
+	msg, err := jsonrpc.DecodeMessage(encoded)
+	if err != nil {
+		return errors.New(message)
+	}
+	resp, ok := msg.(*jsonrpc.Response)
+	if !ok || resp.Error == nil {
+		return errors.New(message)
+	}
+	return resp.Error
+}
+
+// validateInput returns a request error for input which can not be evaluated.
+func validateInput(input jseval.JsEvalToolInput) error {
+	if strings.TrimSpace(input.Code) == "" {
+		return jsonrpcError(codeInvalidParams, "invalid params: code must not be empty")
+	}
+	switch input.Language {
+	case "", jseval.LanguageJavaScript, jseval.LanguageTypeScript:
+	default:
+		return jsonrpcError(codeInvalidParams, fmt.Sprintf("invalid params: unsupported language %q", input.Language))
+	}
+	if err := jseval.ValidateModuleType(input.ModuleType); err != nil {
+		return jsonrpcError(codeInvalidParams, fmt.Sprintf("invalid params: %v", err))
+	}
+	if err := jseval.ValidateContentType(input.ContentType); err != nil {
+		return jsonrpcError(codeInvalidParams, fmt.Sprintf("invalid params: %v", err))
+	}
+	if err := jseval.ValidateFiles(input.Files); err != nil {
+		return jsonrpcError(codeInvalidParams, fmt.Sprintf("invalid params: %v", err))
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+func TestValidateInput(t *testing.T) {
+	if err := validateInput(jseval.JsEvalToolInput{Code: "1 + 1"}); err != nil {
+		t.Fatalf("validateInput() rejected valid input: %v", err)
+	}
+
+	if err := validateInput(jseval.JsEvalToolInput{Code: "1", Language: "coffee"}); err == nil {
+		t.Fatal("validateInput() accepted an unknown language")
+	}
+
+	if err := validateInput(jseval.JsEvalToolInput{Code: "1", ModuleType: "commonjs"}); err == nil {
+		t.Fatal("validateInput() accepted an unknown module type")
+	}
+
+	if err := validateInput(jseval.JsEvalToolInput{Code: "1", Files: map[string]string{"../x.js": ""}}); err == nil {
+		t.Fatal("validateInput() accepted a file escaping the mount")
+	}
+
+	err := validateInput(jseval.JsEvalToolInput{Code: " \n"})
+	if err == nil {
+		t.Fatal("validateInput() accepted blank code")
+	}
+
+	id, _ := jsonrpc.MakeID(int64(1))
+	encoded, encodeErr := jsonrpc.EncodeMessage(&jsonrpc.Response{ID: id, Error: err})
+	if encodeErr != nil {
+		t.Fatalf("failed to encode the response: %v", encodeErr)
+	}
+	var wire struct {
+		Error struct {
+			Code int64 `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(encoded, &wire); err != nil {
+		t.Fatalf("failed to decode the response: %v", err)
+	}
+	if wire.Error.Code != codeInvalidParams {
+		t.Errorf("unexpected JSON-RPC error code. Got: %d, Want: %d", wire.Error.Code, codeInvalidParams)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// selftestCase is one snippet of -selftest with the check of its result.
+type selftestCase struct {
+	name  string
+	code  string
+	check func(jseval.JsEvalResultDto) error
+}
+
+// wantJSON checks that a snippet succeeds with the result marshaling to want.
+func wantJSON(want string) func(jseval.JsEvalResultDto) error {
+	return func(result jseval.JsEvalResultDto) error {
+		if result.Error != nil {
+			return fmt.Errorf("error %d: %s", result.Error.Code, result.Error.Message)
+		}
+		got, err := json.Marshal(result.Result)
+		if err != nil {
+			return err
+		}
+		if string(got) != want {
+			return fmt.Errorf("got %s, want %s", got, want)
+		}
+		return nil
+	}
+}
+
+// wantError checks that a snippet fails. The error code depends on the
+// engine and the protocol, so any error passes.
+func wantError(result jseval.JsEvalResultDto) error {
+	if result.Error == nil {
+		return fmt.Errorf("got result %v, want an error", result.Result)
+	}
+	return nil
+}
+
+// selftestCases exercise what every deployment relies on: values, errors
+// of the code and stopping code which does not end by itself.
+var selftestCases = []selftestCase{
+	{name: "arithmetic", code: "1 + 2 * 3", check: wantJSON("7")},
+	{name: "strings", code: "'js'.concat('-', 'eval').toUpperCase()", check: wantJSON(`"JS-EVAL"`)},
+	{name: "json", code: "({ list: [1, 'two', null], nested: { ok: true } })", check: wantJSON(`{"list":[1,"two",null],"nested":{"ok":true}}`)},
+	{name: "error", code: "throw new Error('selftest')", check: wantError},
+	{name: "timeout", code: "while (true) {}", check: wantError},
+}
+
+// runSelftest evaluates selftestCases, each within timeout, and writes a
+// PASS or FAIL line per case to w. It fails when any case failed.
+func runSelftest(ctx context.Context, evaluate jseval.Evaluator, timeout time.Duration, w io.Writer) error {
+	failed := 0
+	for _, tc := range selftestCases {
+		// The cases check the engine, whatever shape the options give results.
+		caseCtx, cancel := context.WithTimeout(jseval.ContextWithProbe(ctx), timeout)
+		started := time.Now()
+		err := tc.check(evaluate(caseCtx, tc.code))
+		elapsed := time.Since(started).Round(time.Millisecond)
+		cancel()
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "FAIL %s (%v): %v\n", tc.name, elapsed, err)
+			continue
+		}
+		fmt.Fprintf(w, "PASS %s (%v)\n", tc.name, elapsed)
+	}
+	fmt.Fprintf(w, "%d of %d passed\n", len(selftestCases)-failed, len(selftestCases))
+	if failed > 0 {
+		return errors.New("the engine failed the selftest")
+	}
+	return nil
+}
+
+// selftestEngine loads the engine with the configured options, runs the
+// selftest against it and releases it again.
+func selftestEngine(ctx context.Context, path string, evalOpts []jseval.Option, w io.Writer) error {
+	e, err := loadEngine(ctx, path, evalOpts)
+	if err != nil {
+		return err
+	}
+	defer e.close()
+	return runSelftest(ctx, e.evaluate, time.Duration(*timeout)*time.Millisecond, w)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// scriptedEvaluator answers the selftest snippets with the given results.
+func scriptedEvaluator(results map[string]string) jseval.Evaluator {
+	return func(_ context.Context, code string) jseval.JsEvalResultDto {
+		raw, ok := results[code]
+		if !ok {
+			return jseval.JsEvalResultDto{Error: &jseval.ErrorDto{Code: 1, Message: "Error: selftest"}}
+		}
+		return jseval.JsEvalResultDto{Result: json.RawMessage(raw)}
+	}
+}
+
+func TestRunSelftest(t *testing.T) {
+	working := map[string]string{
+		"1 + 2 * 3":                                          "7",
+		"'js'.concat('-', 'eval').toUpperCase()":             `"JS-EVAL"`,
+		"({ list: [1, 'two', null], nested: { ok: true } })": `{"list":[1,"two",null],"nested":{"ok":true}}`,
+	}
+	var report bytes.Buffer
+	if err := runSelftest(context.Background(), scriptedEvaluator(working), time.Second, &report); err != nil {
+		t.Fatalf("selftest failed: %v\n%s", err, &report)
+	}
+	if !strings.Contains(report.String(), "5 of 5 passed") {
+		t.Errorf("unexpected report:\n%s", &report)
+	}
+
+	broken := map[string]string{"1 + 2 * 3": "6", "while (true) {}": "null"}
+	report.Reset()
+	if err := runSelftest(context.Background(), scriptedEvaluator(broken), time.Second, &report); err == nil {
+		t.Fatalf("selftest passed a broken engine:\n%s", &report)
+	}
+	for _, want := range []string{"FAIL arithmetic", "got 6, want 7", "FAIL strings", "PASS error", "FAIL timeout", "1 of 5 passed"} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("report lacks %q:\n%s", want, &report)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// evaluateFunc runs one evaluation as the eval-js tool does, passing the
+// engine output lines to the sinks.
+type evaluateFunc func(ctx context.Context, req *mcp.CallToolRequest, input jseval.JsEvalToolInput, sinks ...jseval.LogSink) jseval.JsEvalResultDto
+
+// sseLog is the data of a log event.
+type sseLog struct {
+	Stream string `json:"stream"`
+	Line   string `json:"line"`
+}
+
+// sseHandler serves /eval-sse: the code, from the code, language, moduleType
+// and contentType query parameters of a GET as EventSource sends it, or the
+// JSON tool input or a multipart form (see multipartInput) of a POST, is
+// evaluated while each output line is sent as a log event, followed by a
+// single result event with the result as the eval-js tool returns it.
+//
+// Events carry ids so that a reconnecting EventSource sends Last-Event-ID.
+// Such requests are answered with 204, which stops it from reconnecting:
+// an evaluation is never run again nor its events replayed.
+func sseHandler(evaluate evaluateFunc, names jseval.FieldNames) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Last-Event-ID") != "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		input, status, err := sseInput(r)
+		if err != nil {
+			writeJSONError(w, status, err.Error())
+			return
+		}
+
+		h := w.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		h.Set("X-Accel-Buffering", "no")
+		events := &sseWriter{w: w, rc: http.NewResponseController(w)}
+		w.WriteHeader(http.StatusOK)
+		_ = events.rc.Flush()
+
+		req := &mcp.CallToolRequest{Extra: &mcp.RequestExtra{Header: r.Header}}
+		result := evaluate(r.Context(), req, input, func(stream, line string) {
+			events.send("log", sseLog{Stream: stream, Line: line})
+		})
+
+		if len(names) == 0 {
+			events.send("result", result)
+			return
+		}
+		renamed, err := names.Rename(result)
+		if err != nil {
+			log.Printf("failed to rename the result fields: %v", err)
+			return
+		}
+		events.send("result", renamed)
+	})
+}
+
+// sseInput reads the evaluation input of an /eval-sse request, or the status
+// and error to reject it with.
+func sseInput(r *http.Request) (jseval.JsEvalToolInput, int, error) {
+	var input jseval.JsEvalToolInput
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		input.Code = query.Get("code")
+		input.Language = query.Get("language")
+		input.ModuleType = query.Get("moduleType")
+		input.ContentType = query.Get("contentType")
+	case http.MethodPost:
+		if isMultipart(r) {
+			form, status, err := multipartInput(r)
+			if err != nil {
+				return input, status, err
+			}
+			input = form
+		} else if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			return input, http.StatusBadRequest, fmt.Errorf("invalid input: %w", err)
+		}
+	default:
+		return input, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method)
+	}
+	if err := validateInput(input); err != nil {
+		return input, http.StatusBadRequest, err
+	}
+	return input, 0, nil
+}
+
+// sseWriter sends numbered events, flushing each as it is written.
+type sseWriter struct {
+	mu     sync.Mutex
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	nextID int
+}
+
+func (s *sseWriter) send(event string, data any) {
+	encoded, err := encodeJSON(data, false)
+	if err != nil {
+		log.Printf("failed to encode the %s event: %v", event, err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	// Compact JSON is a single line, as an SSE data field must be.
+	if _, err := fmt.Fprintf(s.w, "id: %d\nevent: %s\ndata: %s\n\n", s.nextID, event, encoded); err != nil {
+		return
+	}
+	_ = s.rc.Flush()
+}
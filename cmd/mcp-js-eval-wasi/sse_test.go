@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+// echoEvaluate logs the code on stderr and returns it as the result.
+func echoEvaluate(_ context.Context, _ *mcp.CallToolRequest, input jseval.JsEvalToolInput, sinks ...jseval.LogSink) jseval.JsEvalResultDto {
+	for _, sink := range sinks {
+		sink("stderr", "running "+input.Code)
+	}
+	return jseval.JsEvalResultDto{Result: input.Code}
+}
+
+func TestSSEHandler(t *testing.T) {
+	handler := sseHandler(echoEvaluate, nil)
+
+	t.Run("GET", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/eval-sse?code="+url.QueryEscape("1 + 1"), nil))
+		if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("unexpected content type %q", ct)
+		}
+		want := "id: 1\nevent: log\ndata: {\"stream\":\"stderr\",\"line\":\"running 1 + 1\"}\n\n" +
+			"id: 2\nevent: result\ndata: {\"result\":\"1 + 1\",\"outputBytes\":0}\n\n"
+		if got := rec.Body.String(); got != want {
+			t.Errorf("unexpected events:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("POST", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/eval-sse", strings.NewReader(`{"code":"2"}`)))
+		if !strings.Contains(rec.Body.String(), "event: result\ndata: {\"result\":\"2\"") {
+			t.Errorf("unexpected events: %s", rec.Body)
+		}
+	})
+
+	t.Run("Reconnect", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/eval-sse?code=1", nil)
+		req.Header.Set("Last-Event-ID", "2")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+			t.Errorf("expected a reconnect to end the stream with 204, got %d %q", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, req := range []*http.Request{
+			httptest.NewRequest(http.MethodGet, "/eval-sse", nil),
+			httptest.NewRequest(http.MethodPost, "/eval-sse", strings.NewReader(`{`)),
+			httptest.NewRequest(http.MethodPut, "/eval-sse", nil),
+		} {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code < 400 {
+				t.Errorf("expected %s with %v to be rejected, got %d", req.Method, req.URL, rec.Code)
+				continue
+			}
+			decodeHTTPError(t, rec)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"log"
+	"slices"
+)
+
+// startupSummary is the configuration logged once at startup so operators can
+// confirm the server runs as intended.
+type startupSummary struct {
+	Address      string      `json:"address"`
+	MCPPath      string      `json:"mcpPath"`
+	Transport    string      `json:"transport"`
+	EngineMode   string      `json:"engineMode"`
+	Engine       *engineInfo `json:"engine"`
+	MemoryMiB    uint        `json:"memoryMiB"`
+	TimeoutMs    uint        `json:"timeoutMs"`
+	CPUTimeoutMs uint        `json:"cpuTimeoutMs,omitempty"`
+	Workers      int         `json:"workers"`
+	TypeScript   bool        `json:"typescript"`
+	Features     []string    `json:"features"`
+}
+
+// summaryFlags are reported in fields of their own rather than as features.
+var summaryFlags = []string{"listen", "port", "mcp-path", "engine-mode", "path2engine", "mem", "timeout", "cpu-timeout", "workers", "path2transpiler"}
+
+// featureNames name the features of flags whose names do not say them.
+var featureNames = map[string]string{
+	"auth-token":         "auth",
+	"auth-token-file":    "auth",
+	"breaker-failures":   "circuit-breaker",
+	"pool-max":           "elastic-pool",
+	"timeout-mode":       "soft-timeout",
+	"debug-error-buffer": "debug-errors",
+	"executor-workers":   "executor",
+	"cors-origins":       "cors",
+}
+
+// enabledFeatures lists the optional features switched on, in flag order:
+// every flag set to other than its default, except those switched off, set
+// to zero or empty, or in summaryFlags. It reads the resolved values, so a
+// token taken from JSEVAL_AUTH_TOKEN counts as auth, and a flag added later
+// shows up without being listed here.
+func enabledFeatures() []string {
+	features := []string{}
+	flag.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if value == f.DefValue || value == "false" || value == "0" || value == "" || slices.Contains(summaryFlags, f.Name) {
+			return
+		}
+		if name := cmp.Or(featureNames[f.Name], f.Name); !slices.Contains(features, name) {
+			features = append(features, name)
+		}
+	})
+	return features
+}
+
+// logStartupSummary logs the summary as a single JSON line. The engine
+// identity is the one the engine-info tool reports.
+func logStartupSummary(address string, engine *engineInfo) {
+	encoded, err := json.Marshal(startupSummary{
+		Address:      address,
+		MCPPath:      *mcpPath,
+		Transport:    transportName,
+		EngineMode:   *engineMode,
+		Engine:       engine,
+		MemoryMiB:    *mem,
+		TimeoutMs:    *timeout,
+		CPUTimeoutMs: *cpuTimeout,
+		Workers:      *workers,
+		TypeScript:   *transpilerPath != "",
+		Features:     enabledFeatures(),
+	})
+	if err != nil {
+		log.Printf("failed to encode the startup summary: %v", err)
+		return
+	}
+	log.Printf("Startup summary: %s", encoded)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"slices"
+	"testing"
+)
+
+func TestEnabledFeatures(t *testing.T) {
+	set := map[string]string{
+		"auth-token":     "secret",
+		"max-concurrent": "4",
+		"output-dir":     "/tmp/outputs",
+		"diagnostics":    "boa",
+		"timeout-mode":   "soft",
+		"raw-output":     "false",
+		"timeout":        "500",
+	}
+	for name, value := range set {
+		f := flag.Lookup(name)
+		previous := f.Value.String()
+		if err := f.Value.Set(value); err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = f.Value.Set(previous) }()
+	}
+
+	features := enabledFeatures()
+	for _, want := range []string{"auth", "max-concurrent", "output-dir", "diagnostics", "soft-timeout"} {
+		if !slices.Contains(features, want) {
+			t.Errorf("features %v lack %s", features, want)
+		}
+	}
+	for _, unwanted := range []string{"auth-token", "secret", "raw-output", "timeout"} {
+		if slices.Contains(features, unwanted) {
+			t.Errorf("features %v unexpectedly contain %s", features, unwanted)
+		}
+	}
+}
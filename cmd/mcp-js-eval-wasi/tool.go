@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/takanoriyanagitani/go-mcp-js-eval-wasi/jseval"
+)
+
+type evalHandler = mcp.ToolHandlerFor[jseval.JsEvalToolInput, jseval.JsEvalResultDto]
+
+func evalTool() *mcp.Tool {
+	return &mcp.Tool{
+		Name:         "eval-js",
+		Title:        "Evaluate JavaScript",
+		Description:  "Tool to evaluate JavaScript code, provided as a raw string inside an object.",
+		InputSchema:  nil,
+		OutputSchema: nil,
+	}
+}
+
+// registerEvalTool adds the eval-js tool. With field names configured the
+// structured result is re-keyed, so its output schema is a generic object.
+func registerEvalTool(server *mcp.Server, handler evalHandler, names jseval.FieldNames) {
+	if len(names) == 0 {
+		mcp.AddTool(server, evalTool(), func(ctx context.Context, req *mcp.CallToolRequest, input jseval.JsEvalToolInput) (
+			*mcp.CallToolResult,
+			jseval.JsEvalResultDto,
+			error,
+		) {
+			res, result, err := handler(ctx, req, input)
+			if err == nil {
+				res = withResultContent(res, result)
+			}
+			return res, result, err
+		})
+		return
+	}
+
+	mcp.AddTool(server, evalTool(), func(ctx context.Context, req *mcp.CallToolRequest, input jseval.JsEvalToolInput) (
+		*mcp.CallToolResult,
+		map[string]any,
+		error,
+	) {
+		res, result, err := handler(ctx, req, input)
+		if err != nil {
+			return nil, nil, err
+		}
+		renamed, err := names.Rename(result)
+		if err != nil {
+			return nil, nil, err
+		}
+		return withResultContent(res, renamed), renamed, nil
+	})
+}
@@ -0,0 +1,79 @@
+package jseval
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrorCodeServerBusy is the ErrorDto code of an evaluation rejected because
+// all slots of the concurrency limit are taken and its queue is full, or
+// which gave up waiting for a slot.
+const ErrorCodeServerBusy = -7
+
+var errQueueFull = errors.New("queue full")
+
+// ConcurrencyLimit bounds the evaluations running at once across all
+// evaluators sharing it, and how many may wait for a slot. Evaluations beyond
+// both are rejected immediately instead of piling up.
+// A ConcurrencyLimit is safe for concurrent use.
+type ConcurrencyLimit struct {
+	slots    chan struct{}
+	maxQueue int64
+	queued   atomic.Int64
+}
+
+// NewConcurrencyLimit allows maxConcurrent running and maxQueue waiting evaluations.
+func NewConcurrencyLimit(maxConcurrent, maxQueue int) *ConcurrencyLimit {
+	return &ConcurrencyLimit{slots: make(chan struct{}, maxConcurrent), maxQueue: int64(maxQueue)}
+}
+
+// Running returns the number of evaluations holding a slot.
+func (l *ConcurrencyLimit) Running() int { return len(l.slots) }
+
+// Queued returns the number of evaluations waiting for a slot.
+func (l *ConcurrencyLimit) Queued() int { return int(l.queued.Load()) }
+
+// acquire takes a slot, waiting in the queue while there is room in it.
+func (l *ConcurrencyLimit) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if l.queued.Add(1) > l.maxQueue {
+		l.queued.Add(-1)
+		return errQueueFull
+	}
+	defer l.queued.Add(-1)
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+func (l *ConcurrencyLimit) release() { <-l.slots }
+
+// WithConcurrencyLimit makes evaluations take a slot of the limit for as long
+// as they run. Share one limit across evaluators to bound them together.
+func WithConcurrencyLimit(limit *ConcurrencyLimit) Option {
+	return func(c *config) { c.concurrencyLimit = limit }
+}
+
+// WithQueueWait sets QueueWaitMs of every result to the time the evaluation
+// waited for a slot of the concurrency limit, including evaluations which
+// gave up waiting. Evaluations rejected straight away waited zero.
+func WithQueueWait() Option {
+	return func(c *config) { c.queueWait = true }
+}
+
+// WithOnQueueWait calls f with the time each evaluation waited for a slot of
+// the concurrency limit, e.g. to record it as a metric.
+func WithOnQueueWait(f func(time.Duration)) Option {
+	return func(c *config) { c.onQueueWait = f }
+}
@@ -0,0 +1,27 @@
+package jseval
+
+import "regexp"
+
+// ansiEscape matches CSI sequences such as colors and cursor movement, OSC
+// sequences such as window titles and hyperlinks, and other two-byte escapes.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[0-~]`)
+
+// stripANSI removes terminal escape sequences, which engines emitting
+// colorized errors leave in stderr.
+func stripANSI(s string) string {
+	return ansiEscape.ReplaceAllString(s, "")
+}
+
+// WithKeepANSI keeps terminal escape sequences of stderr in error messages,
+// which are stripped by default.
+func WithKeepANSI() Option {
+	return func(c *config) { c.keepANSI = true }
+}
+
+// stderrText returns the captured stderr for an error message.
+func (c *config) stderrText(stderr string) string {
+	if c.keepANSI {
+		return stderr
+	}
+	return stripANSI(stderr)
+}
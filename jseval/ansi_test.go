@@ -0,0 +1,20 @@
+package jseval
+
+import "testing"
+
+func TestStripANSI(t *testing.T) {
+	colorized := "\x1b[31m\x1b[1mUncaught ReferenceError\x1b[0m: x is not defined\n" +
+		"    at \x1b[36m<eval>\x1b[39m:1:1\x1b[K\n" +
+		"\x1b]8;;https://example.com\x07docs\x1b]8;;\x07\x1b=\n"
+	want := "Uncaught ReferenceError: x is not defined\n    at <eval>:1:1\ndocs\n"
+
+	if got := stripANSI(colorized); got != want {
+		t.Errorf("stripANSI() = %q, want %q", got, want)
+	}
+	if got := newConfig(nil).stderrText(colorized); got != want {
+		t.Errorf("stderrText() = %q, want %q", got, want)
+	}
+	if got := newConfig([]Option{WithKeepANSI()}).stderrText(colorized); got != colorized {
+		t.Errorf("stderrText() WithKeepANSI = %q, want it unchanged", got)
+	}
+}
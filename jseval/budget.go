@@ -0,0 +1,54 @@
+package jseval
+
+import "sync"
+
+// ErrorCodeMemoryCapacity is the ErrorDto code of an evaluation rejected
+// because the memory budget is exhausted.
+const ErrorCodeMemoryCapacity = -4
+
+const wasmPageSize = 65536
+
+// MemoryBudget bounds the linear memory which all live module instances sharing
+// it may commit. Each evaluation reserves the memory limit of its evaluator,
+// the most its instance can grow to, for as long as it runs.
+// A MemoryBudget is safe for concurrent use.
+type MemoryBudget struct {
+	mu    sync.Mutex
+	limit uint64
+	used  uint64
+}
+
+// NewMemoryBudget creates a budget of limitBytes.
+func NewMemoryBudget(limitBytes uint64) *MemoryBudget {
+	return &MemoryBudget{limit: limitBytes}
+}
+
+// Used returns the bytes reserved by running evaluations.
+func (b *MemoryBudget) Used() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+func (b *MemoryBudget) reserve(n uint64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used+n > b.limit {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+func (b *MemoryBudget) release(n uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= n
+}
+
+// WithMemoryBudget makes evaluations reserve their memory limit from the budget,
+// rejecting them when it would be exceeded rather than risking the host.
+// Share one budget across evaluators to bound them together.
+func WithMemoryBudget(budget *MemoryBudget) Option {
+	return func(c *config) { c.memoryBudget = budget }
+}
@@ -0,0 +1,58 @@
+package jseval
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBufferBytes bounds the buffers kept for reuse, so one huge output
+// does not pin its memory for the life of the process.
+const maxPooledBufferBytes = 16 << 20
+
+var outputBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// DefaultStdoutBufferSize is the initial stdout buffer capacity unless set
+// WithStdoutBufferSize: a typical small JSON result fits without growing it.
+const DefaultStdoutBufferSize = 512
+
+// WithStdoutBufferSize sets the initial capacity of the stdout buffer of each
+// evaluation. Outputs up to size bytes are then written without growing the
+// buffer, however many writes the engine takes; larger outputs grow it as
+// usual. 0 allocates on the first write only. Set it to the usual result size
+// of a workload of known-small results; BenchmarkStdoutBufferSize compares
+// the allocations.
+func WithStdoutBufferSize(size int) Option {
+	return func(c *config) { c.stdoutBufferSize = max(size, 0) }
+}
+
+// WithReusedBuffers takes the stdout and stderr buffers of evaluations from a
+// pool shared by all evaluators instead of growing fresh ones, saving the
+// reallocations of repeated large outputs; the more writes an output takes,
+// the more it saves. Results never refer to a reused buffer: with
+// WithRawResult the output is copied once, at its final size, which combined
+// with skipping the value tree makes a large output cost little more than
+// that copy. Buffers beyond 16 MiB are dropped rather than kept.
+func WithReusedBuffers() Option {
+	return func(c *config) { c.reuseBuffers = true }
+}
+
+// outputBuffer returns an empty buffer for the stdout or stderr of a run,
+// with room for at least size bytes.
+func (c *config) outputBuffer(size int) *bytes.Buffer {
+	if !c.reuseBuffers {
+		return bytes.NewBuffer(make([]byte, 0, size))
+	}
+	buf := outputBuffers.Get().(*bytes.Buffer)
+	buf.Grow(size)
+	return buf
+}
+
+// releaseBuffer returns a buffer of outputBuffer once nothing refers to its
+// bytes anymore.
+func (c *config) releaseBuffer(buf *bytes.Buffer) {
+	if !c.reuseBuffers || buf.Cap() > maxPooledBufferBytes {
+		return
+	}
+	buf.Reset()
+	outputBuffers.Put(buf)
+}
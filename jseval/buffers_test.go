@@ -0,0 +1,138 @@
+package jseval
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+)
+
+func TestWithReusedBuffers(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithReusedBuffers()},
+		{WithReusedBuffers(), WithRawResult()},
+		{WithReusedBuffers(), WithTextResult()},
+	} {
+		evaluator := newFixtureEvaluator(t, opts...)
+		ctx := fixtureExitCtx(context.Background(), 0)
+		first := evaluator(ctx, `{"first":[1,2,3]}`)
+		second := evaluator(ctx, `{"second":"overwrites the buffer"}`)
+		if first.Error != nil || second.Error != nil {
+			t.Fatalf("unexpected errors: %+v, %+v", first.Error, second.Error)
+		}
+		// Marshal the earlier result after the buffer was reused.
+		encoded, err := json.Marshal(first.Result)
+		if err != nil {
+			t.Fatalf("failed to marshal the first result: %v", err)
+		}
+		if want := `{"first":[1,2,3]}`; string(encoded) != want && string(encoded) != `"{\"first\":[1,2,3]}"` {
+			t.Errorf("the first result changed to %s after the next evaluation", encoded)
+		}
+	}
+}
+
+// chunkedWriteWasm builds a WASI command module writing chunks to stdout
+// with one fd_write of an iovec per chunk, which the host receives as one
+// write each, like an engine printing a result in pieces.
+func chunkedWriteWasm(chunks ...string) []byte {
+	const (
+		nwrittenOffset = 200
+		dataOffset     = 256
+	)
+
+	types := []byte{2}
+	types = append(types, 0x60, 4, 0x7f, 0x7f, 0x7f, 0x7f, 1, 0x7f) // fd_write
+	types = append(types, 0x60, 0, 0)                               // _start
+
+	imports := []byte{1}
+	imports = append(imports, wasmName("wasi_snapshot_preview1")...)
+	imports = append(imports, wasmName("fd_write")...)
+	imports = append(imports, 0x00, 0)
+
+	exports := []byte{2}
+	exports = append(exports, wasmName("memory")...)
+	exports = append(exports, 0x02, 0)
+	exports = append(exports, wasmName("_start")...)
+	exports = append(exports, 0x00, 1)
+
+	// fd_write(1, iovs=0, iovs_len=len(chunks), nwritten)
+	body := []byte{0}
+	body = append(body, i32Const(1)...)
+	body = append(body, i32Const(0)...)
+	body = append(body, i32Const(int32(len(chunks)))...)
+	body = append(body, i32Const(nwrittenOffset)...)
+	body = append(body, 0x10, 0, 0x1a, 0x0b)
+	code := append([]byte{1}, uleb128(uint32(len(body)))...)
+	code = append(code, body...)
+
+	// The iovecs at 0, followed at dataOffset by the chunks.
+	segment := make([]byte, dataOffset)
+	for i, chunk := range chunks {
+		binary.LittleEndian.PutUint32(segment[8*i:], uint32(len(segment)))
+		binary.LittleEndian.PutUint32(segment[8*i+4:], uint32(len(chunk)))
+		segment = append(segment, chunk...)
+	}
+	data := []byte{1, 0, 0x41, 0, 0x0b}
+	data = append(data, uleb128(uint32(len(segment)))...)
+	data = append(data, segment...)
+
+	wasm := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	wasm = append(wasm, wasmSection(1, types)...)
+	wasm = append(wasm, wasmSection(2, imports)...)
+	wasm = append(wasm, wasmSection(3, []byte{1, 1})...)
+	wasm = append(wasm, wasmSection(5, []byte{1, 0, 1})...)
+	wasm = append(wasm, wasmSection(7, exports)...)
+	wasm = append(wasm, wasmSection(10, code)...)
+	wasm = append(wasm, wasmSection(11, data)...)
+	return wasm
+}
+
+// smallResultChunks is a typical small JSON result, printed in pieces.
+var smallResultChunks = []string{`{"id":42,"name":"widget","tags":["a","b","c"],`, `"price":9.5,"inStock":true,"description":"a small result"}`, "\n"}
+
+func TestWithStdoutBufferSize(t *testing.T) {
+	ctx := context.Background()
+	wasm := chunkedWriteWasm(smallResultChunks...)
+	for _, size := range []int{0, 8, DefaultStdoutBufferSize, -1} {
+		evaluator, cleanup, err := NewEvaluator(ctx, wasm, 1, WithStdoutBufferSize(size))
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+		result := evaluator(ctx, "")
+		_ = cleanup()
+		if result.Error != nil {
+			t.Fatalf("size %d: unexpected error: %+v", size, result.Error)
+		}
+		if m, ok := result.Result.(map[string]any); !ok || m["id"] != 42.0 || m["inStock"] != true {
+			t.Errorf("size %d: unexpected result %v", size, result.Result)
+		}
+	}
+}
+
+func BenchmarkStdoutBufferSize(b *testing.B) {
+	ctx := context.Background()
+	wasm := chunkedWriteWasm(smallResultChunks...)
+
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "Unsized", opts: []Option{WithStdoutBufferSize(0), WithRawResult()}},
+		{name: "Default", opts: []Option{WithRawResult()}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			evaluator, cleanup, err := NewEvaluator(ctx, wasm, 2, bc.opts...)
+			if err != nil {
+				b.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+			}
+			b.Cleanup(func() { _ = cleanup() })
+
+			b.ReportAllocs()
+			for b.Loop() {
+				if result := evaluator(ctx, ""); result.Error != nil {
+					b.Fatalf("evaluator() returned an unexpected error: %+v", result.Error)
+				}
+			}
+		})
+	}
+}
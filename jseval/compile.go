@@ -0,0 +1,57 @@
+package jseval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// ErrCompileTimeout is returned by NewEvaluator when compiling the module
+// exceeds the limit set WithCompileTimeout.
+var ErrCompileTimeout = errors.New("compile timeout exceeded")
+
+// WithCompileTimeout makes NewEvaluator fail with ErrCompileTimeout when
+// compiling the module takes longer than timeout, so a pathological module
+// can not stall startup. Zero, the default, waits as long as it takes.
+func WithCompileTimeout(timeout time.Duration) Option {
+	return func(c *config) { c.compileTimeout = timeout }
+}
+
+// compileModule compiles wasmBinary with r within timeout, if positive.
+//
+// wazero checks its context only between the functions of a parallel
+// compilation, so on timeout the compilation may go on in the background;
+// r is closed once it ends.
+func compileModule(ctx context.Context, r wazero.Runtime, wasmBinary []byte, timeout time.Duration) (wazero.CompiledModule, error) {
+	if timeout <= 0 {
+		return r.CompileModule(ctx, wasmBinary)
+	}
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, ErrCompileTimeout)
+	defer cancel()
+
+	type compileResult struct {
+		compiled wazero.CompiledModule
+		err      error
+	}
+	done := make(chan compileResult, 1)
+	go func() {
+		compiled, err := r.CompileModule(ctx, wasmBinary)
+		done <- compileResult{compiled, err}
+	}()
+	select {
+	case result := <-done:
+		return result.compiled, result.err
+	case <-ctx.Done():
+		go func() {
+			<-done
+			_ = r.Close(context.Background())
+		}()
+		if errors.Is(context.Cause(ctx), ErrCompileTimeout) {
+			return nil, fmt.Errorf("%w: compiling took longer than %v", ErrCompileTimeout, timeout)
+		}
+		return nil, context.Cause(ctx)
+	}
+}
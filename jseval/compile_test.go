@@ -0,0 +1,50 @@
+package jseval
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// manyFunctionsWasm builds a module of n functions with long bodies, which
+// takes a while to compile while running instantly.
+func manyFunctionsWasm(n int) []byte {
+	wasm := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	wasm = append(wasm, wasmSection(1, []byte{1, 0x60, 0, 0})...)
+	functions := uleb128(uint32(n))
+	for range n {
+		functions = append(functions, 0)
+	}
+	wasm = append(wasm, wasmSection(3, functions)...)
+	wasm = append(wasm, wasmSection(7, append(append([]byte{1}, wasmName("_start")...), 0x00, 0))...)
+	body := []byte{0}
+	for range 200 {
+		body = append(body, 0x41, 0x01, 0x1a) // i32.const 1 drop
+	}
+	body = append(body, 0x0b)
+	code := uleb128(uint32(n))
+	for range n {
+		code = append(append(code, uleb128(uint32(len(body)))...), body...)
+	}
+	return append(wasm, wasmSection(10, code)...)
+}
+
+func TestWithCompileTimeout(t *testing.T) {
+	ctx := context.Background()
+	wasm := manyFunctionsWasm(2000)
+
+	_, _, err := NewEvaluator(ctx, wasm, 1, WithCompileTimeout(time.Nanosecond))
+	if !errors.Is(err, ErrCompileTimeout) {
+		t.Errorf("expected ErrCompileTimeout, got: %v", err)
+	}
+
+	evaluator, cleanup, err := NewEvaluator(ctx, wasm, 1, WithCompileTimeout(time.Minute), WithTextResult())
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+	if result := evaluator(ctx, ""); result.Error != nil {
+		t.Errorf("unexpected error: %+v", result.Error)
+	}
+}
@@ -0,0 +1,53 @@
+package jseval
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+type contentTypeKey struct{}
+
+// ContextWithContentType returns a context which declares the media type of
+// the stdout of the evaluations run with it, e.g. "text/csv". It is reported
+// as ContentType of text results instead of a sniffed type, and is ignored
+// for JSON results.
+func ContextWithContentType(ctx context.Context, contentType string) context.Context {
+	if contentType == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, contentTypeKey{}, contentType)
+}
+
+// ValidateContentType rejects declared content types which are not a valid
+// media type. The empty type declares nothing.
+func ValidateContentType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	if _, _, err := mime.ParseMediaType(contentType); err != nil {
+		return fmt.Errorf("invalid content type %q: %w", contentType, err)
+	}
+	return nil
+}
+
+// WithContentTypeSniffing sets ContentType of the results of WithTextResult
+// to the media type of stdout detected by http.DetectContentType, e.g.
+// "text/html; charset=utf-8" or "text/plain; charset=utf-8", unless the
+// evaluation declared one with ContextWithContentType.
+func WithContentTypeSniffing() Option {
+	return func(c *config) { c.sniffContentType = true }
+}
+
+// contentType returns the ContentType of the text result output of an
+// evaluation run with ctx, or the empty string if it has none.
+func (c *config) contentType(ctx context.Context, output []byte) string {
+	if declared, _ := ctx.Value(contentTypeKey{}).(string); declared != "" {
+		return declared
+	}
+	if c.sniffContentType {
+		return http.DetectContentType(output)
+	}
+	return ""
+}
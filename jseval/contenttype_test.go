@@ -0,0 +1,60 @@
+package jseval
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithContentTypeSniffing(t *testing.T) {
+	tests := []struct {
+		name     string
+		stdout   string
+		declared string
+		opts     []Option
+		want     string
+	}{
+		{name: "Text", stdout: "plain words\n", opts: []Option{WithContentTypeSniffing()}, want: "text/plain; charset=utf-8"},
+		{name: "HTML", stdout: "<!DOCTYPE html><html><body>hi</body></html>", opts: []Option{WithContentTypeSniffing()}, want: "text/html; charset=utf-8"},
+		{name: "Declared", stdout: "a,b\n1,2\n", declared: "text/csv", opts: []Option{WithContentTypeSniffing()}, want: "text/csv"},
+		{name: "DeclaredWithoutSniffing", stdout: "a,b\n", declared: "text/csv", want: "text/csv"},
+		{name: "NotSniffing", stdout: "<html></html>", want: ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			evaluator := newFixtureEvaluator(t, append(tc.opts, WithTextResult())...)
+			ctx := ContextWithContentType(fixtureExitCtx(context.Background(), 0), tc.declared)
+			result := evaluator(ctx, tc.stdout)
+			if result.Error != nil {
+				t.Fatalf("unexpected error: %+v", result.Error)
+			}
+			if result.Result != tc.stdout {
+				t.Errorf("result = %#v, want the stdout %q", result.Result, tc.stdout)
+			}
+			if result.ContentType != tc.want {
+				t.Errorf("ContentType = %q, want %q", result.ContentType, tc.want)
+			}
+		})
+	}
+
+	t.Run("JSONResult", func(t *testing.T) {
+		evaluator := newFixtureEvaluator(t, WithContentTypeSniffing())
+		ctx := ContextWithContentType(fixtureExitCtx(context.Background(), 0), "text/csv")
+		result := evaluator(ctx, `{"a":1}`)
+		if result.Error != nil || result.ContentType != "" {
+			t.Errorf("expected a JSON result without ContentType, got: %+v", result)
+		}
+	})
+}
+
+func TestValidateContentType(t *testing.T) {
+	for _, valid := range []string{"", "text/csv", "text/html; charset=utf-8"} {
+		if err := ValidateContentType(valid); err != nil {
+			t.Errorf("ValidateContentType(%q) = %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{"csv/", "text/csv; =x", ";"} {
+		if err := ValidateContentType(invalid); err == nil {
+			t.Errorf("ValidateContentType(%q) accepted an invalid type", invalid)
+		}
+	}
+}
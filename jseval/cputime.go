@@ -0,0 +1,71 @@
+package jseval
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrorCodeCPUTimeExceeded is the ErrorDto code of an evaluation stopped by the CPU time limit.
+const ErrorCodeCPUTimeExceeded = -3
+
+var errCPUTimeExceeded = errors.New("CPU time limit exceeded")
+
+const minCPUPollInterval = time.Millisecond
+
+// WithCPUTimeLimit stops an evaluation once it has consumed the given CPU time.
+//
+// Unlike the wall clock timeout of the context, time spent waiting for a CPU on
+// a busy host is not counted, so fast code is not killed under contention.
+// The limit is checked periodically, so the code may overrun it slightly.
+// Each evaluation is locked to its OS thread while it runs to measure it.
+// Only linux is supported; elsewhere the limit is not applied.
+func WithCPUTimeLimit(limit time.Duration) Option {
+	return func(c *config) { c.cpuTimeLimit = limit }
+}
+
+// watchCPUTime cancels the returned context with errCPUTimeExceeded once the
+// calling OS thread has used the limit of CPU time since the call.
+// The caller must keep the goroutine locked to its thread until stop is called.
+func (c *config) watchCPUTime(ctx context.Context, limit time.Duration) (watched context.Context, stop func()) {
+	clock, err := currentThreadCPUClock()
+	if err != nil {
+		c.logf("warning: CPU time limit not applied: %v", err)
+		return ctx, func() {}
+	}
+	start, err := clock()
+	if err != nil {
+		c.logf("warning: CPU time limit not applied: %v", err)
+		return ctx, func() {}
+	}
+
+	watched, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(max(limit/10, minCPUPollInterval))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-watched.Done():
+				return
+			case <-ticker.C:
+				used, err := clock()
+				if err != nil {
+					c.logf("warning: failed to read CPU time: %v", err)
+					return
+				}
+				if used-start > limit {
+					cancel(errCPUTimeExceeded)
+					return
+				}
+			}
+		}
+	}()
+
+	return watched, func() {
+		close(done)
+		cancel(nil)
+	}
+}
@@ -0,0 +1,36 @@
+package jseval
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// currentThreadCPUClock returns a function reporting the CPU time consumed by
+// the calling OS thread, which must stay locked to the goroutine.
+// The returned function may be called from any goroutine.
+func currentThreadCPUClock() (func() (time.Duration, error), error) {
+	path := fmt.Sprintf("/proc/self/task/%d/schedstat", syscall.Gettid())
+	clock := func() (time.Duration, error) {
+		stat, err := os.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+		fields := bytes.Fields(stat)
+		if len(fields) == 0 {
+			return 0, fmt.Errorf("unexpected schedstat content: %q", stat)
+		}
+		onCPU, err := strconv.ParseInt(string(fields[0]), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unexpected schedstat content: %w", err)
+		}
+		return time.Duration(onCPU), nil
+	}
+	if _, err := clock(); err != nil {
+		return nil, err
+	}
+	return clock, nil
+}
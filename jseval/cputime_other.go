@@ -0,0 +1,12 @@
+//go:build !linux
+
+package jseval
+
+import (
+	"errors"
+	"time"
+)
+
+func currentThreadCPUClock() (func() (time.Duration, error), error) {
+	return nil, errors.New("per-thread CPU time is only supported on linux")
+}
@@ -0,0 +1,47 @@
+package jseval
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is an error the engine located in the code.
+type Diagnostic struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+// DiagnosticParser extracts the diagnostics from the stderr of a failed run.
+// It returns none when the output is not in the format of its engine.
+type DiagnosticParser func(stderr string) []Diagnostic
+
+// boaLocation matches the location boa appends to its errors, e.g.
+// "Uncaught SyntaxError: unexpected token ')', primary expression at line 1, col 5".
+var boaLocation = regexp.MustCompile(`^(?:Uncaught )?(.+?),? at line (\d+), col(?:umn)? (\d+)$`)
+
+// BoaDiagnostics parses the errors of engines built on boa, one per line of
+// stderr ending in "at line L, col C".
+func BoaDiagnostics(stderr string) []Diagnostic {
+	var diagnostics []Diagnostic
+	for line := range strings.Lines(stderr) {
+		m := boaLocation.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		lineNo, errLine := strconv.Atoi(m[2])
+		column, errColumn := strconv.Atoi(m[3])
+		if errLine != nil || errColumn != nil {
+			continue
+		}
+		diagnostics = append(diagnostics, Diagnostic{Line: lineNo, Column: column, Message: m[1]})
+	}
+	return diagnostics
+}
+
+// WithDiagnosticParser fills ErrorDto.Diagnostics of failed runs by parsing
+// their stderr with p. Message keeps the raw output either way.
+func WithDiagnosticParser(p DiagnosticParser) Option {
+	return func(c *config) { c.diagnosticParser = p }
+}
@@ -0,0 +1,57 @@
+package jseval
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestBoaDiagnostics(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		want   []Diagnostic
+	}{
+		{
+			name:   "SyntaxError",
+			stderr: "Uncaught SyntaxError: unexpected token ')', primary expression at line 1, col 5\n",
+			want:   []Diagnostic{{Line: 1, Column: 5, Message: "SyntaxError: unexpected token ')', primary expression"}},
+		},
+		{
+			name:   "AbruptEnd",
+			stderr: "SyntaxError: abrupt end at line 3, col 12",
+			want:   []Diagnostic{{Line: 3, Column: 12, Message: "SyntaxError: abrupt end"}},
+		},
+		{
+			name:   "RuntimeError",
+			stderr: "Uncaught ReferenceError: x is not defined\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BoaDiagnostics(tt.stderr); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BoaDiagnostics() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithDiagnosticParser(t *testing.T) {
+	ctx := context.Background()
+	stderr := "Uncaught SyntaxError: unexpected token ')', primary expression at line 1, col 5\n"
+
+	evaluator, cleanup, err := NewEvaluator(ctx, writeFdAndExitWasm(2, stderr, 1), 1, WithDiagnosticParser(BoaDiagnostics))
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	result := evaluator(ctx, "1 + )")
+	if result.Error == nil || result.Error.Message != stderr {
+		t.Fatalf("expected the raw message to be kept, got: %+v", result.Error)
+	}
+	want := []Diagnostic{{Line: 1, Column: 5, Message: "SyntaxError: unexpected token ')', primary expression"}}
+	if !reflect.DeepEqual(result.Error.Diagnostics, want) {
+		t.Errorf("unexpected diagnostics. Got: %+v, Want: %+v", result.Error.Diagnostics, want)
+	}
+}
@@ -0,0 +1,40 @@
+package jseval
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// LeastBusy combines independent evaluators, typically each with its own runtime,
+// into one Evaluator. Each evaluation goes to the evaluator with the fewest
+// evaluations in flight; ties are broken round-robin. Without evaluators,
+// every evaluation fails with a host error.
+func LeastBusy(evaluators ...Evaluator) Evaluator {
+	switch len(evaluators) {
+	case 0:
+		return func(context.Context, string) JsEvalResultDto {
+			return JsEvalResultDto{Error: &ErrorDto{Code: -1, Message: "no evaluators to dispatch to"}}
+		}
+	case 1:
+		return evaluators[0]
+	}
+
+	inFlight := make([]atomic.Int64, len(evaluators))
+	var next atomic.Uint64
+
+	return func(ctx context.Context, code string) JsEvalResultDto {
+		n := uint64(len(evaluators))
+		start := next.Add(1)
+		chosen := int(start % n)
+		for i := uint64(1); i < n; i++ {
+			candidate := int((start + i) % n)
+			if inFlight[candidate].Load() < inFlight[chosen].Load() {
+				chosen = candidate
+			}
+		}
+
+		inFlight[chosen].Add(1)
+		defer inFlight[chosen].Add(-1)
+		return evaluators[chosen](ctx, code)
+	}
+}
@@ -0,0 +1,71 @@
+package jseval
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestLeastBusy(t *testing.T) {
+	const workers = 3
+	var mu sync.Mutex
+	counts := make([]int, workers)
+	evaluators := make([]Evaluator, workers)
+	for i := range evaluators {
+		evaluators[i] = func(context.Context, string) JsEvalResultDto {
+			mu.Lock()
+			counts[i]++
+			mu.Unlock()
+			return JsEvalResultDto{Result: i}
+		}
+	}
+
+	evaluator := LeastBusy(evaluators...)
+	for range workers * 4 {
+		evaluator(context.Background(), "")
+	}
+
+	// Sequential calls leave every evaluator idle, so dispatch is round-robin.
+	for i, count := range counts {
+		if count != 4 {
+			t.Errorf("evaluator %d got %d evaluations, want 4", i, count)
+		}
+	}
+}
+
+func TestLeastBusyWithoutEvaluators(t *testing.T) {
+	result := LeastBusy()(context.Background(), "1")
+	if result.Error == nil || result.Error.Code != -1 {
+		t.Errorf("expected a host error, got: %+v", result)
+	}
+}
+
+func BenchmarkLeastBusy(b *testing.B) {
+	ctx := context.Background()
+	wasm := writeAndExitWasm(`{"ok":true}`, -1)
+
+	for _, runtimes := range []int{1, 4} {
+		b.Run(fmt.Sprintf("Runtimes%d", runtimes), func(b *testing.B) {
+			evaluators := make([]Evaluator, runtimes)
+			for i := range evaluators {
+				evaluator, cleanup, err := NewEvaluator(ctx, wasm, 1)
+				if err != nil {
+					b.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+				}
+				b.Cleanup(func() { _ = cleanup() })
+				evaluators[i] = evaluator
+			}
+			evaluator := LeastBusy(evaluators...)
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if result := evaluator(ctx, ""); result.Error != nil {
+						b.Errorf("evaluator() returned an unexpected error: %+v", result.Error)
+					}
+				}
+			})
+		})
+	}
+}
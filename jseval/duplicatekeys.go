@@ -0,0 +1,101 @@
+package jseval
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrorCodeDuplicateKey is the ErrorDto code of a result with an object
+// repeating a member name, rejected WithRejectDuplicateKeys.
+const ErrorCodeDuplicateKey = -21
+
+// WithRejectDuplicateKeys rejects results with an object which has the same
+// member name twice, at any depth. encoding/json keeps the last value of
+// such members without notice, which can hide engine bugs such as a
+// serializer emitting a property twice. The output is scanned once more
+// before it is decoded. Results of WithTextResult are not JSON and are not
+// checked.
+func WithRejectDuplicateKeys() Option {
+	return func(c *config) { c.rejectDuplicates = true }
+}
+
+// checkDuplicateKeys returns the error of output which repeats a member name.
+func (c *config) checkDuplicateKeys(output []byte) *ErrorDto {
+	if !c.rejectDuplicates {
+		return nil
+	}
+	path, ok := duplicateKey(output)
+	if !ok {
+		return nil
+	}
+	return &ErrorDto{
+		Code:    ErrorCodeDuplicateKey,
+		Message: fmt.Sprintf("result has a duplicate key at %s", path),
+		Details: map[string]any{"path": path},
+	}
+}
+
+// pointerEscaper escapes a JSON pointer reference token (RFC 6901).
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// duplicateKey returns the JSON pointer of the first member of the JSON
+// output whose name its object already has. Invalid JSON is left to the
+// decoding to report.
+func duplicateKey(output []byte) (path string, found bool) {
+	// container is an array or object being scanned; segment is the pointer
+	// token of its element or member being scanned.
+	type container struct {
+		keys    map[string]struct{} // nil for arrays
+		key     bool                // an object expects a member name next
+		index   int
+		segment string
+	}
+	var open []container
+
+	dec := json.NewDecoder(bytes.NewReader(output))
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			// io.EOF after the last value, or a syntax error.
+			return "", false
+		}
+		delim, isDelim := token.(json.Delim)
+		if isDelim && (delim == ']' || delim == '}') {
+			open = open[:len(open)-1]
+			continue
+		}
+		if n := len(open); n > 0 {
+			top := &open[n-1]
+			switch {
+			case top.keys == nil:
+				top.segment = strconv.Itoa(top.index)
+				top.index++
+			case top.key:
+				name, _ := token.(string)
+				top.segment = pointerEscaper.Replace(name)
+				if _, dup := top.keys[name]; dup {
+					var b strings.Builder
+					for _, c := range open {
+						b.WriteString("/" + c.segment)
+					}
+					return b.String(), true
+				}
+				top.keys[name] = struct{}{}
+				top.key = false
+				continue
+			default:
+				top.key = true
+			}
+		}
+		if isDelim {
+			c := container{key: delim == '{'}
+			if delim == '{' {
+				c.keys = map[string]struct{}{}
+			}
+			open = append(open, c)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package jseval
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDuplicateKey(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{output: `{"a":1,"b":2}`, want: ""},
+		{output: `{"a":1,"a":2}`, want: "/a"},
+		{output: `[{"a":1},{"a":2}]`, want: ""},
+		{output: `{"x":[0,{"b":1,"c":{},"b":2}]}`, want: "/x/1/b"},
+		{output: `{"a/b":{"~":1,"~":2}}`, want: "/a~1b/~0"},
+		{output: `{"a":{"a":1}}`, want: ""},
+		{output: `{"a":1,"a"`, want: "/a"},
+		{output: `"a"`, want: ""},
+	}
+	for _, tt := range tests {
+		path, found := duplicateKey([]byte(tt.output))
+		if path != tt.want || found != (tt.want != "") {
+			t.Errorf("duplicateKey(%s) = %q, %v, want %q", tt.output, path, found, tt.want)
+		}
+	}
+}
+
+func TestWithRejectDuplicateKeys(t *testing.T) {
+	ctx := context.Background()
+	const duplicated = `{"id":1,"items":[{"id":2,"id":3}]}`
+
+	for _, opts := range [][]Option{
+		{WithRejectDuplicateKeys()},
+		{WithRejectDuplicateKeys(), WithRawResult()},
+	} {
+		evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(duplicated, -1), 1, opts...)
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+		result := evaluator(ctx, "")
+		_ = cleanup()
+		if result.Error == nil || result.Error.Code != ErrorCodeDuplicateKey {
+			t.Fatalf("expected the duplicate key to be rejected, got: %+v", result.Error)
+		}
+		if path := result.Error.Details["path"]; path != "/items/0/id" {
+			t.Errorf("path = %v, want /items/0/id", path)
+		}
+	}
+
+	// Without the option the last value wins, as with encoding/json.
+	evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(duplicated, -1), 1)
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+	result := evaluator(ctx, "")
+	if result.Error != nil {
+		t.Fatalf("expected duplicate keys to pass by default, got: %+v", result.Error)
+	}
+	items := result.Result.(map[string]any)["items"].([]any)
+	if id := items[0].(map[string]any)["id"]; id != 3.0 {
+		t.Errorf("id = %v, want the last value 3", id)
+	}
+}
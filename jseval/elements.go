@@ -0,0 +1,76 @@
+package jseval
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ErrorCodeTooManyResultElements is the ErrorDto code of a result with more
+// array elements and object members than WithMaxResultElements allows.
+const ErrorCodeTooManyResultElements = -20
+
+// WithMaxResultElements rejects results with more than limit array elements
+// and object members in total, at any depth, before they are decoded. Dense
+// output such as [0,0,0,...] decodes to far more memory than its size, so a
+// byte limit alone does not bound the cost of decoding it. The output is
+// scanned once more for the count, and the scan stops at the limit. Depth is
+// already bounded by encoding/json, which rejects nesting beyond 10000
+// levels.
+func WithMaxResultElements(limit int) Option {
+	return func(c *config) { c.maxResultElements = limit }
+}
+
+// checkResultElements returns the error of output which has too many elements.
+func (c *config) checkResultElements(output []byte) *ErrorDto {
+	if c.maxResultElements <= 0 || !exceedsElements(output, c.maxResultElements) {
+		return nil
+	}
+	return &ErrorDto{
+		Code:    ErrorCodeTooManyResultElements,
+		Message: fmt.Sprintf("result has more than %d elements", c.maxResultElements),
+		Details: map[string]any{"max_result_elements": c.maxResultElements},
+	}
+}
+
+// exceedsElements reports whether the JSON output has more than limit array
+// elements and object members. Invalid JSON is left to the decoding to
+// report.
+func exceedsElements(output []byte, limit int) bool {
+	// container is an array or object being scanned; inObject containers
+	// expect a member name next when key is set.
+	type container struct{ inObject, key bool }
+	var open []container
+	elements := 0
+
+	dec := json.NewDecoder(bytes.NewReader(output))
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			// io.EOF after the last value, or a syntax error.
+			return false
+		}
+		delim, isDelim := token.(json.Delim)
+		if isDelim && (delim == ']' || delim == '}') {
+			open = open[:len(open)-1]
+			continue
+		}
+		if n := len(open); n > 0 {
+			top := &open[n-1]
+			if top.inObject {
+				if top.key {
+					top.key = false
+					continue
+				}
+				top.key = true
+			}
+			elements++
+			if elements > limit {
+				return true
+			}
+		}
+		if isDelim {
+			open = append(open, container{inObject: delim == '{', key: delim == '{'})
+		}
+	}
+}
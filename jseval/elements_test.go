@@ -0,0 +1,62 @@
+package jseval
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestExceedsElements(t *testing.T) {
+	tests := []struct {
+		output string
+		limit  int
+		want   bool
+	}{
+		{output: `42`, limit: 0, want: false},
+		{output: `[1,2,3]`, limit: 3, want: false},
+		{output: `[1,2,3]`, limit: 2, want: true},
+		{output: `{"a":1,"b":2}`, limit: 2, want: false},
+		{output: `{"a":{"b":[1,2]}}`, limit: 3, want: true},
+		{output: `{"a":{"b":[1,2]}}`, limit: 4, want: false},
+		{output: `[[],{},[[]]]`, limit: 4, want: false},
+		{output: `[[],{},[[]]]`, limit: 3, want: true},
+		{output: `[1,2,`, limit: 10, want: false},
+	}
+	for _, tt := range tests {
+		if got := exceedsElements([]byte(tt.output), tt.limit); got != tt.want {
+			t.Errorf("exceedsElements(%s, %d) = %v, want %v", tt.output, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestWithMaxResultElements(t *testing.T) {
+	ctx := context.Background()
+	flat := "[" + strings.TrimSuffix(strings.Repeat("0,", 20_000), ",") + "]"
+
+	for _, opts := range [][]Option{
+		{WithMaxResultElements(1000)},
+		{WithMaxResultElements(1000), WithRawResult()},
+	} {
+		evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(flat, -1), 1, opts...)
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+		result := evaluator(ctx, "")
+		_ = cleanup()
+		if result.Error == nil || result.Error.Code != ErrorCodeTooManyResultElements {
+			t.Fatalf("expected the flat array to be rejected, got: %+v", result.Error)
+		}
+		if limit := result.Error.Details["max_result_elements"]; limit != 1000 {
+			t.Errorf("max_result_elements = %v, want 1000", limit)
+		}
+	}
+
+	evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(`{"a":[1,2,3]}`, -1), 1, WithMaxResultElements(4))
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+	if result := evaluator(ctx, ""); result.Error != nil {
+		t.Errorf("expected a result within the limit to pass, got: %+v", result.Error)
+	}
+}
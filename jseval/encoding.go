@@ -0,0 +1,79 @@
+package jseval
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrorCodeInvalidEncoding is the ErrorDto code of code which is not valid UTF-8.
+const ErrorCodeInvalidEncoding = -11
+
+// utf8BOM is the UTF-8 byte order mark some editors prepend to files.
+const utf8BOM = "\uFEFF"
+
+var errInvalidUTF8 = errors.New("code is not valid UTF-8")
+
+// WithRawCode passes the code to the engine byte for byte. By default a
+// leading UTF-8 byte order mark is removed and code which is not valid UTF-8
+// is rejected with ErrorCodeInvalidEncoding, as engines tend to fail on
+// either with confusing syntax errors.
+func WithRawCode() Option {
+	return func(c *config) { c.rawCode = true }
+}
+
+// normalizeCode returns the code without a leading byte order mark, failing
+// when it is not valid UTF-8.
+func (c *config) normalizeCode(code string) (string, error) {
+	if c.rawCode {
+		return code, nil
+	}
+	code = strings.TrimPrefix(code, utf8BOM)
+	if !utf8.ValidString(code) {
+		return "", errInvalidUTF8
+	}
+	return code, nil
+}
+
+// Encodings of the text result of WithTextResult.
+const (
+	// ResultEncodingUTF8 returns stdout as a string, replacing bytes which are
+	// not valid UTF-8 with U+FFFD when it is encoded as JSON. The default.
+	ResultEncodingUTF8 = "utf8"
+	// ResultEncodingBase64 always returns stdout base64 encoded.
+	ResultEncodingBase64 = "base64"
+	// ResultEncodingAuto returns stdout base64 encoded only when it is not
+	// valid UTF-8, and as a string otherwise.
+	ResultEncodingAuto = "auto"
+)
+
+// WithResultEncoding selects how WithTextResult returns stdout. Base64
+// encoded results have ResultEncoding "base64" so that clients can tell them
+// apart; stdout is then carried byte for byte. NewEvaluator fails for
+// unknown encodings.
+func WithResultEncoding(encoding string) Option {
+	return func(c *config) { c.resultEncoding = encoding }
+}
+
+func validateResultEncoding(encoding string) error {
+	switch encoding {
+	case "", ResultEncodingUTF8, ResultEncodingBase64, ResultEncodingAuto:
+		return nil
+	default:
+		return fmt.Errorf("unknown result encoding %q", encoding)
+	}
+}
+
+// textOutput returns stdout as the text result and its ResultEncoding,
+// which is empty for a plain string.
+func (c *config) textOutput(output []byte) (string, string) {
+	switch {
+	case c.resultEncoding == ResultEncodingBase64,
+		c.resultEncoding == ResultEncodingAuto && !utf8.Valid(output):
+		return base64.StdEncoding.EncodeToString(output), ResultEncodingBase64
+	default:
+		return string(output), ""
+	}
+}
@@ -0,0 +1,77 @@
+package jseval
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNormalizeCode(t *testing.T) {
+	cfg := newConfig(nil)
+	for _, tc := range []struct {
+		name string
+		code string
+		want string
+		err  error
+	}{
+		{"Plain", "1 + 1", "1 + 1", nil},
+		{"BOM", "\uFEFF1 + 1", "1 + 1", nil},
+		{"InnerBOMKept", "'\uFEFF'", "'\uFEFF'", nil},
+		{"InvalidUTF8", "'\xff'", "", errInvalidUTF8},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := cfg.normalizeCode(tc.code)
+			if !errors.Is(err, tc.err) || got != tc.want {
+				t.Errorf("normalizeCode(%q) = %q, %v; want %q, %v", tc.code, got, err, tc.want, tc.err)
+			}
+		})
+	}
+
+	raw := newConfig([]Option{WithRawCode()})
+	if got, err := raw.normalizeCode("\uFEFF'\xff'"); err != nil || got != "\uFEFF'\xff'" {
+		t.Errorf("expected raw code to be kept, got %q, %v", got, err)
+	}
+}
+
+func TestInvalidEncodingRejected(t *testing.T) {
+	evaluator := newFixtureEvaluator(t)
+	result := evaluator(t.Context(), "\xc3(")
+	if result.Error == nil || result.Error.Code != ErrorCodeInvalidEncoding {
+		t.Fatalf("expected an encoding error, got %+v", result)
+	}
+
+	result = evaluator(t.Context(), "\uFEFF1")
+	if result.Error != nil || result.Result != 1.0 {
+		t.Errorf("expected the BOM to be stripped before the engine, got %+v", result)
+	}
+}
+
+func TestWithResultEncoding(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name         string
+		encoding     string
+		output       string
+		wantResult   string
+		wantEncoding string
+	}{
+		{"DefaultText", "", "héllo", "héllo", ""},
+		{"UTF8Invalid", ResultEncodingUTF8, "a\xff", "a\xff", ""},
+		{"Base64", ResultEncodingBase64, "héllo", "aMOpbGxv", ResultEncodingBase64},
+		{"AutoValid", ResultEncodingAuto, "héllo", "héllo", ""},
+		{"AutoInvalid", ResultEncodingAuto, "a\xff", "Yf8=", ResultEncodingBase64},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// The fixture echoes its stdin, so invalid UTF-8 needs WithRawCode.
+			evaluator := newFixtureEvaluator(t, WithTextResult(), WithResultEncoding(tc.encoding), WithRawCode())
+			result := evaluator(ctx, tc.output)
+			if result.Error != nil || result.Result != tc.wantResult || result.ResultEncoding != tc.wantEncoding {
+				t.Errorf("got %q (%q), %+v; want %q (%q)", result.Result, result.ResultEncoding, result.Error, tc.wantResult, tc.wantEncoding)
+			}
+		})
+	}
+
+	if _, _, err := NewEvaluator(ctx, writeAndExitWasm("1", -1), 1, WithResultEncoding("hex")); err == nil {
+		t.Error("expected an unknown encoding to be rejected")
+	}
+}
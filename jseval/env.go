@@ -0,0 +1,27 @@
+package jseval
+
+import (
+	"context"
+	"maps"
+	"slices"
+)
+
+type envKey struct{}
+
+// ContextWithEnv returns a context which sets the environment variables of the
+// evaluations run with it. They are visible to that evaluation only: every
+// evaluation starts from a fresh module configuration, so environment, stdio and
+// any other module state never carry over to another evaluation.
+func ContextWithEnv(ctx context.Context, env map[string]string) context.Context {
+	return context.WithValue(ctx, envKey{}, maps.Clone(env))
+}
+
+// envFrom returns the environment of the context in a stable order.
+func envFrom(ctx context.Context) [][2]string {
+	env, _ := ctx.Value(envKey{}).(map[string]string)
+	pairs := make([][2]string, 0, len(env))
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		pairs = append(pairs, [2]string{name, env[name]})
+	}
+	return pairs
+}
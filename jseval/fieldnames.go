@@ -0,0 +1,102 @@
+package jseval
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldNames maps default JSON keys of JsEvalResultDto and its ErrorDto to
+// the keys a client expects instead. Keys of JsEvalResultDto are the plain
+// names, such as "result"; keys of ErrorDto are prefixed with "error.", such
+// as "error.message", as some names, like "code", occur in both.
+type FieldNames map[string]string
+
+// errorFieldPrefix scopes a FieldNames key to the ErrorDto.
+const errorFieldPrefix = "error."
+
+// ParseFieldNames parses a comma separated list of default=custom pairs,
+// e.g. "result=value,error=err,error.code=status". A plain name only found in
+// ErrorDto, such as "message", is short for its "error." key; a plain name
+// found in both, such as "code", is the JsEvalResultDto field.
+func ParseFieldNames(spec string) (FieldNames, error) {
+	resultFields := jsonFieldNames(reflect.TypeFor[JsEvalResultDto]())
+	errorFields := jsonFieldNames(reflect.TypeFor[ErrorDto]())
+	names := FieldNames{}
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(pair, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid field name mapping %q, want default=custom", pair)
+		}
+		if name, scoped := strings.CutPrefix(from, errorFieldPrefix); scoped {
+			if !errorFields[name] {
+				return nil, fmt.Errorf("invalid field name mapping %q: the error has no field %q", pair, name)
+			}
+		} else if !resultFields[from] && errorFields[from] {
+			from = errorFieldPrefix + from
+		}
+		if _, dup := names[from]; dup {
+			return nil, fmt.Errorf("field %q is renamed twice", from)
+		}
+		names[from] = to
+	}
+	return names, nil
+}
+
+// jsonFieldNames returns the JSON keys of the fields of the struct type t.
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for i := range t.NumField() {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// Rename returns the JSON object of the result with its envelope keys renamed.
+// The evaluated value itself is passed through untouched.
+func (f FieldNames) Rename(result JsEvalResultDto) (map[string]any, error) {
+	fields, err := f.renameObject(result, "")
+	if err != nil {
+		return nil, err
+	}
+	if result.Error != nil {
+		errorFields, err := f.renameObject(result.Error, errorFieldPrefix)
+		if err != nil {
+			return nil, err
+		}
+		fields[f.key("", "error")] = errorFields
+	}
+	return fields, nil
+}
+
+// renameObject renames the keys of the JSON object of v looked up with
+// scope, the prefix of their FieldNames keys.
+func (f FieldNames) renameObject(v any, scope string) (map[string]any, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the result: %w", err)
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode the result: %w", err)
+	}
+	renamed := make(map[string]any, len(raw))
+	for key, value := range raw {
+		renamed[f.key(scope, key)] = value
+	}
+	return renamed, nil
+}
+
+func (f FieldNames) key(scope, name string) string {
+	if renamed, ok := f[scope+name]; ok {
+		return renamed
+	}
+	return name
+}
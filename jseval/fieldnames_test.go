@@ -0,0 +1,73 @@
+package jseval
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFieldNames(t *testing.T) {
+	names, err := ParseFieldNames("result=value, error=err,message=msg")
+	if err != nil {
+		t.Fatalf("ParseFieldNames() returned an unexpected error: %v", err)
+	}
+
+	t.Run("RenamesEnvelopeOnly", func(t *testing.T) {
+		result := JsEvalResultDto{Result: map[string]any{"result": 1}, OutputBytes: 12}
+		renamed, err := names.Rename(result)
+		if err != nil {
+			t.Fatalf("Rename() returned an unexpected error: %v", err)
+		}
+		encoded, _ := json.Marshal(renamed)
+		want := `{"outputBytes":12,"value":{"result":1}}`
+		if string(encoded) != want {
+			t.Errorf("unexpected output.\nGot:  %s\nWant: %s", encoded, want)
+		}
+	})
+
+	t.Run("RenamesErrorFields", func(t *testing.T) {
+		result := JsEvalResultDto{Error: &ErrorDto{Code: 1, Message: "boom"}}
+		renamed, err := names.Rename(result)
+		if err != nil {
+			t.Fatalf("Rename() returned an unexpected error: %v", err)
+		}
+		encoded, _ := json.Marshal(renamed)
+		want := `{"err":{"code":1,"msg":"boom"},"outputBytes":0,"value":null}`
+		if string(encoded) != want {
+			t.Errorf("unexpected output.\nGot:  %s\nWant: %s", encoded, want)
+		}
+	})
+
+	t.Run("ScopesSharedNames", func(t *testing.T) {
+		names, err := ParseFieldNames("code=source,error.code=status")
+		if err != nil {
+			t.Fatalf("ParseFieldNames() returned an unexpected error: %v", err)
+		}
+		result := JsEvalResultDto{Code: "1+", Error: &ErrorDto{Code: -5, Message: "boom"}}
+		renamed, err := names.Rename(result)
+		if err != nil {
+			t.Fatalf("Rename() returned an unexpected error: %v", err)
+		}
+		encoded, _ := json.Marshal(renamed)
+		want := `{"error":{"message":"boom","status":-5},"outputBytes":0,"result":null,"source":"1+"}`
+		if string(encoded) != want {
+			t.Errorf("unexpected output.\nGot:  %s\nWant: %s", encoded, want)
+		}
+
+		// A plain shared name renames the result field only.
+		names, _ = ParseFieldNames("code=source")
+		renamed, _ = names.Rename(result)
+		encoded, _ = json.Marshal(renamed)
+		want = `{"error":{"code":-5,"message":"boom"},"outputBytes":0,"result":null,"source":"1+"}`
+		if string(encoded) != want {
+			t.Errorf("unexpected output.\nGot:  %s\nWant: %s", encoded, want)
+		}
+	})
+
+	t.Run("RejectsMalformedSpec", func(t *testing.T) {
+		for _, spec := range []string{"result", "error.result=value", "message=a,error.message=b"} {
+			if _, err := ParseFieldNames(spec); err == nil {
+				t.Errorf("ParseFieldNames(%q) returned no error", spec)
+			}
+		}
+	})
+}
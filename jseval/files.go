@@ -0,0 +1,116 @@
+package jseval
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing/fstest"
+
+	"github.com/tetratelabs/wazero"
+)
+
+type filesKey struct{}
+
+// ContextWithFiles returns a context which mounts the files, keyed by their
+// slash separated path, read-only at / of the evaluations run with it. The
+// code on stdin stays the entrypoint; whether and how it can import the
+// files depends on the engine. Like the environment, the file system belongs
+// to the one evaluation and is dropped with its module configuration.
+//
+// Use ValidateFiles first: files with an invalid name are not mounted.
+func ContextWithFiles(ctx context.Context, files map[string]string) context.Context {
+	if len(files) == 0 {
+		return ctx
+	}
+	mounted := make(fstest.MapFS, len(files))
+	for name, content := range files {
+		cleaned, err := fileName(name)
+		if err != nil {
+			continue
+		}
+		mounted[cleaned] = &fstest.MapFile{Data: []byte(content), Mode: 0o444}
+	}
+	return context.WithValue(ctx, filesKey{}, fs.FS(mounted))
+}
+
+// ValidateFiles reports the first file name which can not be mounted.
+func ValidateFiles(files map[string]string) error {
+	for name := range files {
+		if _, err := fileName(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileName strips a leading slash and rejects names escaping the mount.
+func fileName(name string) (string, error) {
+	cleaned := strings.TrimPrefix(name, "/")
+	if cleaned == "." || !fs.ValidPath(cleaned) {
+		return "", fmt.Errorf("invalid file name %q", name)
+	}
+	return cleaned, nil
+}
+
+// filesFrom returns the file system mounted for the evaluation, or nil.
+func filesFrom(ctx context.Context) fs.FS {
+	mounted, _ := ctx.Value(filesKey{}).(fs.FS)
+	return mounted
+}
+
+// WithSharedFiles mounts fsys read-only at mountPath, e.g. "/lib", of every
+// evaluation, next to the files of ContextWithFiles at /. The mount is set up
+// once for the evaluator, so a stable library costs nothing per evaluation.
+// fsys must not change while the evaluator is in use; LoadSharedFiles
+// provides an in-memory copy of a directory.
+func WithSharedFiles(fsys fs.FS, mountPath string) Option {
+	return func(c *config) {
+		c.sharedFS = wazero.NewFSConfig().WithFSMount(fsys, mountPath)
+	}
+}
+
+// LoadSharedFiles copies the regular files below dir into memory for
+// WithSharedFiles, failing when they exceed maxBytes in total.
+func LoadSharedFiles(dir string, maxBytes int64) (fs.FS, error) {
+	loaded := fstest.MapFS{}
+	var total int64
+	err := fs.WalkDir(os.DirFS(dir), ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if total += info.Size(); total > maxBytes {
+			return fmt.Errorf("files exceed the limit of %d bytes", maxBytes)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return err
+		}
+		loaded[name] = &fstest.MapFile{Data: data, Mode: 0o444}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the shared files of %s: %w", dir, err)
+	}
+	return loaded, nil
+}
+
+// fsConfig returns the file system configuration of an evaluation with the
+// files of ctx, or nil when it has no file system.
+func (c *config) fsConfig(ctx context.Context) wazero.FSConfig {
+	files := filesFrom(ctx)
+	if files == nil {
+		return c.sharedFS
+	}
+	base := c.sharedFS
+	if base == nil {
+		base = wazero.NewFSConfig()
+	}
+	return base.WithFSMount(files, "/")
+}
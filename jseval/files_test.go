@@ -0,0 +1,88 @@
+package jseval
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestContextWithFiles(t *testing.T) {
+	files := map[string]string{
+		"lib.js":        "export const x = 1;",
+		"/util/math.js": "export const add = (a, b) => a + b;",
+	}
+	mounted := filesFrom(ContextWithFiles(context.Background(), files))
+	if mounted == nil {
+		t.Fatal("expected a mounted file system")
+	}
+
+	for name, want := range map[string]string{
+		"lib.js":       files["lib.js"],
+		"util/math.js": files["/util/math.js"],
+	} {
+		got, err := fs.ReadFile(mounted, name)
+		if err != nil {
+			t.Errorf("ReadFile(%q) returned an unexpected error: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("ReadFile(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	if filesFrom(ContextWithFiles(context.Background(), nil)) != nil {
+		t.Error("expected no file system without files")
+	}
+}
+
+func TestValidateFiles(t *testing.T) {
+	for _, name := range []string{"", "/", "../etc/passwd", "a/../../b", "a//b"} {
+		if err := ValidateFiles(map[string]string{name: ""}); err == nil {
+			t.Errorf("ValidateFiles(%q) returned no error", name)
+		}
+	}
+	if err := ValidateFiles(map[string]string{"lib.js": "", "/dir/mod.js": ""}); err != nil {
+		t.Errorf("ValidateFiles() returned an unexpected error: %v", err)
+	}
+}
+
+func TestLoadSharedFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "util"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"lib.js": "export const x = 1;", "util/math.js": "export {};"} {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	shared, err := LoadSharedFiles(dir, 1024)
+	if err != nil {
+		t.Fatalf("LoadSharedFiles() returned an unexpected error: %v", err)
+	}
+	if got, err := fs.ReadFile(shared, "util/math.js"); err != nil || string(got) != "export {};" {
+		t.Errorf("unexpected shared file: %q, %v", got, err)
+	}
+
+	// The copy does not follow later changes of the directory.
+	if err := os.WriteFile(filepath.Join(dir, "lib.js"), []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := fs.ReadFile(shared, "lib.js"); string(got) != "export const x = 1;" {
+		t.Errorf("expected the shared files to be immutable, got %q", got)
+	}
+
+	if _, err := LoadSharedFiles(dir, 8); err == nil {
+		t.Error("expected files beyond the limit to be rejected")
+	}
+
+	// Shared files mount next to the files of an evaluation.
+	evaluator := newFixtureEvaluator(t, WithSharedFiles(shared, "/lib"))
+	ctx := ContextWithFiles(context.Background(), map[string]string{"main.js": ""})
+	if result := evaluator(ctx, "1"); result.Error != nil {
+		t.Errorf("unexpected error with shared files: %+v", result.Error)
+	}
+}
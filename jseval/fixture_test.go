@@ -0,0 +1,211 @@
+package jseval
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The fixture engine, testdata/fixture.wasm, is a WASI command standing in
+// for a real JavaScript engine:
+//
+//   - It writes fixtureLogLine to stderr.
+//   - It copies stdin to stdout, so code which is JSON "evaluates" to itself.
+//   - Given an environment whose first entry is EXIT=<digit>, e.g. through
+//     ContextWithEnv, it then exits with that code.
+//
+// It is assembled by fixtureWasm. After changing it, regenerate the committed
+// file with:
+//
+//	go test ./jseval -run TestFixtureWasm -update
+var updateFixture = flag.Bool("update", false, "regenerate testdata/fixture.wasm")
+
+const (
+	fixturePath    = "testdata/fixture.wasm"
+	fixtureLogLine = "fixture: started\n"
+)
+
+// i32Const encodes an i32.const instruction; its immediate is signed LEB128.
+func i32Const(v int32) []byte {
+	return append([]byte{0x41}, sleb128(v)...)
+}
+
+// fixtureWasm assembles the fixture engine.
+//
+// Memory layout: iovecs for the log line at 0, stdin at 8 and stdout at 16,
+// the read and written byte counts at 24 and 28, the environment count and
+// size at 32 and 36, the log line at 64, the environment pointers at 256 and
+// strings at 512, and the 8 KiB copy buffer at 1024.
+func fixtureWasm() []byte {
+	const (
+		fdWrite = iota
+		fdRead
+		procExit
+		environSizesGet
+		environGet
+		start
+	)
+
+	types := []byte{4}
+	types = append(types, 0x60, 4, 0x7f, 0x7f, 0x7f, 0x7f, 1, 0x7f) // fd_write, fd_read
+	types = append(types, 0x60, 1, 0x7f, 0)                         // proc_exit
+	types = append(types, 0x60, 2, 0x7f, 0x7f, 1, 0x7f)             // environ_sizes_get, environ_get
+	types = append(types, 0x60, 0, 0)                               // _start
+
+	imports := []byte{5}
+	for _, imp := range []struct {
+		name    string
+		typeIdx byte
+	}{{"fd_write", 0}, {"fd_read", 0}, {"proc_exit", 1}, {"environ_sizes_get", 2}, {"environ_get", 2}} {
+		imports = append(imports, wasmName("wasi_snapshot_preview1")...)
+		imports = append(imports, wasmName(imp.name)...)
+		imports = append(imports, 0x00, imp.typeIdx)
+	}
+
+	exports := []byte{2}
+	exports = append(exports, wasmName("memory")...)
+	exports = append(exports, 0x02, 0)
+	exports = append(exports, wasmName("_start")...)
+	exports = append(exports, 0x00, start)
+
+	var body []byte
+	emit := func(parts ...[]byte) {
+		for _, p := range parts {
+			body = append(body, p...)
+		}
+	}
+	call := func(idx byte) []byte { return []byte{0x10, idx} }
+	drop := []byte{0x1a}
+	load := []byte{0x28, 2, 0}
+	store := []byte{0x36, 2, 0}
+
+	emit([]byte{0}) // no locals
+	emit(i32Const(2), i32Const(0), i32Const(1), i32Const(28), call(fdWrite), drop)
+
+	emit([]byte{0x02, 0x40, 0x03, 0x40}) // block loop
+	emit(i32Const(0), i32Const(8), i32Const(1), i32Const(24), call(fdRead), drop)
+	emit(i32Const(24), load, []byte{0x45, 0x0d, 1}) // br_if the block on EOF
+	emit(i32Const(20), i32Const(24), load, store)   // stdout iovec.len = nread
+	emit(i32Const(1), i32Const(16), i32Const(1), i32Const(28), call(fdWrite), drop)
+	emit([]byte{0x0c, 0, 0x0b, 0x0b}) // br loop, end loop, end block
+
+	emit(i32Const(32), i32Const(36), call(environSizesGet), drop)
+	emit(i32Const(32), load, []byte{0x04, 0x40}) // if count != 0
+	emit(i32Const(256), i32Const(512), call(environGet), drop)
+	emit(i32Const(int32(512+len("EXIT="))), []byte{0x2d, 0, 0}, i32Const('0'), []byte{0x6b}, call(procExit))
+	emit([]byte{0x0b, 0x0b}) // end if, end function
+
+	code := append([]byte{1}, uleb128(uint32(len(body)))...)
+	code = append(code, body...)
+
+	le32 := func(v int) []byte { return []byte{byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24)} }
+	var iovecs []byte
+	iovecs = append(iovecs, le32(64)...)
+	iovecs = append(iovecs, le32(len(fixtureLogLine))...)
+	iovecs = append(iovecs, le32(1024)...)
+	iovecs = append(iovecs, le32(8192)...)
+	iovecs = append(iovecs, le32(1024)...)
+	iovecs = append(iovecs, le32(0)...)
+
+	data := []byte{2}
+	for _, segment := range []struct {
+		offset  int32
+		payload []byte
+	}{{0, iovecs}, {64, []byte(fixtureLogLine)}} {
+		data = append(data, 0)
+		data = append(data, i32Const(segment.offset)...)
+		data = append(data, 0x0b)
+		data = append(data, uleb128(uint32(len(segment.payload)))...)
+		data = append(data, segment.payload...)
+	}
+
+	wasm := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	wasm = append(wasm, wasmSection(1, types)...)
+	wasm = append(wasm, wasmSection(2, imports)...)
+	wasm = append(wasm, wasmSection(3, []byte{1, 3})...)
+	wasm = append(wasm, wasmSection(5, []byte{1, 0, 1})...)
+	wasm = append(wasm, wasmSection(7, exports)...)
+	wasm = append(wasm, wasmSection(10, code)...)
+	wasm = append(wasm, wasmSection(11, data)...)
+	return wasm
+}
+
+func TestFixtureWasm(t *testing.T) {
+	want := fixtureWasm()
+	if *updateFixture {
+		if err := os.MkdirAll(filepath.Dir(fixturePath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fixturePath, want, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := os.ReadFile(fixturePath)
+	if err != nil {
+		t.Fatalf("failed to read the fixture: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s is outdated; regenerate it with -update", fixturePath)
+	}
+}
+
+// newFixtureEvaluator creates an evaluator around the committed fixture
+// engine and closes it when the test ends.
+func newFixtureEvaluator(t testing.TB, opts ...Option) Evaluator {
+	t.Helper()
+	wasm, err := LoadWasmBinary(fixturePath, 1)
+	if err != nil {
+		t.Fatalf("failed to load the fixture: %v", err)
+	}
+	evaluator, cleanup, err := NewEvaluator(context.Background(), wasm, 1, opts...)
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = cleanup() })
+	return evaluator
+}
+
+// fixtureExitCtx makes the fixture exit with code after echoing.
+func fixtureExitCtx(ctx context.Context, code byte) context.Context {
+	return ContextWithEnv(ctx, map[string]string{"EXIT": string('0' + code)})
+}
+
+func TestFixtureEvaluator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("EchoesJSON", func(t *testing.T) {
+		var logged []string
+		evaluator := newFixtureEvaluator(t)
+		result := evaluator(ContextWithLogSink(ctx, func(stream, line string) {
+			logged = append(logged, stream+":"+line)
+		}), `{"answer":42}`)
+		if result.Error != nil {
+			t.Fatalf("unexpected error: %+v", result.Error)
+		}
+		if got, _ := result.Result.(map[string]interface{}); got["answer"] != 42.0 {
+			t.Errorf("unexpected result: %#v", result.Result)
+		}
+		if len(logged) != 2 || logged[0] != "stderr:fixture: started" {
+			t.Errorf("unexpected log lines: %q", logged)
+		}
+	})
+
+	t.Run("EchoesLargeInput", func(t *testing.T) {
+		input := largeJSONArray(1000) // several reads of the 8 KiB buffer
+		result := newFixtureEvaluator(t)(ctx, input)
+		if result.Error != nil || result.OutputBytes != len(input) {
+			t.Errorf("unexpected result: %d bytes, error %+v", result.OutputBytes, result.Error)
+		}
+	})
+
+	t.Run("ExitsOnDemand", func(t *testing.T) {
+		result := newFixtureEvaluator(t)(fixtureExitCtx(ctx, 3), `1`)
+		if result.Error == nil || result.Error.Code != 3 || result.Error.Message != fixtureLogLine {
+			t.Errorf("expected exit code 3 with the log as message, got: %+v", result.Error)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package jseval
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// WithFlushGrace lets the engine write to stdout for up to grace after its
+// run returns, before the output is read and the module is closed. Output
+// keeps being collected while writes arrive less than grace apart, until the
+// context of the evaluation is done; writes after that are dropped.
+//
+// A WASI command writes synchronously, so this is only needed for engines
+// whose host functions hand output to goroutines of their own, which may
+// still be writing when _start returns. The default of zero reads stdout as
+// soon as the run returns.
+func WithFlushGrace(grace time.Duration) Option {
+	return func(c *config) { c.flushGrace = grace }
+}
+
+// drainingWriter passes writes to w until closed, recording when the last
+// one arrived so that the output can be left to drain.
+type drainingWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	last   time.Time
+	closed bool
+}
+
+func (d *drainingWriter) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return 0, os.ErrClosed
+	}
+	d.last = time.Now()
+	return d.w.Write(p)
+}
+
+// drain waits until no write arrived for grace or ctx is done, then closes
+// d.
+func (d *drainingWriter) drain(ctx context.Context, grace time.Duration) {
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			d.close()
+			return
+		case <-timer.C:
+		}
+		d.mu.Lock()
+		idle := time.Since(d.last)
+		if idle >= grace {
+			d.closed = true
+			d.mu.Unlock()
+			return
+		}
+		d.mu.Unlock()
+		timer.Reset(grace - idle)
+	}
+}
+
+func (d *drainingWriter) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+}
+
+// drainableOutput wraps stdout to be drained after the run, if a grace period
+// is set.
+func (c *config) drainableOutput(stdout io.Writer) (io.Writer, func(context.Context)) {
+	if c.flushGrace <= 0 {
+		return stdout, func(context.Context) {}
+	}
+	d := &drainingWriter{w: stdout}
+	return d, func(ctx context.Context) { d.drain(ctx, c.flushGrace) }
+}
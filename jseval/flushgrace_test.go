@@ -0,0 +1,60 @@
+package jseval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDrainingWriter(t *testing.T) {
+	var buf bytes.Buffer
+	d := &drainingWriter{w: &buf}
+	_, _ = d.Write([]byte("a"))
+
+	// Output handed to another goroutine arrives after the run returned.
+	go func() {
+		for _, s := range []string{"b", "c"} {
+			time.Sleep(20 * time.Millisecond)
+			_, _ = d.Write([]byte(s))
+		}
+	}()
+	d.drain(context.Background(), 100*time.Millisecond)
+	if got := buf.String(); got != "abc" {
+		t.Errorf("drained output = %q, want abc", got)
+	}
+	if _, err := d.Write([]byte("d")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected writes after the drain to fail, got: %v", err)
+	}
+
+	// The drain ends with the context.
+	d = &drainingWriter{w: &buf}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	d.drain(ctx, time.Hour)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("drain took %v after the context ended", elapsed)
+	}
+}
+
+func TestWithFlushGrace(t *testing.T) {
+	ctx := context.Background()
+	const grace = 50 * time.Millisecond
+	evaluator, cleanup, err := NewEvaluator(ctx, sleepThenWriteWasm(10*time.Millisecond, `"late"`), 1, WithFlushGrace(grace))
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	start := time.Now()
+	result := evaluator(ctx, "")
+	if result.Error != nil || result.Result != "late" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if elapsed := time.Since(start); elapsed < grace {
+		t.Errorf("evaluation took %v, want at least the grace of %v", elapsed, grace)
+	}
+}
@@ -0,0 +1,54 @@
+package jseval
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// HostFunction is a function the host offers to the engine.
+type HostFunction struct {
+	Module string `json:"module"`
+	Name   string `json:"name"`
+
+	// Imported is true when the engine imports the function, i.e. may call it.
+	Imported bool `json:"imported"`
+}
+
+// hostModules are the host modules NewEvaluator instantiates.
+var hostModules = []string{wasi_snapshot_preview1.ModuleName}
+
+// WithOnHostFunctions calls f with every function of the host modules of the
+// runtime, sorted by module and name, once NewEvaluator compiled the module.
+// It is the complete list of what the sandbox grants: only WASI preview1
+// functions, as no other host functions are registered.
+func WithOnHostFunctions(f func([]HostFunction)) Option {
+	return func(c *config) { c.onHostFunctions = f }
+}
+
+// hostFunctions lists the functions of the host modules of r and marks those
+// compiled imports.
+func hostFunctions(r wazero.Runtime, compiled wazero.CompiledModule) []HostFunction {
+	imported := map[[2]string]bool{}
+	for _, def := range compiled.ImportedFunctions() {
+		module, name, _ := def.Import()
+		imported[[2]string{module, name}] = true
+	}
+	var functions []HostFunction
+	for _, module := range hostModules {
+		var exports map[string]api.FunctionDefinition
+		if instance := r.Module(module); instance != nil {
+			exports = instance.ExportedFunctionDefinitions()
+		}
+		for name := range exports {
+			functions = append(functions, HostFunction{Module: module, Name: name, Imported: imported[[2]string{module, name}]})
+		}
+	}
+	slices.SortFunc(functions, func(a, b HostFunction) int {
+		return cmp.Or(cmp.Compare(a.Module, b.Module), cmp.Compare(a.Name, b.Name))
+	})
+	return functions
+}
@@ -0,0 +1,38 @@
+package jseval
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithOnHostFunctions(t *testing.T) {
+	var functions []HostFunction
+	_, cleanup, err := NewEvaluator(context.Background(), writeAndExitWasm("1", 0), 1,
+		WithOnHostFunctions(func(f []HostFunction) { functions = f }))
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	byName := map[string]HostFunction{}
+	for _, f := range functions {
+		if f.Module != "wasi_snapshot_preview1" {
+			t.Errorf("unexpected host module: %+v", f)
+		}
+		byName[f.Name] = f
+	}
+	// The module imports fd_write and proc_exit only.
+	for name, imported := range map[string]bool{"fd_write": true, "proc_exit": true, "fd_read": false, "random_get": false} {
+		f, ok := byName[name]
+		if !ok {
+			t.Errorf("%s is missing from %v", name, functions)
+			continue
+		}
+		if f.Imported != imported {
+			t.Errorf("%s imported = %v, want %v", name, f.Imported, imported)
+		}
+	}
+	if len(functions) < 40 {
+		t.Errorf("expected all WASI preview1 functions, got %d", len(functions))
+	}
+}
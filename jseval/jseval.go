@@ -3,13 +3,16 @@ package jseval
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
-	"os"
+	"runtime"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
@@ -18,25 +21,176 @@ import (
 
 type JsEvalToolInput struct {
 	Code string `json:"code"`
+
+	// Language of Code: "js" (default) or "ts", which is transpiled to JavaScript first.
+	Language string `json:"language,omitempty"`
+
+	// Files are mounted read-only at / for the evaluation, keyed by their path.
+	// Code stays the entrypoint and may import them if the engine supports it.
+	Files map[string]string `json:"files,omitempty"`
+
+	// Labels tag the evaluation for logs and, for allowlisted keys, metrics,
+	// e.g. {"workflow": "nightly", "step": "3"}. They do not affect the run.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// ModuleType is how the engine parses the code: "script" (default) or
+	// "module" for an ES module, if the engine supports it.
+	ModuleType string `json:"moduleType,omitempty"`
+
+	// ContentType declares the media type of the engine stdout, e.g.
+	// "text/csv", for servers returning it as text. See ContextWithContentType.
+	ContentType string `json:"contentType,omitempty"`
+
+	// Seed replaces the seed of the randomness for servers running
+	// deterministically, to reproduce a run. See ContextWithSeed.
+	Seed *uint64 `json:"seed,omitempty"`
 }
 
 type JsEvalResultDto struct {
 	Result interface{} `json:"result"`
 	Error  *ErrorDto   `json:"error,omitempty"`
+
+	// OutputBytes is the number of bytes the engine wrote to stdout.
+	OutputBytes int `json:"outputBytes"`
+
+	// ResultEncoding is "base64" when Result is stdout base64 encoded, and
+	// empty otherwise. See WithResultEncoding.
+	ResultEncoding string `json:"resultEncoding,omitempty"`
+
+	// ContentType is the declared or sniffed media type of a text result.
+	// Only set WithTextResult, see WithContentTypeSniffing.
+	ContentType string `json:"contentType,omitempty"`
+
+	// OutputRef refers to the stdout kept in the OutputStore, replacing Result.
+	// Only set WithOutputStore.
+	OutputRef string `json:"outputRef,omitempty"`
+
+	// EvaluatedAt is when the evaluation started. Only set WithTimestamp.
+	EvaluatedAt time.Time `json:"evaluatedAt,omitzero"`
+
+	// AppliedLimits are the limits the evaluation ran under. Only set WithAppliedLimits.
+	AppliedLimits *AppliedLimits `json:"appliedLimits,omitempty"`
+
+	// PagesUsed is the peak number of 64 KiB memory pages of the evaluation.
+	// Only set WithPagesUsed.
+	PagesUsed uint32 `json:"pagesUsed,omitempty"`
+
+	// SoftTimeout is true when the evaluation finished after the deadline of
+	// its context, within the grace of WithSoftTimeout.
+	SoftTimeout bool `json:"softTimeout,omitempty"`
+
+	// QueueWaitMs is how long the evaluation waited for a slot of the
+	// concurrency limit, telling queueing apart from the time the code took.
+	// Only set WithQueueWait and WithConcurrencyLimit.
+	QueueWaitMs *int64 `json:"queueWaitMs,omitempty"`
+
+	// Code is the evaluated code, truncated to MaxEchoCodeBytes. Only set WithEchoCode.
+	Code string `json:"code,omitempty"`
+
+	// CodeSHA256 is the hex SHA-256 of the evaluated code. Only set WithCodeHash.
+	CodeSHA256 string `json:"codeSha256,omitempty"`
+
+	// unwrapped marshals a successful result as the bare Result. See WithUnwrappedResult.
+	unwrapped bool
+
+	// unescapedHTML keeps <, > and & unescaped. See WithUnescapedHTML.
+	unescapedHTML bool
+}
+
+// MarshalJSON encodes the envelope, or only Result for a successful
+// evaluation of an evaluator created WithUnwrappedResult.
+func (d JsEvalResultDto) MarshalJSON() ([]byte, error) {
+	if d.unwrapped && d.Error == nil {
+		return marshalJSON(d.Result, !d.unescapedHTML)
+	}
+	type envelope JsEvalResultDto
+	return marshalJSON(envelope(d), !d.unescapedHTML)
+}
+
+// marshalJSON is json.Marshal with the HTML escaping of strings optional.
+func marshalJSON(v any, escapeHTML bool) ([]byte, error) {
+	if escapeHTML {
+		return json.Marshal(v)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// AppliedLimits describes the limits in effect for one evaluation.
+type AppliedLimits struct {
+	// TimeoutMs is the time left until the context deadline when the evaluation
+	// started, or zero without a deadline.
+	TimeoutMs int64 `json:"timeoutMs"`
+	// MemoryBytes is the most linear memory the instance may grow to.
+	MemoryBytes uint64 `json:"memoryBytes"`
+	// CPUTimeoutMs is the CPU time limit, or zero without one.
+	CPUTimeoutMs int64 `json:"cpuTimeoutMs"`
 }
 
 type ErrorDto struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
+
+	// Terminated is true when the host stopped the evaluation (timeout,
+	// cancellation or CPU time limit) rather than the code exiting on its own.
+	Terminated bool `json:"terminated,omitempty"`
+
+	// ExitCode and Stderr describe a run whose output failed to parse.
+	// Only set WithParseErrorDetails.
+	ExitCode *uint32 `json:"exitCode,omitempty"`
+	Stderr   string  `json:"stderr,omitempty"`
+
+	// Diagnostics are the locations of the errors found in Message.
+	// Only set WithDiagnosticParser, when its engine format matched.
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+
+	// Details are the stable facts behind Message, keyed in snake_case, such
+	// as the exceeded limit, for clients asserting on them rather than on the
+	// prose. Which keys an error code carries is listed in the README.
+	Details map[string]interface{} `json:"details,omitempty"`
 }
 
+// ErrorCodeEngineFailure is the ErrorDto code of an engine module which
+// failed to run rather than exiting, such as by trapping, as opposed to host
+// failures of one evaluation such as output which is no JSON.
+const ErrorCodeEngineFailure = -23
+
 // Evaluator is the function type that will execute the WASM module.
 type Evaluator func(context.Context, string) JsEvalResultDto
 
 // NewEvaluator sets up wazero runtime and returns an Evaluator function.
 // It takes the WASM binary directly to be unit test friendly.
-func NewEvaluator(ctx context.Context, wasmBinary []byte, memoryLimitPages uint32) (Evaluator, func() error, error) {
-	rConfig := wazero.NewRuntimeConfig().WithCloseOnContextDone(true).WithMemoryLimitPages(memoryLimitPages)
+func NewEvaluator(ctx context.Context, wasmBinary []byte, memoryLimitPages uint32, opts ...Option) (Evaluator, func() error, error) {
+	cfg := newConfig(opts)
+	if err := validateSuccessExitCodes(cfg.invalidExitCodes); err != nil {
+		return nil, nil, err
+	}
+	if err := validateProtocol(cfg.protocol); err != nil {
+		return nil, nil, err
+	}
+	if err := validateResultEncoding(cfg.resultEncoding); err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.resolveResultSchema(); err != nil {
+		return nil, nil, err
+	}
+	if DetectWASIVersion(wasmBinary) == WASIPreview2 {
+		return nil, nil, ErrWASIPreview2Unsupported
+	}
+
+	rConfig := wazero.NewRuntimeConfig()
+	switch {
+	case cfg.runtimeConfig != nil:
+		rConfig = cfg.runtimeConfig
+	case cfg.interpreter:
+		rConfig = wazero.NewRuntimeConfigInterpreter()
+	}
+	rConfig = rConfig.WithCloseOnContextDone(true).WithMemoryLimitPages(memoryLimitPages)
 	r := wazero.NewRuntimeWithConfig(ctx, rConfig)
 	cleanup := func() error { return r.Close(context.Background()) }
 
@@ -45,80 +199,439 @@ func NewEvaluator(ctx context.Context, wasmBinary []byte, memoryLimitPages uint3
 		return nil, nil, fmt.Errorf("failed to instantiate wasi_snapshot_preview1: %w", err)
 	}
 
-	compiled, err := r.CompileModule(ctx, wasmBinary)
+	compileStartedAt := time.Now()
+	compiled, err := compileModule(ctx, r, wasmBinary, cfg.compileTimeout)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to compile WASM module: %w", err)
 	}
+	compileTime := time.Since(compileStartedAt)
 
-	log.Printf("WASM module compiled successfully.")
+	cfg.logf("WASM module compiled successfully in %v.", compileTime)
+	if cfg.onCompiled != nil {
+		cfg.onCompiled(compileTime)
+	}
+	if cfg.onHostFunctions != nil {
+		cfg.onHostFunctions(hostFunctions(r, compiled))
+	}
+	if cfg.onModuleSurface != nil {
+		cfg.onModuleSurface(moduleSurface(compiled))
+	}
+
+	probeCfg := cfg.probeConfig()
+	run := func(evalCtx context.Context, jsCode string) (result JsEvalResultDto) {
+		runStartedAt := time.Now()
+		cfg := cfg
+		if isProbe(evalCtx) {
+			cfg = probeCfg
+		}
+		jsCode, err := cfg.normalizeCode(jsCode)
+		if err != nil {
+			cfg.logf("Code rejected: %v", err)
+			return JsEvalResultDto{Error: &ErrorDto{Code: ErrorCodeInvalidEncoding, Message: err.Error()}}
+		}
+		moduleType := moduleTypeFrom(evalCtx)
+		if unsupported := cfg.checkModuleType(moduleType); unsupported != nil {
+			cfg.logf("Code rejected: %s", unsupported.Message)
+			return JsEvalResultDto{Error: unsupported}
+		}
+
+		if cfg.codePolicy != nil {
+			if err := cfg.codePolicy(jsCode); err != nil {
+				cfg.logf("Code rejected by policy: %v", err)
+				return JsEvalResultDto{Error: &ErrorDto{Code: ErrorCodePolicyViolation, Message: fmt.Sprintf("code rejected by policy: %v", err)}}
+			}
+		}
+
+		if cfg.maxStdinBytes > 0 && len(jsCode) > cfg.maxStdinBytes {
+			cfg.logf("Code rejected: %d bytes exceed the stdin limit of %d bytes", len(jsCode), cfg.maxStdinBytes)
+			return JsEvalResultDto{Error: &ErrorDto{
+				Code:    ErrorCodeInputTooLarge,
+				Message: fmt.Sprintf("code is too large (%d bytes), exceeding the limit of %d bytes", len(jsCode), cfg.maxStdinBytes),
+				Details: map[string]interface{}{"limit": cfg.maxStdinBytes, "size": len(jsCode)},
+			}}
+		}
+
+		if cfg.concurrencyLimit != nil {
+			waitStartedAt := time.Now()
+			err := cfg.concurrencyLimit.acquire(evalCtx)
+			waited := time.Since(waitStartedAt)
+			if cfg.onQueueWait != nil {
+				cfg.onQueueWait(waited)
+			}
+			if cfg.queueWait {
+				waitedMs := waited.Milliseconds()
+				defer func() { result.QueueWaitMs = &waitedMs }()
+			}
+			if err != nil {
+				if errors.Is(err, errQueueFull) {
+					cfg.logf("Evaluation rejected: all slots busy and the queue is full")
+					return JsEvalResultDto{Error: &ErrorDto{Code: ErrorCodeServerBusy, Message: "server busy, try again later"}}
+				}
+				cfg.logf("Evaluation gave up waiting for a slot: %v", err)
+				return JsEvalResultDto{Error: &ErrorDto{
+					Code:       ErrorCodeServerBusy,
+					Message:    fmt.Sprintf("server busy: gave up waiting for a free slot: %v", err),
+					Terminated: true,
+				}}
+			}
+			defer cfg.concurrencyLimit.release()
+		}
+
+		if cfg.memoryBudget != nil {
+			reservation := uint64(memoryLimitPages) * wasmPageSize
+			if !cfg.memoryBudget.reserve(reservation) {
+				cfg.logf("Evaluation rejected: memory budget exhausted")
+				return JsEvalResultDto{Error: &ErrorDto{Code: ErrorCodeMemoryCapacity, Message: "server at memory capacity, try again later"}}
+			}
+			defer cfg.memoryBudget.release(reservation)
+		}
+
+		if cfg.lockOSThread || cfg.cpuTimeLimit > 0 {
+			runtime.LockOSThread()
+			defer runtime.UnlockOSThread()
+		}
+
+		// softDeadline is the deadline WithSoftTimeout lets the run exceed.
+		var softDeadline time.Time
+		if cfg.softTimeoutGrace > 0 {
+			var stopGrace func()
+			evalCtx, softDeadline, stopGrace = cfg.withSoftTimeout(evalCtx)
+			defer stopGrace()
+			defer func() {
+				if !softDeadline.IsZero() && time.Now().After(softDeadline) && (result.Error == nil || !result.Error.Terminated) {
+					result.SoftTimeout = true
+				}
+			}()
+		}
+
+		if cfg.cpuTimeLimit > 0 {
+			cpuCtx, stopWatching := cfg.watchCPUTime(evalCtx, cfg.cpuTimeLimit)
+			defer stopWatching()
+			evalCtx = cpuCtx
+		}
+
+		stdin, err := cfg.engineInput(jsCode, moduleType)
+		if err != nil {
+			return JsEvalResultDto{Error: &ErrorDto{Code: -1, Message: fmt.Sprintf("failed to encode the engine input: %v", err)}}
+		}
+
+		stdoutBuf, stderrBuf := cfg.outputBuffer(cfg.stdoutBufferSize), cfg.outputBuffer(0)
+		defer cfg.releaseBuffer(stdoutBuf)
+		defer cfg.releaseBuffer(stderrBuf)
+		var stdout, stderr io.Writer = stdoutBuf, stderrBuf
+		var stored OutputWriter
+		var storedBytes *countingWriter
+		if cfg.outputStore != nil {
+			stored, err = cfg.outputStore.Create(evalCtx)
+			if err != nil {
+				cfg.logf("Failed to create the output: %v", err)
+				return JsEvalResultDto{Error: &ErrorDto{Code: -1, Message: fmt.Sprintf("failed to create the output: %v", err)}}
+			}
+			defer func() {
+				if stored != nil {
+					stored.Abort()
+				}
+			}()
+			storedBytes = &countingWriter{w: stored}
+			stdout = storedBytes
+		}
+		var streamed []*lineWriter
+		if sink := logSinkFrom(evalCtx); sink != nil {
+			stdoutLines := &lineWriter{stream: "stdout", sink: sink}
+			stderrLines := &lineWriter{stream: "stderr", sink: sink}
+			stdout = io.MultiWriter(stdout, stdoutLines)
+			stderr = io.MultiWriter(stderr, stderrLines)
+			streamed = append(streamed, stdoutLines, stderrLines)
+		}
+
+		evalCtx, stdout, stopLimitingLines := cfg.limitOutputLines(evalCtx, stdout)
+		defer stopLimitingLines()
+		stdout, drainOutput := cfg.drainableOutput(stdout)
 
-	evalFn := func(evalCtx context.Context, jsCode string) JsEvalResultDto {
-		var stdoutBuf, stderrBuf bytes.Buffer
 		moduleConfig := wazero.NewModuleConfig().
 			WithSysWalltime().
 			WithSysNanotime().
 			WithSysNanosleep().
-			WithStdin(strings.NewReader(jsCode)).
-			WithStdout(&stdoutBuf).
-			WithStderr(&stderrBuf)
+			WithRandSource(cfg.randSource(evalCtx)).
+			WithStdin(bytes.NewReader(stdin)).
+			WithStdout(stdout).
+			WithStderr(stderr)
+		if args := cfg.engineArgs(moduleType); args != nil {
+			moduleConfig = moduleConfig.WithArgs(args...)
+		}
+		for _, kv := range envFrom(evalCtx) {
+			moduleConfig = moduleConfig.WithEnv(kv[0], kv[1])
+		}
+		if fsConfig := cfg.fsConfig(evalCtx); fsConfig != nil {
+			moduleConfig = moduleConfig.WithFSConfig(fsConfig)
+		}
 
 		instance, e := r.InstantiateModule(evalCtx, compiled, moduleConfig)
+		drainOutput(evalCtx)
+		for _, lines := range streamed {
+			lines.flush()
+		}
 		if instance != nil {
 			defer func() { _ = instance.Close(evalCtx) }()
 		}
+		if cfg.pagesUsed && instance != nil && instance.Memory() != nil {
+			// Memory only grows, so its final size is the peak of the run.
+			pages := instance.Memory().Size() / wasmPageSize
+			defer func() { result.PagesUsed = pages }()
+		}
+
+		outputBytes := stdoutBuf.Bytes()
+		outputSize := len(outputBytes)
+		if storedBytes != nil {
+			outputSize = storedBytes.n
+		}
 
+		if errors.Is(context.Cause(evalCtx), errCPUTimeExceeded) {
+			cfg.logf("WASM execution exceeded the CPU time limit of %v", cfg.cpuTimeLimit)
+			return JsEvalResultDto{
+				Error: &ErrorDto{
+					Code:       ErrorCodeCPUTimeExceeded,
+					Message:    fmt.Sprintf("CPU time limit of %v exceeded", cfg.cpuTimeLimit),
+					Terminated: true,
+					Details:    map[string]interface{}{"cpu_time_limit_ms": cfg.cpuTimeLimit.Milliseconds()},
+				},
+				OutputBytes: outputSize,
+			}
+		}
+
+		if errors.Is(context.Cause(evalCtx), errTooManyOutputLines) {
+			cfg.logf("WASM execution exceeded the output line limit of %d", cfg.maxOutputLines)
+			return JsEvalResultDto{
+				Error: &ErrorDto{
+					Code:       ErrorCodeTooManyOutputLines,
+					Message:    fmt.Sprintf("output line limit of %d exceeded", cfg.maxOutputLines),
+					Terminated: true,
+					Details:    map[string]interface{}{"max_output_lines": cfg.maxOutputLines},
+				},
+				OutputBytes: outputSize,
+			}
+		}
+
+		var exitCode uint32
 		if e != nil {
 			var exitErr *sys.ExitError
-			if errors.As(e, &exitErr) {
-				errorMsg := stderrBuf.String()
-				log.Printf("WASM execution failed with exit code %d: %s", exitErr.ExitCode(), errorMsg)
-				return JsEvalResultDto{Error: &ErrorDto{Code: int(exitErr.ExitCode()), Message: errorMsg}}
+			if !errors.As(e, &exitErr) {
+				cfg.logf("Failed to instantiate WASM module: %v", e)
+				failure := &ErrorDto{Code: ErrorCodeEngineFailure, Message: fmt.Sprintf("WASM execution failed: %v", e)}
+				cfg.markStackOverflow(failure, nil)
+				return JsEvalResultDto{Error: failure, OutputBytes: outputSize}
+			}
+			exitCode = exitErr.ExitCode()
+		}
+
+		if reason, terminated := terminationReason(exitCode); terminated {
+			cfg.logf("WASM execution terminated by host: %s", reason)
+			errorMsg := "terminated by host: " + reason
+			if stderrBuf.Len() > 0 {
+				errorMsg += "\n" + cfg.stderrText(stderrBuf.String())
+			}
+			details := map[string]interface{}{"reason": strings.ReplaceAll(reason, " ", "_")}
+			if deadline, ok := evalCtx.Deadline(); ok && exitCode == sys.ExitCodeDeadlineExceeded {
+				details["timeout_ms"] = deadline.Sub(runStartedAt).Milliseconds()
+				if !softDeadline.IsZero() {
+					details["timeout_ms"] = softDeadline.Sub(runStartedAt).Milliseconds()
+					details["grace_ms"] = cfg.softTimeoutGrace.Milliseconds()
+				}
+			}
+			return JsEvalResultDto{
+				Error: &ErrorDto{
+					Code:       int(exitCode),
+					Message:    errorMsg,
+					Terminated: true,
+					Details:    details,
+				},
+				OutputBytes: outputSize,
+			}
+		}
+
+		if !cfg.isSuccess(exitCode) {
+			errorMsg := cfg.stderrText(stderrBuf.String())
+			cfg.logf("WASM execution failed with exit code %d: %s", exitCode, errorMsg)
+			failure := &ErrorDto{Code: int(exitCode), Message: errorMsg}
+			if cfg.probe {
+				failure.ExitCode = &exitCode
+			}
+			if cfg.diagnosticParser != nil {
+				failure.Diagnostics = cfg.diagnosticParser(errorMsg)
+			}
+			cfg.markStackOverflow(failure, &exitCode)
+			return JsEvalResultDto{Error: failure, OutputBytes: outputSize}
+		}
+
+		if cfg.failOnStderr && stderrBuf.Len() > 0 {
+			errorMsg := cfg.stderrText(stderrBuf.String())
+			cfg.logf("WASM execution wrote to stderr: %s", errorMsg)
+			failure := &ErrorDto{Code: ErrorCodeUnexpectedStderr, Message: errorMsg, ExitCode: &exitCode}
+			if cfg.diagnosticParser != nil {
+				failure.Diagnostics = cfg.diagnosticParser(errorMsg)
+			}
+			return JsEvalResultDto{Error: failure, OutputBytes: outputSize}
+		}
+
+		if stored != nil {
+			ref, err := stored.Commit()
+			stored = nil // committed or removed by Commit, nothing to abort
+			if err != nil {
+				cfg.logf("Failed to store the output: %v", err)
+				return JsEvalResultDto{Error: &ErrorDto{Code: -1, Message: fmt.Sprintf("failed to store the output: %v", err)}, OutputBytes: outputSize}
+			}
+			return JsEvalResultDto{OutputBytes: outputSize, OutputRef: ref}
+		}
+
+		payload, engineErr := cfg.engineOutput(outputBytes)
+		if engineErr != nil {
+			if engineErr.Code == -1 {
+				cfg.addParseErrorDetails(engineErr, exitCode, stderrBuf.String())
+			}
+			return JsEvalResultDto{Error: engineErr, OutputBytes: outputSize}
+		}
+
+		if !cfg.textResult {
+			if tooMany := cfg.checkResultElements(payload); tooMany != nil {
+				cfg.logf("Result rejected: %s", tooMany.Message)
+				return JsEvalResultDto{Error: tooMany, OutputBytes: outputSize}
+			}
+			if duplicate := cfg.checkDuplicateKeys(payload); duplicate != nil {
+				cfg.logf("Result rejected: %s", duplicate.Message)
+				return JsEvalResultDto{Error: duplicate, OutputBytes: outputSize}
 			}
-			log.Printf("Failed to instantiate WASM module: %v", e)
-			return JsEvalResultDto{Error: &ErrorDto{Code: -1, Message: fmt.Sprintf("WASM execution failed: %v", e)}}
 		}
 
 		var rawJsonOutput interface{}
-		outputBytes := stdoutBuf.Bytes()
-		if err := json.Unmarshal(outputBytes, &rawJsonOutput); err != nil {
-			log.Printf("Failed to parse raw JSON from WASM stdout: %v. Raw output: %s", err, string(outputBytes))
-			return JsEvalResultDto{Error: &ErrorDto{Code: -1, Message: "Failed to parse successful WASM output as JSON"}}
+		var resultEncoding, contentType string
+		if cfg.textResult {
+			rawJsonOutput, resultEncoding = cfg.textOutput(payload)
+			contentType = cfg.contentType(evalCtx, payload)
+		} else if rawJsonOutput, err = cfg.parseOutput(payload); err != nil {
+			cfg.logf("Failed to parse raw JSON from WASM stdout: %v. Raw output: %s", err, string(outputBytes))
+			parseErr := &ErrorDto{Code: -1, Message: "Failed to parse successful WASM output as JSON"}
+			cfg.addParseErrorDetails(parseErr, exitCode, stderrBuf.String())
+			return JsEvalResultDto{Error: parseErr, OutputBytes: outputSize}
 		}
 
-		return JsEvalResultDto{Result: rawJsonOutput, Error: nil}
+		if !cfg.textResult {
+			if notObject := cfg.checkObjectResult(rawJsonOutput); notObject != nil {
+				cfg.logf("Result rejected: %s", notObject.Message)
+				return JsEvalResultDto{Error: notObject, OutputBytes: outputSize}
+			}
+		}
+
+		if violation := cfg.checkResultSchema(rawJsonOutput); violation != nil {
+			cfg.logf("Result rejected: %s", violation.Message)
+			return JsEvalResultDto{Error: violation, OutputBytes: outputSize}
+		}
+
+		return JsEvalResultDto{
+			Result:         rawJsonOutput,
+			Error:          nil,
+			ResultEncoding: resultEncoding,
+			ContentType:    contentType,
+			OutputBytes:    outputSize,
+		}
+	}
+
+	evalFn := func(evalCtx context.Context, jsCode string) JsEvalResultDto {
+		startedAt := cfg.clock()
+		var limits *AppliedLimits
+		if cfg.appliedLimits {
+			limits = &AppliedLimits{
+				MemoryBytes:  uint64(memoryLimitPages) * wasmPageSize,
+				CPUTimeoutMs: cfg.cpuTimeLimit.Milliseconds(),
+			}
+			if deadline, ok := evalCtx.Deadline(); ok {
+				limits.TimeoutMs = max(time.Until(deadline).Milliseconds(), 0)
+			}
+		}
+
+		result := run(evalCtx, jsCode)
+		if cfg.timestamp {
+			result.EvaluatedAt = startedAt
+		}
+		result.AppliedLimits = limits
+		if cfg.echoCode {
+			result.Code = truncate(jsCode, MaxEchoCodeBytes)
+		}
+		if cfg.codeHash {
+			sum := sha256.Sum256([]byte(jsCode))
+			result.CodeSHA256 = hex.EncodeToString(sum[:])
+		}
+		result.unwrapped = cfg.unwrappedResult
+		result.unescapedHTML = cfg.unescapedHTML
+		return result
 	}
 
 	return evalFn, cleanup, nil
 }
 
-// LoadWasmBinary reads the WASM file from the given path with a size limit.
-const bytesInMiB = 1024 * 1024
-
-func LoadWasmBinary(wasmFilePath string, maxWasmSize uint) ([]byte, error) {
-	f, err := os.Open(wasmFilePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open WASM file from %s: %w", wasmFilePath, err)
+// terminationReason reports whether the exit code is one wazero uses when it
+// closes a module because its context is done, rather than a code the module exited with.
+func terminationReason(exitCode uint32) (string, bool) {
+	switch exitCode {
+	case sys.ExitCodeDeadlineExceeded:
+		return "deadline exceeded", true
+	case sys.ExitCodeContextCanceled:
+		return "context canceled", true
+	default:
+		return "", false
 	}
-	defer func() {
-		if err := f.Close(); err != nil {
-			log.Printf("warning: failed to close wasm file %s: %v", wasmFilePath, err)
-		}
-	}()
+}
 
-	fileInfo, err := f.Stat()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get WASM file info from %s: %w", wasmFilePath, err)
+var errInvalidJSON = errors.New("invalid JSON")
+
+// parseOutput decodes the stdout of a successful run.
+func (c *config) parseOutput(output []byte) (interface{}, error) {
+	if c.rawResult {
+		// Marshaling an invalid RawMessage fails later, so the cheap check is not optional.
+		if !json.Valid(output) {
+			if lines, ok := c.jsonLinesOutput(output, true); ok {
+				return lines, nil
+			}
+			return nil, errInvalidJSON
+		}
+		if c.reuseBuffers {
+			// The buffer goes back to the pool once the run ends.
+			output = bytes.Clone(output)
+		}
+		return json.RawMessage(output), nil
 	}
 
-	maxBytes := int64(maxWasmSize) * bytesInMiB
-	if fileInfo.Size() > maxBytes {
-		return nil, fmt.Errorf("WASM file %s is too large (%d bytes), exceeding max size of %d MiB", wasmFilePath, fileInfo.Size(), maxWasmSize)
+	var decoded interface{}
+	if err := json.Unmarshal(output, &decoded); err != nil {
+		if lines, ok := c.jsonLinesOutput(output, false); ok {
+			return lines, nil
+		}
+		return nil, err
 	}
+	return decoded, nil
+}
 
-	wasmBinary, err := io.ReadAll(f)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read WASM file from %s: %w", wasmFilePath, err)
+// maxStderrExcerpt bounds the stderr attached to parse errors.
+const maxStderrExcerpt = 1024
+
+// addParseErrorDetails attaches the exit code and the start of stderr to the
+// error of a successful run whose output failed to parse.
+func (c *config) addParseErrorDetails(e *ErrorDto, exitCode uint32, stderr string) {
+	if !c.parseErrorDetails {
+		return
 	}
+	e.ExitCode = &exitCode
+	stderr = c.stderrText(stderr)
+	e.Stderr = truncate(stderr, maxStderrExcerpt)
+}
 
-	return wasmBinary, nil
+// truncate cuts s to at most limit bytes at a rune boundary, marking the cut with "…".
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "…"
 }
@@ -1,9 +1,23 @@
 package jseval
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/sys"
 )
 
 func TestNewEvaluator(t *testing.T) {
@@ -59,3 +73,995 @@ func TestNewEvaluator(t *testing.T) {
 		t.Logf("Received expected error: %s", result.Error.Message)
 	})
 }
+
+// uleb128 encodes v as an unsigned LEB128 integer.
+func uleb128(v uint32) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+// sleb128 encodes v as a signed LEB128 integer.
+func sleb128(v int32) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func wasmSection(id byte, payload []byte) []byte {
+	return append(append([]byte{id}, uleb128(uint32(len(payload)))...), payload...)
+}
+
+func wasmName(s string) []byte {
+	return append(uleb128(uint32(len(s))), s...)
+}
+
+// writeAndExitWasm builds a WASI command module which writes stdout to fd 1
+// and then calls proc_exit(exitCode). A negative exitCode returns normally
+// from _start instead.
+func writeAndExitWasm(stdout string, exitCode int32) []byte {
+	return writeFdAndExitWasm(1, stdout, exitCode)
+}
+
+// writeFdAndExitWasm is writeAndExitWasm writing to the file descriptor fd.
+func writeFdAndExitWasm(fd byte, output string, exitCode int32) []byte {
+	const dataOffset = 16
+
+	types := []byte{3}
+	types = append(types, 0x60, 4, 0x7f, 0x7f, 0x7f, 0x7f, 1, 0x7f) // fd_write
+	types = append(types, 0x60, 1, 0x7f, 0)                         // proc_exit
+	types = append(types, 0x60, 0, 0)                               // _start
+
+	imports := []byte{2}
+	imports = append(imports, wasmName("wasi_snapshot_preview1")...)
+	imports = append(imports, wasmName("fd_write")...)
+	imports = append(imports, 0x00, 0)
+	imports = append(imports, wasmName("wasi_snapshot_preview1")...)
+	imports = append(imports, wasmName("proc_exit")...)
+	imports = append(imports, 0x00, 1)
+
+	exports := []byte{2}
+	exports = append(exports, wasmName("memory")...)
+	exports = append(exports, 0x02, 0)
+	exports = append(exports, wasmName("_start")...)
+	exports = append(exports, 0x00, 2)
+
+	// fd_write(fd, iovs=0, iovs_len=1, nwritten=8)
+	body := []byte{0}
+	body = append(body, 0x41, fd, 0x41, 0, 0x41, 1, 0x41, 8, 0x10, 0, 0x1a)
+	if exitCode >= 0 {
+		body = append(body, 0x41)
+		body = append(body, sleb128(exitCode)...)
+		body = append(body, 0x10, 1)
+	}
+	body = append(body, 0x0b)
+	code := append([]byte{1}, uleb128(uint32(len(body)))...)
+	code = append(code, body...)
+
+	// iovec{buf: dataOffset, len: len(output)} followed by the payload.
+	segment := make([]byte, dataOffset, dataOffset+len(output))
+	segment[0] = dataOffset
+	copy(segment[4:8], []byte{byte(len(output)), byte(len(output) >> 8), byte(len(output) >> 16), byte(len(output) >> 24)})
+	segment = append(segment, output...)
+	data := []byte{1, 0, 0x41, 0, 0x0b}
+	data = append(data, uleb128(uint32(len(segment)))...)
+	data = append(data, segment...)
+
+	wasm := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	wasm = append(wasm, wasmSection(1, types)...)
+	wasm = append(wasm, wasmSection(2, imports)...)
+	wasm = append(wasm, wasmSection(3, []byte{1, 2})...)
+	wasm = append(wasm, wasmSection(5, []byte{1, 0, 1})...)
+	wasm = append(wasm, wasmSection(7, exports)...)
+	wasm = append(wasm, wasmSection(10, code)...)
+	wasm = append(wasm, wasmSection(11, data)...)
+	return wasm
+}
+
+func TestWithSuccessExitCodes(t *testing.T) {
+	memoryLimitPages := uint32(1)
+
+	tests := []struct {
+		name      string
+		exitCode  int32
+		opts      []Option
+		wantError bool
+		wantCode  int
+	}{
+		{name: "NormalReturnIsSuccessByDefault", exitCode: -1},
+		{name: "ExitZeroIsSuccessByDefault", exitCode: 0},
+		{name: "CustomCodeIsErrorByDefault", exitCode: 3, wantError: true, wantCode: 3},
+		{name: "CustomCodeIsSuccessWhenConfigured", exitCode: 3, opts: []Option{WithSuccessExitCodes(0, 3)}},
+		{name: "ZeroIsErrorWhenNotConfigured", exitCode: 0, opts: []Option{WithSuccessExitCodes(3)}, wantError: true, wantCode: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			wasm := writeAndExitWasm(`{"answer":42}`, tc.exitCode)
+			evaluator, cleanup, err := NewEvaluator(ctx, wasm, memoryLimitPages, tc.opts...)
+			if err != nil {
+				t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+			}
+			defer func() { _ = cleanup() }()
+
+			result := evaluator(ctx, "")
+			if tc.wantError {
+				if result.Error == nil {
+					t.Fatalf("evaluator() was expected to return an error, got result %v", result.Result)
+				}
+				if result.Error.Code != tc.wantCode {
+					t.Errorf("unexpected error code. Got: %d, Want: %d", result.Error.Code, tc.wantCode)
+				}
+				return
+			}
+
+			if result.Error != nil {
+				t.Fatalf("evaluator() returned an unexpected error: %+v", result.Error)
+			}
+			obj, ok := result.Result.(map[string]interface{})
+			if !ok || obj["answer"] != float64(42) {
+				t.Errorf("unexpected result: %#v", result.Result)
+			}
+		})
+	}
+}
+
+func TestWithSuccessExitCodesOutOfRange(t *testing.T) {
+	wasm := writeAndExitWasm(`{}`, -1)
+	codes := []int{-1, math.MinInt32}
+	if strconv.IntSize == 64 {
+		beyond := 1
+		codes = append(codes, beyond<<32)
+	}
+	for _, code := range codes {
+		if _, _, err := NewEvaluator(context.Background(), wasm, 1, WithSuccessExitCodes(0, code)); err == nil {
+			t.Errorf("expected NewEvaluator() to reject the success exit code %d", code)
+		}
+	}
+
+	// A later option replaces the invalid codes, and codes beyond 8 bits are
+	// kept as the engine reports them.
+	evaluator, cleanup, err := NewEvaluator(context.Background(), writeAndExitWasm(`{}`, 300), 1, WithSuccessExitCodes(-1), WithSuccessExitCodes(0, 300))
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+	if result := evaluator(context.Background(), ""); result.Error != nil {
+		t.Errorf("evaluator() returned an unexpected error: %+v", result.Error)
+	}
+}
+
+func TestOutputBytes(t *testing.T) {
+	ctx := context.Background()
+	output := `{"items":[1,2,3]}`
+
+	for _, exitCode := range []int32{0, 1} {
+		evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(output, exitCode), 1)
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+
+		result := evaluator(ctx, "")
+		if result.OutputBytes != len(output) {
+			t.Errorf("exit code %d: unexpected OutputBytes. Got: %d, Want: %d", exitCode, result.OutputBytes, len(output))
+		}
+
+		if err := cleanup(); err != nil {
+			t.Errorf("cleanup() returned an unexpected error: %v", err)
+		}
+	}
+}
+
+func TestWithCodePolicy(t *testing.T) {
+	ctx := context.Background()
+	policy := DenyPattern(regexp.MustCompile(`while\s*\(\s*true\s*\)`))
+	evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(`"ok"`, -1), 1, WithCodePolicy(policy))
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	t.Run("DeniedCodeIsRejected", func(t *testing.T) {
+		result := evaluator(ctx, "while (true) {}")
+		if result.Error == nil {
+			t.Fatal("evaluator() was expected to reject the code, but it did not")
+		}
+		if result.Error.Code != ErrorCodePolicyViolation {
+			t.Errorf("unexpected error code. Got: %d, Want: %d", result.Error.Code, ErrorCodePolicyViolation)
+		}
+	})
+
+	t.Run("AllowedCodeIsEvaluated", func(t *testing.T) {
+		result := evaluator(ctx, "1 + 1")
+		if result.Error != nil {
+			t.Fatalf("evaluator() returned an unexpected error: %+v", result.Error)
+		}
+		if result.Result != "ok" {
+			t.Errorf("unexpected result: %#v", result.Result)
+		}
+	})
+}
+
+func TestProbeCapabilities(t *testing.T) {
+	probes := []FeatureProbe{{Name: "yes", Code: "true"}, {Name: "no", Code: "false"}}
+	evaluator := func(_ context.Context, code string) JsEvalResultDto {
+		return JsEvalResultDto{Result: code == "true"}
+	}
+
+	capabilities := ProbeCapabilities(context.Background(), evaluator, probes, time.Second)
+	if !capabilities["yes"] || capabilities["no"] {
+		t.Errorf("unexpected capabilities: %v", capabilities)
+	}
+}
+
+func TestWithLockOSThread(t *testing.T) {
+	ctx := context.Background()
+	evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(`[1]`, -1), 1, WithLockOSThread())
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	if result := evaluator(ctx, ""); result.Error != nil {
+		t.Fatalf("evaluator() returned an unexpected error: %+v", result.Error)
+	}
+}
+
+func TestContextWithLogSink(t *testing.T) {
+	ctx := context.Background()
+	evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm("first\r\nsecond\nlast", -1), 1)
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	var lines []string
+	sinkCtx := ContextWithLogSink(ctx, func(stream, line string) {
+		lines = append(lines, stream+":"+line)
+	})
+	result := evaluator(sinkCtx, "")
+	if result.OutputBytes != len("first\r\nsecond\nlast") {
+		t.Errorf("the output was not captured while streaming: %d bytes", result.OutputBytes)
+	}
+
+	want := []string{"stdout:first", "stdout:second", "stdout:last"}
+	if strings.Join(lines, ",") != strings.Join(want, ",") {
+		t.Errorf("unexpected streamed lines.\nGot:  %v\nWant: %v", lines, want)
+	}
+}
+
+// busyLoopWasm builds a WASI command module whose _start never returns.
+func busyLoopWasm() []byte {
+	wasm := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	wasm = append(wasm, wasmSection(1, []byte{1, 0x60, 0, 0})...)
+	wasm = append(wasm, wasmSection(3, []byte{1, 0})...)
+	wasm = append(wasm, wasmSection(7, append(append([]byte{1}, wasmName("_start")...), 0x00, 0))...)
+	body := []byte{0, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b} // loop br 0 end
+	wasm = append(wasm, wasmSection(10, append([]byte{1, byte(len(body))}, body...))...)
+	return wasm
+}
+
+func TestWithCPUTimeLimit(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("CPU time limits are only supported on linux")
+	}
+
+	ctx := context.Background()
+	evaluator, cleanup, err := NewEvaluator(ctx, busyLoopWasm(), 1, WithCPUTimeLimit(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	wallCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	result := evaluator(wallCtx, "")
+	if result.Error == nil {
+		t.Fatal("evaluator() was expected to stop the busy loop, but it returned a result")
+	}
+	if result.Error.Code != ErrorCodeCPUTimeExceeded {
+		t.Errorf("unexpected error code. Got: %d (%s), Want: %d", result.Error.Code, result.Error.Message, ErrorCodeCPUTimeExceeded)
+	}
+}
+
+func TestWithTimestamp(t *testing.T) {
+	ctx := context.Background()
+	fixed := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	wasm := writeAndExitWasm(`1`, -1)
+
+	t.Run("OmittedByDefault", func(t *testing.T) {
+		evaluator, cleanup, err := NewEvaluator(ctx, wasm, 1)
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+		defer func() { _ = cleanup() }()
+
+		encoded, err := json.Marshal(evaluator(ctx, ""))
+		if err != nil {
+			t.Fatalf("failed to encode the result: %v", err)
+		}
+		if strings.Contains(string(encoded), "evaluatedAt") {
+			t.Errorf("unexpected timestamp in the default output: %s", encoded)
+		}
+	})
+
+	t.Run("StampedFromClock", func(t *testing.T) {
+		evaluator, cleanup, err := NewEvaluator(ctx, wasm, 1, WithTimestamp(), WithClock(func() time.Time { return fixed }))
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+		defer func() { _ = cleanup() }()
+
+		encoded, err := json.Marshal(evaluator(ctx, ""))
+		if err != nil {
+			t.Fatalf("failed to encode the result: %v", err)
+		}
+		if !strings.Contains(string(encoded), `"evaluatedAt":"2025-01-02T03:04:05Z"`) {
+			t.Errorf("unexpected timestamp in the output: %s", encoded)
+		}
+	})
+}
+
+// growMemoryWasm is a module with a 1 page memory whose _start grows it by pages.
+func growMemoryWasm(pages byte) []byte {
+	wasm := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	wasm = append(wasm, wasmSection(1, []byte{1, 0x60, 0, 0})...)
+	wasm = append(wasm, wasmSection(3, []byte{1, 0})...)
+	wasm = append(wasm, wasmSection(5, []byte{1, 0x00, 1})...)
+	wasm = append(wasm, wasmSection(7, append(append([]byte{1}, wasmName("_start")...), 0x00, 0))...)
+	body := []byte{0, 0x41, pages, 0x40, 0x00, 0x1a, 0x0b} // i32.const pages memory.grow drop end
+	wasm = append(wasm, wasmSection(10, append([]byte{1, byte(len(body))}, body...))...)
+	return wasm
+}
+
+func TestWithPagesUsed(t *testing.T) {
+	ctx := context.Background()
+	evaluator, cleanup, err := NewEvaluator(ctx, growMemoryWasm(2), 8, WithPagesUsed())
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	if result := evaluator(ctx, ""); result.PagesUsed != 3 {
+		t.Errorf("expected 3 pages after growing 1 page by 2, got %d", result.PagesUsed)
+	}
+
+	plain, cleanupPlain, err := NewEvaluator(ctx, growMemoryWasm(2), 8)
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanupPlain() }()
+	if result := plain(ctx, ""); result.PagesUsed != 0 {
+		t.Errorf("expected no page count by default, got %d", result.PagesUsed)
+	}
+	// The fixture leaves through proc_exit, closing its instance.
+	if result := newFixtureEvaluator(t, WithPagesUsed())(fixtureExitCtx(ctx, 3), "1"); result.PagesUsed == 0 {
+		t.Errorf("expected a page count after an exit, got %+v", result)
+	}
+}
+
+func TestWithFailOnStderr(t *testing.T) {
+	ctx := context.Background()
+
+	// The fixture always logs to stderr before echoing its input.
+	if result := newFixtureEvaluator(t)(ctx, "1"); result.Error != nil {
+		t.Fatalf("expected stderr to be ignored by default, got %+v", result.Error)
+	}
+	result := newFixtureEvaluator(t, WithFailOnStderr())(ctx, "1")
+	if result.Error == nil || result.Error.Code != ErrorCodeUnexpectedStderr || result.Error.Message != fixtureLogLine {
+		t.Fatalf("expected stderr to fail the run, got %+v", result)
+	}
+	if result.Error.ExitCode == nil || *result.Error.ExitCode != 0 {
+		t.Errorf("expected exit code 0 to be kept, got %v", result.Error.ExitCode)
+	}
+	if result.Result != nil {
+		t.Errorf("expected no result, got %v", result.Result)
+	}
+
+	evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(`1`, -1), 1, WithFailOnStderr())
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+	if result := evaluator(ctx, ""); result.Error != nil {
+		t.Errorf("expected a run without stderr to succeed, got %+v", result.Error)
+	}
+}
+
+func TestEchoCode(t *testing.T) {
+	ctx := context.Background()
+	evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(`1`, -1), 1, WithEchoCode(), WithCodeHash())
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	result := evaluator(ctx, "1")
+	if result.Code != "1" {
+		t.Errorf("expected the code to be echoed, got %q", result.Code)
+	}
+	// sha256("1")
+	if want := "6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b"; result.CodeSHA256 != want {
+		t.Errorf("expected hash %s, got %s", want, result.CodeSHA256)
+	}
+
+	long := evaluator(ctx, strings.Repeat("x", MaxEchoCodeBytes+1))
+	if len(long.Code) != MaxEchoCodeBytes+len("…") {
+		t.Errorf("expected long code to be truncated, got %d bytes", len(long.Code))
+	}
+
+	plain, cleanupPlain, err := NewEvaluator(ctx, writeAndExitWasm(`1`, -1), 1)
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanupPlain() }()
+	encoded, err := json.Marshal(plain(ctx, "1"))
+	if err != nil {
+		t.Fatalf("failed to encode the result: %v", err)
+	}
+	if strings.Contains(string(encoded), "code") {
+		t.Errorf("unexpected code in the default output: %s", encoded)
+	}
+}
+
+func TestWithMemoryBudget(t *testing.T) {
+	ctx := context.Background()
+	budget := NewMemoryBudget(wasmPageSize) // room for a single 1-page instance
+	evaluator, cleanup, err := NewEvaluator(ctx, busyLoopWasm(), 1, WithMemoryBudget(budget))
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	runningCtx, stopRunning := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		evaluator(runningCtx, "")
+	}()
+	for budget.Used() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	result := evaluator(ctx, "")
+	if result.Error == nil || result.Error.Code != ErrorCodeMemoryCapacity {
+		t.Errorf("expected a memory capacity error, got: %+v", result.Error)
+	}
+
+	stopRunning()
+	<-done
+	if used := budget.Used(); used != 0 {
+		t.Errorf("the reservation was not released: %d bytes still used", used)
+	}
+}
+
+func TestWithConcurrencyLimit(t *testing.T) {
+	ctx := context.Background()
+	limit := NewConcurrencyLimit(1, 1)
+	evaluator, cleanup, err := NewEvaluator(ctx, busyLoopWasm(), 1, WithConcurrencyLimit(limit))
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	runningCtx, stopRunning := context.WithCancel(ctx)
+	defer stopRunning()
+	var done sync.WaitGroup
+	for range 2 { // one running, one queued
+		done.Go(func() { evaluator(runningCtx, "") })
+	}
+	for limit.Running() != 1 || limit.Queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	startedAt := time.Now()
+	result := evaluator(ctx, "")
+	if result.Error == nil || result.Error.Code != ErrorCodeServerBusy || result.Error.Terminated {
+		t.Errorf("expected an immediate server busy error, got: %+v", result.Error)
+	}
+	if elapsed := time.Since(startedAt); elapsed > time.Second {
+		t.Errorf("the rejection took %v, expected it to be immediate", elapsed)
+	}
+
+	stopRunning()
+	done.Wait()
+	if limit.Running() != 0 || limit.Queued() != 0 {
+		t.Errorf("slots were not released: %d running, %d queued", limit.Running(), limit.Queued())
+	}
+}
+
+func TestWithQueueWait(t *testing.T) {
+	ctx := context.Background()
+	limit := NewConcurrencyLimit(1, 1)
+	var observed atomic.Int64
+	evaluator, cleanup, err := NewEvaluator(ctx, busyLoopWasm(), 1,
+		WithConcurrencyLimit(limit),
+		WithQueueWait(),
+		WithOnQueueWait(func(d time.Duration) { observed.Add(int64(d)) }),
+	)
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	runningCtx, stopRunning := context.WithCancel(ctx)
+	defer stopRunning()
+	running := make(chan JsEvalResultDto, 1)
+	go func() { running <- evaluator(runningCtx, "") }()
+	for limit.Running() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	queuedCtx, stopQueued := context.WithCancel(ctx)
+	defer stopQueued()
+	queued := make(chan JsEvalResultDto, 1)
+	go func() { queued <- evaluator(queuedCtx, "") }()
+	for limit.Queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The queued evaluation gets the slot once the running one stops.
+	time.Sleep(50 * time.Millisecond)
+	stopRunning()
+	first := <-running
+	for limit.Queued() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	stopQueued()
+	second := <-queued
+
+	if first.QueueWaitMs == nil || *first.QueueWaitMs > 10 {
+		t.Errorf("the first evaluation should not have waited, got: %v", first.QueueWaitMs)
+	}
+	if second.QueueWaitMs == nil || *second.QueueWaitMs < 40 {
+		t.Errorf("the queued evaluation should have waited about 50 ms, got: %v", second.QueueWaitMs)
+	}
+	if observed.Load() < int64(40*time.Millisecond) {
+		t.Errorf("observed queue wait = %v, want at least 40ms", time.Duration(observed.Load()))
+	}
+}
+
+func TestWithAppliedLimits(t *testing.T) {
+	ctx := context.Background()
+	evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(`1`, -1), 2, WithAppliedLimits(), WithCPUTimeLimit(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	limits := evaluator(timeoutCtx, "").AppliedLimits
+	if limits == nil {
+		t.Fatal("AppliedLimits was not set")
+	}
+	if limits.MemoryBytes != 2*wasmPageSize {
+		t.Errorf("unexpected MemoryBytes: %d", limits.MemoryBytes)
+	}
+	if limits.CPUTimeoutMs != 50 {
+		t.Errorf("unexpected CPUTimeoutMs: %d", limits.CPUTimeoutMs)
+	}
+	if limits.TimeoutMs <= 0 || limits.TimeoutMs > time.Minute.Milliseconds() {
+		t.Errorf("unexpected TimeoutMs: %d", limits.TimeoutMs)
+	}
+}
+
+func TestTerminated(t *testing.T) {
+	ctx := context.Background()
+	evaluator, cleanup, err := NewEvaluator(ctx, busyLoopWasm(), 1)
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	t.Run("ContextCanceled", func(t *testing.T) {
+		cancelCtx, cancel := context.WithCancel(ctx)
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		result := evaluator(cancelCtx, "")
+		if result.Error == nil || !result.Error.Terminated {
+			t.Fatalf("expected a host termination, got: %+v", result.Error)
+		}
+		if result.Error.Code != int(sys.ExitCodeContextCanceled) {
+			t.Errorf("unexpected error code: %d", result.Error.Code)
+		}
+		if reason := result.Error.Details["reason"]; reason != "context_canceled" {
+			t.Errorf("unexpected reason: %v", reason)
+		}
+	})
+
+	t.Run("DeadlineExceeded", func(t *testing.T) {
+		timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+
+		result := evaluator(timeoutCtx, "")
+		if result.Error == nil || !result.Error.Terminated {
+			t.Fatalf("expected a host termination, got: %+v", result.Error)
+		}
+		if result.Error.Code != int(sys.ExitCodeDeadlineExceeded) {
+			t.Errorf("unexpected error code: %d", result.Error.Code)
+		}
+		if reason := result.Error.Details["reason"]; reason != "deadline_exceeded" {
+			t.Errorf("unexpected reason: %v", reason)
+		}
+		if timeoutMs, ok := result.Error.Details["timeout_ms"].(int64); !ok || timeoutMs <= 0 || timeoutMs > 20 {
+			t.Errorf("unexpected timeout_ms: %v", result.Error.Details["timeout_ms"])
+		}
+	})
+
+	t.Run("OwnExitIsNotTerminated", func(t *testing.T) {
+		exiting, cleanupExiting, err := NewEvaluator(ctx, writeAndExitWasm("", 7), 1)
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+		defer func() { _ = cleanupExiting() }()
+
+		result := exiting(ctx, "")
+		if result.Error == nil || result.Error.Terminated {
+			t.Errorf("expected a non-terminated error, got: %+v", result.Error)
+		}
+	})
+}
+
+func TestWithMaxStdinBytes(t *testing.T) {
+	ctx := context.Background()
+	evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(`true`, -1), 1, WithMaxStdinBytes(16))
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	if result := evaluator(ctx, "1 + 1"); result.Error != nil {
+		t.Errorf("small code was rejected: %+v", result.Error)
+	}
+
+	result := evaluator(ctx, strings.Repeat("x", 17))
+	if result.Error == nil || result.Error.Code != ErrorCodeInputTooLarge {
+		t.Fatalf("expected an input too large error, got: %+v", result.Error)
+	}
+	if limit, size := result.Error.Details["limit"], result.Error.Details["size"]; limit != 16 || size != 17 {
+		t.Errorf("unexpected details: %v", result.Error.Details)
+	}
+}
+
+// envEchoWasm builds a WASI command module writing its environment block,
+// NUL-separated NAME=VALUE entries, to stdout.
+func envEchoWasm() []byte {
+	types := []byte{3}
+	types = append(types, 0x60, 2, 0x7f, 0x7f, 1, 0x7f)             // environ_sizes_get, environ_get
+	types = append(types, 0x60, 4, 0x7f, 0x7f, 0x7f, 0x7f, 1, 0x7f) // fd_write
+	types = append(types, 0x60, 0, 0)                               // _start
+
+	imports := []byte{3}
+	for _, imp := range []struct {
+		name    string
+		typeIdx byte
+	}{{"environ_sizes_get", 0}, {"environ_get", 0}, {"fd_write", 1}} {
+		imports = append(imports, wasmName("wasi_snapshot_preview1")...)
+		imports = append(imports, wasmName(imp.name)...)
+		imports = append(imports, 0x00, imp.typeIdx)
+	}
+
+	exports := []byte{2}
+	exports = append(exports, wasmName("memory")...)
+	exports = append(exports, 0x02, 0)
+	exports = append(exports, wasmName("_start")...)
+	exports = append(exports, 0x00, 3)
+
+	// i32.const immediates are signed LEB128: 64 is 0xc0 0x00, 1024 is 0x80 0x08.
+	body := []byte{0}
+	body = append(body, 0x41, 0, 0x41, 4, 0x10, 0, 0x1a)                // environ_sizes_get(count=0, size=4)
+	body = append(body, 0x41, 0xc0, 0, 0x41, 0x80, 0x08, 0x10, 1, 0x1a) // environ_get(ptrs=64, buf=1024)
+	body = append(body, 0x41, 16, 0x41, 0x80, 0x08, 0x36, 2, 0)         // iovec.buf = 1024
+	body = append(body, 0x41, 20, 0x41, 4, 0x28, 2, 0, 0x36, 2, 0)      // iovec.len = size
+	body = append(body, 0x41, 1, 0x41, 16, 0x41, 1, 0x41, 8, 0x10, 2)   // fd_write(1, iovs=16, 1, nwritten=8)
+	body = append(body, 0x1a, 0x0b)
+	code := append([]byte{1}, uleb128(uint32(len(body)))...)
+	code = append(code, body...)
+
+	wasm := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	wasm = append(wasm, wasmSection(1, types)...)
+	wasm = append(wasm, wasmSection(2, imports)...)
+	wasm = append(wasm, wasmSection(3, []byte{1, 2})...)
+	wasm = append(wasm, wasmSection(5, []byte{1, 0, 1})...)
+	wasm = append(wasm, wasmSection(7, exports)...)
+	wasm = append(wasm, wasmSection(10, code)...)
+	return wasm
+}
+
+func TestContextWithEnvIsolation(t *testing.T) {
+	ctx := context.Background()
+	evaluator, cleanup, err := NewEvaluator(ctx, envEchoWasm(), 1)
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	environ := func(evalCtx context.Context) string {
+		var stdout strings.Builder
+		evaluator(ContextWithLogSink(evalCtx, func(stream, line string) {
+			if stream == "stdout" {
+				stdout.WriteString(line)
+			}
+		}), "")
+		return stdout.String()
+	}
+
+	first := environ(ContextWithEnv(ctx, map[string]string{"SECRET": "first-tenant"}))
+	if !strings.Contains(first, "SECRET=first-tenant") {
+		t.Fatalf("the variable was not visible to its own evaluation: %q", first)
+	}
+
+	if second := environ(ctx); strings.Contains(second, "SECRET") {
+		t.Errorf("the variable leaked into the next evaluation: %q", second)
+	}
+}
+
+func TestWithRawResult(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ValidJSONIsPassedThrough", func(t *testing.T) {
+		evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(`{"b":[1,2],"a":"x"}`, -1), 1, WithRawResult())
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+		defer func() { _ = cleanup() }()
+
+		result := evaluator(ctx, "")
+		raw, ok := result.Result.(json.RawMessage)
+		if !ok {
+			t.Fatalf("expected a json.RawMessage, got %T (error: %+v)", result.Result, result.Error)
+		}
+		if string(raw) != `{"b":[1,2],"a":"x"}` {
+			t.Errorf("unexpected raw result: %s", raw)
+		}
+	})
+
+	t.Run("InvalidJSONIsRejected", func(t *testing.T) {
+		evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(`{"a":`, -1), 1, WithRawResult())
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+		defer func() { _ = cleanup() }()
+
+		if result := evaluator(ctx, ""); result.Error == nil {
+			t.Errorf("expected a parse error, got: %v", result.Result)
+		}
+	})
+}
+
+func TestWithTextResult(t *testing.T) {
+	ctx := context.Background()
+
+	evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm("not json\n", -1), 1, WithTextResult())
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	result := evaluator(ctx, "")
+	if result.Error != nil {
+		t.Fatalf("expected no error, got: %+v", result.Error)
+	}
+	if result.Result != "not json\n" {
+		t.Errorf("unexpected result. Got: %#v, Want: %q", result.Result, "not json\n")
+	}
+}
+
+func TestWithUnwrappedResult(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		wasm []byte
+		want string
+	}{
+		{name: "Success", wasm: writeAndExitWasm(`{"a":[1,2]}`, -1), want: `{"a":[1,2]}`},
+		{name: "Failure", wasm: writeAndExitWasm("", 1), want: `{"result":null,"error":{"code":1,"message":""},"outputBytes":0}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluator, cleanup, err := NewEvaluator(ctx, tt.wasm, 1, WithUnwrappedResult())
+			if err != nil {
+				t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+			}
+			defer func() { _ = cleanup() }()
+
+			encoded, err := json.Marshal(evaluator(ctx, ""))
+			if err != nil {
+				t.Fatalf("failed to marshal the result: %v", err)
+			}
+			if string(encoded) != tt.want {
+				t.Errorf("unexpected JSON. Got: %s, Want: %s", encoded, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithUnescapedHTML(t *testing.T) {
+	ctx := context.Background()
+	wasm := writeAndExitWasm(`"<div>a & b</div>"`, -1)
+
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{name: "Default", want: `{"result":"\u003cdiv\u003ea \u0026 b\u003c/div\u003e","outputBytes":18}`},
+		{name: "Unescaped", opts: []Option{WithUnescapedHTML()}, want: `{"result":"<div>a & b</div>","outputBytes":18}`},
+		{name: "Unwrapped", opts: []Option{WithUnescapedHTML(), WithUnwrappedResult()}, want: `"<div>a & b</div>"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluator, cleanup, err := NewEvaluator(ctx, wasm, 1, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+			}
+			defer func() { _ = cleanup() }()
+
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(evaluator(ctx, "")); err != nil {
+				t.Fatalf("failed to encode the result: %v", err)
+			}
+			if got := strings.TrimSuffix(buf.String(), "\n"); got != tt.want {
+				t.Errorf("unexpected JSON. Got: %s, Want: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithOnCompiled(t *testing.T) {
+	ctx := context.Background()
+
+	var compileTime time.Duration
+	calls := 0
+	_, cleanup, err := NewEvaluator(ctx, writeAndExitWasm("1", -1), 1, WithOnCompiled(func(d time.Duration) {
+		compileTime = d
+		calls++
+	}))
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	if calls != 1 || compileTime <= 0 {
+		t.Errorf("expected one call with the compile time, got %d calls with %v", calls, compileTime)
+	}
+}
+
+func TestWithInterpreter(t *testing.T) {
+	ctx := context.Background()
+
+	evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(`{"a":1}`, -1), 1, WithInterpreter())
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	result := evaluator(ctx, "")
+	if result.Error != nil {
+		t.Fatalf("expected no error, got: %+v", result.Error)
+	}
+	if got, _ := result.Result.(map[string]interface{}); got["a"] != 1.0 {
+		t.Errorf("unexpected result: %#v", result.Result)
+	}
+}
+
+func TestWithParseErrorDetails(t *testing.T) {
+	ctx := context.Background()
+	stderr := "SyntaxError: unexpected token\n"
+
+	for name, tt := range map[string]struct {
+		opts       []Option
+		wantDetail bool
+	}{
+		"Default":     {},
+		"WithDetails": {opts: []Option{WithParseErrorDetails()}, wantDetail: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			evaluator, cleanup, err := NewEvaluator(ctx, writeFdAndExitWasm(2, stderr, 0), 1, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+			}
+			defer func() { _ = cleanup() }()
+
+			result := evaluator(ctx, "")
+			if result.Error == nil || result.Error.Code != -1 {
+				t.Fatalf("expected a parse error, got: %+v", result.Error)
+			}
+			if !tt.wantDetail {
+				if result.Error.ExitCode != nil || result.Error.Stderr != "" {
+					t.Errorf("expected no details, got: %+v", result.Error)
+				}
+				return
+			}
+			if result.Error.ExitCode == nil || *result.Error.ExitCode != 0 {
+				t.Errorf("expected exit code 0, got: %v", result.Error.ExitCode)
+			}
+			if result.Error.Stderr != stderr {
+				t.Errorf("unexpected stderr. Got: %q, Want: %q", result.Error.Stderr, stderr)
+			}
+		})
+	}
+
+	t.Run("ExcerptIsBounded", func(t *testing.T) {
+		e := &ErrorDto{}
+		newConfig([]Option{WithParseErrorDetails()}).addParseErrorDetails(e, 0, strings.Repeat("é", maxStderrExcerpt))
+		if !strings.HasSuffix(e.Stderr, "…") || len(e.Stderr) > maxStderrExcerpt+len("…") || !utf8.ValidString(e.Stderr) {
+			t.Errorf("unexpected excerpt of %d bytes: %q", len(e.Stderr), e.Stderr)
+		}
+	})
+}
+
+func TestWithRuntimeConfig(t *testing.T) {
+	ctx := context.Background()
+
+	// The limits of the package still apply to the custom configuration.
+	rc := wazero.NewRuntimeConfigInterpreter().WithCloseOnContextDone(false).WithMemoryLimitPages(65536)
+	evaluator, cleanup, err := NewEvaluator(ctx, busyLoopWasm(), 1, WithRuntimeConfig(rc))
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	result := evaluator(timeoutCtx, "")
+	if result.Error == nil || !result.Error.Terminated {
+		t.Errorf("expected the timeout to terminate the evaluation, got: %+v", result.Error)
+	}
+}
+
+func largeJSONArray(n int) string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = fmt.Sprintf(`{"id":%d,"ok":true}`, i)
+	}
+	return "[" + strings.Join(items, ",") + "]"
+}
+
+func BenchmarkRawResult(b *testing.B) {
+	ctx := context.Background()
+	wasm := writeAndExitWasm(largeJSONArray(2000), -1)
+
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "Decoded"},
+		{name: "Raw", opts: []Option{WithRawResult()}},
+		{name: "DecodedReusedBuffers", opts: []Option{WithReusedBuffers()}},
+		{name: "RawReusedBuffers", opts: []Option{WithRawResult(), WithReusedBuffers()}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			evaluator, cleanup, err := NewEvaluator(ctx, wasm, 2, bc.opts...)
+			if err != nil {
+				b.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+			}
+			b.Cleanup(func() { _ = cleanup() })
+
+			b.ReportAllocs()
+			for b.Loop() {
+				if result := evaluator(ctx, ""); result.Error != nil {
+					b.Fatalf("evaluator() returned an unexpected error: %+v", result.Error)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package jseval
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// WithJSONLinesFallback parses stdout which is not a single JSON value as
+// JSON lines: when every non-blank line is a JSON value, Result is the array
+// of them in order. A single value, even spanning several lines, always
+// takes precedence, so engines printing one result are unaffected; output
+// which is neither still fails to parse.
+func WithJSONLinesFallback() Option {
+	return func(c *config) { c.jsonLinesFallback = true }
+}
+
+// jsonLinesOutput is jsonLines for evaluators created WithJSONLinesFallback.
+func (c *config) jsonLinesOutput(output []byte, raw bool) (interface{}, bool) {
+	if !c.jsonLinesFallback {
+		return nil, false
+	}
+	return jsonLines(output, raw)
+}
+
+// jsonLines parses output as JSON lines, as []interface{} or, for raw, as a
+// json.RawMessage of the array. It reports false when a line is not JSON or
+// there are no lines.
+func jsonLines(output []byte, raw bool) (interface{}, bool) {
+	var decoded []interface{}
+	var encoded [][]byte
+	for line := range bytes.Lines(output) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if raw {
+			if !json.Valid(line) {
+				return nil, false
+			}
+			encoded = append(encoded, line)
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(line, &value); err != nil {
+			return nil, false
+		}
+		decoded = append(decoded, value)
+	}
+
+	if raw {
+		if len(encoded) == 0 {
+			return nil, false
+		}
+		// A fresh slice, so the result does not alias a reused buffer.
+		array := append([]byte{'['}, bytes.Join(encoded, []byte{','})...)
+		return json.RawMessage(append(array, ']')), true
+	}
+	if len(decoded) == 0 {
+		return nil, false
+	}
+	return decoded, true
+}
@@ -0,0 +1,70 @@
+package jseval
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestWithJSONLinesFallback(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		output string
+		want   string // the marshaled Result, or empty for a parse error
+	}{
+		{name: "Single", output: `{"a":1}`, want: `{"a":1}`},
+		{name: "SingleMultiLine", output: "{\n  \"a\": [1,\n 2]\n}\n", want: `{"a":[1,2]}`},
+		{name: "Lines", output: "{\"a\":1}\n\n2\r\n\"three\"\n", want: `[{"a":1},2,"three"]`},
+		{name: "NotJSON", output: "hello\nworld\n", want: ""},
+		{name: "PartlyJSON", output: "1\nnot json\n", want: ""},
+		{name: "Empty", output: "\n", want: ""},
+	}
+	for _, mode := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "Decoded", opts: []Option{WithJSONLinesFallback()}},
+		{name: "Raw", opts: []Option{WithJSONLinesFallback(), WithRawResult()}},
+	} {
+		for _, tt := range tests {
+			t.Run(mode.name+"/"+tt.name, func(t *testing.T) {
+				evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(tt.output, -1), 1, mode.opts...)
+				if err != nil {
+					t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+				}
+				defer func() { _ = cleanup() }()
+
+				result := evaluator(ctx, "")
+				if tt.want == "" {
+					if result.Error == nil || result.Error.Code != -1 {
+						t.Errorf("expected a parse error, got: %+v", result)
+					}
+					return
+				}
+				if result.Error != nil {
+					t.Fatalf("unexpected error: %+v", result.Error)
+				}
+				encoded, err := json.Marshal(result.Result)
+				if err != nil {
+					t.Fatalf("failed to marshal the result: %v", err)
+				}
+				if string(encoded) != tt.want {
+					t.Errorf("unexpected result. Got: %s, Want: %s", encoded, tt.want)
+				}
+			})
+		}
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm("1\n2\n", -1), 1)
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+		defer func() { _ = cleanup() }()
+		if result := evaluator(ctx, ""); result.Error == nil {
+			t.Errorf("expected JSON lines to fail to parse without the fallback, got: %+v", result)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package jseval
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+)
+
+const bytesInMiB = 1024 * 1024
+
+const (
+	loadChunkSize        = 1 * bytesInMiB
+	loadProgressInterval = 4 * bytesInMiB
+)
+
+var (
+	// ErrWasmNotFound means the WASM file does not exist.
+	ErrWasmNotFound = errors.New("WASM file not found")
+	// ErrWasmTooLarge means the WASM file exceeds the size limit. See WasmTooLargeError.
+	ErrWasmTooLarge = errors.New("WASM file too large")
+	// ErrWasmInvalid means the file is not a WASM binary.
+	ErrWasmInvalid = errors.New("invalid WASM binary")
+)
+
+var wasmMagic = []byte{0x00, 0x61, 0x73, 0x6d}
+
+// WasmTooLargeError reports the size and the limit of an oversized WASM file.
+// It matches ErrWasmTooLarge with errors.Is.
+type WasmTooLargeError struct {
+	Path  string
+	Size  int64
+	Limit int64
+}
+
+func (e *WasmTooLargeError) Error() string {
+	return fmt.Sprintf(
+		"WASM file %s is too large (%d bytes), exceeding max size of %d MiB",
+		e.Path, e.Size, e.Limit/bytesInMiB,
+	)
+}
+
+func (e *WasmTooLargeError) Is(target error) bool { return target == ErrWasmTooLarge }
+
+// LoadWasmBinary reads the WASM file from the given path with a size limit.
+// Missing, oversized and non-WASM files are reported as ErrWasmNotFound,
+// ErrWasmTooLarge and ErrWasmInvalid respectively. Other than regular files,
+// e.g. pipes, are read up to the limit, reporting Size as the limit plus one
+// when beyond.
+func LoadWasmBinary(wasmFilePath string, maxWasmSize uint) ([]byte, error) {
+	f, err := os.Open(wasmFilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s: %w", ErrWasmNotFound, wasmFilePath, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WASM file from %s: %w", wasmFilePath, err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf("warning: failed to close wasm file %s: %v", wasmFilePath, err)
+		}
+	}()
+
+	fileInfo, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get WASM file info from %s: %w", wasmFilePath, err)
+	}
+
+	maxBytes := int64(maxWasmSize) * bytesInMiB
+	var wasmBinary []byte
+	if fileInfo.Mode().IsRegular() {
+		if fileInfo.Size() > maxBytes {
+			return nil, &WasmTooLargeError{Path: wasmFilePath, Size: fileInfo.Size(), Limit: maxBytes}
+		}
+		wasmBinary, err = readSized(f, fileInfo.Size(), wasmFilePath)
+	} else {
+		// Pipes such as /dev/stdin have no size to check up front.
+		wasmBinary, err = io.ReadAll(io.LimitReader(f, maxBytes+1))
+		if err == nil && int64(len(wasmBinary)) > maxBytes {
+			return nil, &WasmTooLargeError{Path: wasmFilePath, Size: int64(len(wasmBinary)), Limit: maxBytes}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WASM file from %s: %w", wasmFilePath, err)
+	}
+
+	if !bytes.HasPrefix(wasmBinary, wasmMagic) {
+		return nil, fmt.Errorf("%w: %s does not start with the WASM magic number", ErrWasmInvalid, wasmFilePath)
+	}
+
+	return wasmBinary, nil
+}
+
+// readSized reads exactly size bytes in chunks into a preallocated buffer,
+// logging progress for large files.
+func readSized(r io.Reader, size int64, name string) ([]byte, error) {
+	buf := make([]byte, size)
+	nextProgress := int64(loadProgressInterval)
+	for off := int64(0); off < size; {
+		end := min(off+loadChunkSize, size)
+		n, err := io.ReadFull(r, buf[off:end])
+		off += int64(n)
+		if err != nil {
+			return nil, fmt.Errorf("file shrank while reading (%d of %d bytes): %w", off, size, err)
+		}
+		if off >= nextProgress && off < size {
+			log.Printf("Loading %s: %d/%d MiB", name, off/bytesInMiB, size/bytesInMiB)
+			nextProgress += loadProgressInterval
+		}
+	}
+
+	// The limit was checked against the stat size; refuse a file which grew since.
+	var extra [1]byte
+	if n, _ := r.Read(extra[:]); n > 0 {
+		return nil, fmt.Errorf("file grew while reading beyond %d bytes", size)
+	}
+
+	return buf, nil
+}
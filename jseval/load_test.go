@@ -0,0 +1,99 @@
+package jseval
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTempFile(t *testing.T, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "engine.wasm")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	return path
+}
+
+// pipePath returns a path reading content from a pipe, as /dev/stdin does.
+func pipePath(t *testing.T, content []byte) string {
+	t.Helper()
+	if _, err := os.Stat("/dev/fd"); err != nil {
+		t.Skip("no /dev/fd to open a pipe by path")
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+	go func() {
+		_, _ = w.Write(content)
+		_ = w.Close()
+	}()
+	return fmt.Sprintf("/dev/fd/%d", r.Fd())
+}
+
+func TestLoadWasmBinary(t *testing.T) {
+	content := append([]byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}, bytes.Repeat([]byte{0x5a}, loadChunkSize*2+123)...)
+	path := writeTempFile(t, content)
+
+	t.Run("ReadsWholeFileInChunks", func(t *testing.T) {
+		loaded, err := LoadWasmBinary(path, 16)
+		if err != nil {
+			t.Fatalf("LoadWasmBinary() returned an unexpected error: %v", err)
+		}
+		if !bytes.Equal(loaded, content) {
+			t.Errorf("loaded content differs: got %d bytes, want %d bytes", len(loaded), len(content))
+		}
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		_, err := LoadWasmBinary(path, 1)
+		if !errors.Is(err, ErrWasmTooLarge) {
+			t.Fatalf("expected ErrWasmTooLarge, got: %v", err)
+		}
+		var tooLarge *WasmTooLargeError
+		if !errors.As(err, &tooLarge) {
+			t.Fatalf("expected a *WasmTooLargeError, got: %T", err)
+		}
+		if tooLarge.Size != int64(len(content)) || tooLarge.Limit != bytesInMiB {
+			t.Errorf("unexpected size/limit: %d/%d", tooLarge.Size, tooLarge.Limit)
+		}
+	})
+
+	t.Run("Pipe", func(t *testing.T) {
+		loaded, err := LoadWasmBinary(pipePath(t, content), 16)
+		if err != nil {
+			t.Fatalf("LoadWasmBinary() returned an unexpected error: %v", err)
+		}
+		if !bytes.Equal(loaded, content) {
+			t.Errorf("loaded content differs: got %d bytes, want %d bytes", len(loaded), len(content))
+		}
+
+		_, err = LoadWasmBinary(pipePath(t, content), 1)
+		var tooLarge *WasmTooLargeError
+		if !errors.As(err, &tooLarge) || tooLarge.Size != bytesInMiB+1 {
+			t.Errorf("expected a *WasmTooLargeError at the limit, got: %v", err)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := LoadWasmBinary(filepath.Join(t.TempDir(), "missing.wasm"), 16)
+		if !errors.Is(err, ErrWasmNotFound) {
+			t.Fatalf("expected ErrWasmNotFound, got: %v", err)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected the error to wrap os.ErrNotExist, got: %v", err)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := LoadWasmBinary(writeTempFile(t, []byte("console.log(1)")), 16)
+		if !errors.Is(err, ErrWasmInvalid) {
+			t.Fatalf("expected ErrWasmInvalid, got: %v", err)
+		}
+	})
+}
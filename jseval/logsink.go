@@ -0,0 +1,55 @@
+package jseval
+
+import (
+	"bytes"
+	"context"
+)
+
+// LogSink receives the engine output line by line while the engine runs.
+// The stream is either "stdout" or "stderr".
+type LogSink func(stream, line string)
+
+type logSinkKey struct{}
+
+// ContextWithLogSink returns a context which makes an evaluation forward
+// every output line of the engine to the sink as soon as it is written.
+// The output is still captured and returned as usual.
+func ContextWithLogSink(ctx context.Context, sink LogSink) context.Context {
+	return context.WithValue(ctx, logSinkKey{}, sink)
+}
+
+func logSinkFrom(ctx context.Context) LogSink {
+	sink, _ := ctx.Value(logSinkKey{}).(LogSink)
+	return sink
+}
+
+// lineWriter passes each complete line written to it to the sink.
+type lineWriter struct {
+	stream  string
+	sink    LogSink
+	partial []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	rest := p
+	for {
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			break
+		}
+		w.partial = append(w.partial, rest[:i]...)
+		w.sink(w.stream, string(bytes.TrimSuffix(w.partial, []byte{'\r'})))
+		w.partial = w.partial[:0]
+		rest = rest[i+1:]
+	}
+	w.partial = append(w.partial, rest...)
+	return len(p), nil
+}
+
+// flush passes the trailing line not terminated by a newline, if any.
+func (w *lineWriter) flush() {
+	if len(w.partial) > 0 {
+		w.sink(w.stream, string(w.partial))
+		w.partial = nil
+	}
+}
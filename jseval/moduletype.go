@@ -0,0 +1,84 @@
+package jseval
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Module types, telling the engine how to parse the code.
+const (
+	// ModuleTypeScript is a classic script, the default.
+	ModuleTypeScript = "script"
+	// ModuleTypeModule is an ES module, which may use import and top-level await.
+	ModuleTypeModule = "module"
+)
+
+// ErrorCodeUnsupportedModuleType is the ErrorDto code of an evaluation
+// requesting a module type the engine was not declared to support.
+const ErrorCodeUnsupportedModuleType = -16
+
+// moduleArg is the engine argument selecting ModuleTypeModule with
+// EngineProtocolV1; with EngineProtocolV2 the type is part of stdin.
+const moduleArg = "--module"
+
+type moduleTypeKey struct{}
+
+// ContextWithModuleType returns a context which makes the evaluations run
+// with it parse the code as moduleType. Without it the code is a script.
+func ContextWithModuleType(ctx context.Context, moduleType string) context.Context {
+	return context.WithValue(ctx, moduleTypeKey{}, moduleType)
+}
+
+func moduleTypeFrom(ctx context.Context) string {
+	if moduleType, _ := ctx.Value(moduleTypeKey{}).(string); moduleType != "" {
+		return moduleType
+	}
+	return ModuleTypeScript
+}
+
+// ValidateModuleType rejects unknown module types. The empty type is a script.
+func ValidateModuleType(moduleType string) error {
+	switch moduleType {
+	case "", ModuleTypeScript, ModuleTypeModule:
+		return nil
+	default:
+		return fmt.Errorf("unknown module type %q", moduleType)
+	}
+}
+
+// WithModuleTypes declares the module types the engine supports, by default
+// ModuleTypeScript only. Evaluations requesting another type fail with
+// ErrorCodeUnsupportedModuleType without running the engine.
+//
+// A module is passed to an EngineProtocolV1 engine as the argument
+// "--module", after any set WithArgs, and to an EngineProtocolV2 engine as
+// "moduleType" of its stdin. Scripts are passed exactly as before module
+// types existed.
+func WithModuleTypes(moduleTypes ...string) Option {
+	return func(c *config) { c.moduleTypes = moduleTypes }
+}
+
+// checkModuleType returns the error of an unsupported module type.
+func (c *config) checkModuleType(moduleType string) *ErrorDto {
+	if moduleType == ModuleTypeScript || slices.Contains(c.moduleTypes, moduleType) {
+		return nil
+	}
+	return &ErrorDto{
+		Code:    ErrorCodeUnsupportedModuleType,
+		Message: fmt.Sprintf("the engine does not support module type %q", moduleType),
+		Details: map[string]any{"module_type": moduleType},
+	}
+}
+
+// engineArgs returns the arguments of the engine for the module type.
+func (c *config) engineArgs(moduleType string) []string {
+	args := c.args
+	if moduleType == ModuleTypeModule && c.protocol == EngineProtocolV1 {
+		args = append(slices.Clip(args), moduleArg)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return append([]string{engineProgramName}, args...)
+}
@@ -0,0 +1,60 @@
+package jseval
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestModuleTypes(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ScriptByDefault", func(t *testing.T) {
+		if result := newFixtureEvaluator(t)(ctx, "1"); result.Error != nil {
+			t.Fatalf("unexpected error: %+v", result.Error)
+		}
+		if result := newFixtureEvaluator(t)(ContextWithModuleType(ctx, ModuleTypeScript), "1"); result.Error != nil {
+			t.Fatalf("unexpected error for an explicit script: %+v", result.Error)
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		result := newFixtureEvaluator(t)(ContextWithModuleType(ctx, ModuleTypeModule), "1")
+		if result.Error == nil || result.Error.Code != ErrorCodeUnsupportedModuleType {
+			t.Fatalf("expected an unsupported module type error, got %+v", result)
+		}
+	})
+
+	t.Run("Supported", func(t *testing.T) {
+		evaluator := newFixtureEvaluator(t, WithModuleTypes(ModuleTypeModule))
+		if result := evaluator(ContextWithModuleType(ctx, ModuleTypeModule), "1"); result.Error != nil {
+			t.Fatalf("unexpected error: %+v", result.Error)
+		}
+	})
+
+	t.Run("EngineInput", func(t *testing.T) {
+		v1 := newConfig([]Option{WithArgs("--max-stack=10")})
+		if got := v1.engineArgs(ModuleTypeScript); !reflect.DeepEqual(got, []string{engineProgramName, "--max-stack=10"}) {
+			t.Errorf("unexpected script args: %q", got)
+		}
+		if got := v1.engineArgs(ModuleTypeModule); !reflect.DeepEqual(got, []string{engineProgramName, "--max-stack=10", moduleArg}) {
+			t.Errorf("unexpected module args: %q", got)
+		}
+		if got := newConfig(nil).engineArgs(ModuleTypeScript); got != nil {
+			t.Errorf("expected no args for a script, got %q", got)
+		}
+
+		v2 := newConfig([]Option{WithEngineProtocol(EngineProtocolV2)})
+		if got := v2.engineArgs(ModuleTypeModule); got != nil {
+			t.Errorf("expected no args with protocol v2, got %q", got)
+		}
+		stdin, err := v2.engineInput("1", ModuleTypeModule)
+		if err != nil || string(stdin) != `{"code":"1","moduleType":"module"}` {
+			t.Errorf("unexpected engine input: %s, %v", stdin, err)
+		}
+	})
+
+	if err := ValidateModuleType("commonjs"); err == nil {
+		t.Error("expected an unknown module type to be rejected")
+	}
+}
@@ -0,0 +1,43 @@
+package jseval
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// WithName names the evaluator in its log lines, e.g. "[boa] Code rejected:
+// ...", telling apart several evaluators of one process such as engines or
+// tiers. Metrics are up to the caller, who knows the evaluator by the name.
+func WithName(name string) Option {
+	return func(c *config) { c.name = name }
+}
+
+// WithLabels adds labels to the log lines of the evaluator after its name,
+// e.g. "[boa tier=fast] ...", in the order of their keys.
+func WithLabels(labels map[string]string) Option {
+	return func(c *config) { c.labels = maps.Clone(labels) }
+}
+
+// logPrefix returns the prefix of the log lines of an evaluator, or the empty
+// string without a name and labels.
+func (c *config) logPrefix() string {
+	parts := []string{}
+	if c.name != "" {
+		parts = append(parts, c.name)
+	}
+	for _, key := range slices.Sorted(maps.Keys(c.labels)) {
+		parts = append(parts, key+"="+c.labels[key])
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(parts, " ") + "] "
+}
+
+// logf logs like log.Printf, prefixed with the name and labels of the evaluator.
+func (c *config) logf(format string, args ...any) {
+	log.Print(c.prefix + fmt.Sprintf(format, args...))
+}
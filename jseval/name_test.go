@@ -0,0 +1,40 @@
+package jseval
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestWithName(t *testing.T) {
+	var logged bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(previous) })
+
+	evaluator := newFixtureEvaluator(t, WithName("boa"), WithLabels(map[string]string{"tier": "fast", "az": "b"}))
+	if result := evaluator(fixtureExitCtx(context.Background(), 1), "{}"); result.Error == nil {
+		t.Fatal("expected the exit code to fail the evaluation")
+	}
+
+	lines := strings.Split(strings.TrimSpace(logged.String()), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("expected the compile and the failure to be logged, got: %q", lines)
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "[boa az=b tier=fast] ") {
+			t.Errorf("log line lacks the evaluator name and labels: %q", line)
+		}
+	}
+}
+
+func TestLogPrefix(t *testing.T) {
+	if prefix := newConfig(nil).prefix; prefix != "" {
+		t.Errorf("expected no prefix by default, got %q", prefix)
+	}
+	if prefix := newConfig([]Option{WithLabels(map[string]string{"tier": "slow"})}).prefix; prefix != "[tier=slow] " {
+		t.Errorf("unexpected prefix %q", prefix)
+	}
+}
@@ -0,0 +1,73 @@
+package jseval
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ErrorCodeResultNotObject is the ErrorDto code of a result which is valid
+// JSON but not an object, rejected WithRequireObjectResult.
+const ErrorCodeResultNotObject = -18
+
+// WithRequireObjectResult fails evaluations whose result is not a JSON
+// object, e.g. a bare 42, true or array, for clients which require object
+// results. Results of WithTextResult are not JSON and are not checked, nor
+// are those of probes run ContextWithProbe, which answer e.g. true.
+func WithRequireObjectResult() Option {
+	return func(c *config) { c.requireObject = true }
+}
+
+// checkObjectResult returns the error of a result which is not an object.
+func (c *config) checkObjectResult(result any) *ErrorDto {
+	if !c.requireObject {
+		return nil
+	}
+	kind := jsonKind(result)
+	if kind == "object" {
+		return nil
+	}
+	return &ErrorDto{
+		Code:    ErrorCodeResultNotObject,
+		Message: fmt.Sprintf("result is a JSON %s, not an object", kind),
+		Details: map[string]any{"type": kind},
+	}
+}
+
+// jsonKind names the JSON type of a decoded value or a json.RawMessage.
+func jsonKind(value any) string {
+	if raw, ok := value.(json.RawMessage); ok {
+		trimmed := bytes.TrimLeft(raw, " \t\r\n")
+		if len(trimmed) == 0 {
+			return "null"
+		}
+		switch trimmed[0] {
+		case '{':
+			return "object"
+		case '[':
+			return "array"
+		case '"':
+			return "string"
+		case 't', 'f':
+			return "boolean"
+		case 'n':
+			return "null"
+		default:
+			return "number"
+		}
+	}
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	default:
+		return "number"
+	}
+}
@@ -0,0 +1,76 @@
+package jseval
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithRequireObjectResult(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		output   string
+		wantKind string // empty for an accepted result
+	}{
+		{name: "Object", output: `{"a":1}`},
+		{name: "Number", output: "42", wantKind: "number"},
+		{name: "Array", output: " [1, 2]", wantKind: "array"},
+		{name: "Boolean", output: "true", wantKind: "boolean"},
+		{name: "Null", output: "null", wantKind: "null"},
+		{name: "String", output: `"a"`, wantKind: "string"},
+	}
+	for _, mode := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "Decoded", opts: []Option{WithRequireObjectResult()}},
+		{name: "Raw", opts: []Option{WithRequireObjectResult(), WithRawResult()}},
+	} {
+		for _, tt := range tests {
+			t.Run(mode.name+"/"+tt.name, func(t *testing.T) {
+				evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(tt.output, -1), 1, mode.opts...)
+				if err != nil {
+					t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+				}
+				defer func() { _ = cleanup() }()
+
+				result := evaluator(ctx, "")
+				if tt.wantKind == "" {
+					if result.Error != nil {
+						t.Errorf("expected the object to be accepted, got: %+v", result.Error)
+					}
+					return
+				}
+				if result.Error == nil || result.Error.Code != ErrorCodeResultNotObject {
+					t.Fatalf("expected error %d, got: %+v", ErrorCodeResultNotObject, result)
+				}
+				if kind := result.Error.Details["type"]; kind != tt.wantKind {
+					t.Errorf("type = %v, want %s", kind, tt.wantKind)
+				}
+			})
+		}
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm("42", -1), 1)
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+		defer func() { _ = cleanup() }()
+		if result := evaluator(ctx, ""); result.Error != nil || result.Result != 42.0 {
+			t.Errorf("expected any JSON value to be accepted by default, got: %+v", result)
+		}
+	})
+
+	t.Run("TextResult", func(t *testing.T) {
+		evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm("42", -1), 1, WithRequireObjectResult(), WithTextResult())
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+		defer func() { _ = cleanup() }()
+		if result := evaluator(ctx, ""); result.Error != nil {
+			t.Errorf("expected text results not to be checked, got: %+v", result.Error)
+		}
+	})
+}
@@ -0,0 +1,291 @@
+package jseval
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/tetratelabs/wazero"
+)
+
+// Option customizes the Evaluator created by NewEvaluator.
+type Option func(*config)
+
+type config struct {
+	successExitCodes  map[uint32]struct{}
+	codePolicy        func(string) error
+	lockOSThread      bool
+	cpuTimeLimit      time.Duration
+	clock             func() time.Time
+	timestamp         bool
+	memoryBudget      *MemoryBudget
+	appliedLimits     bool
+	maxStdinBytes     int
+	rawResult         bool
+	textResult        bool
+	unwrappedResult   bool
+	onCompiled        func(time.Duration)
+	protocol          int
+	interpreter       bool
+	keepANSI          bool
+	concurrencyLimit  *ConcurrencyLimit
+	parseErrorDetails bool
+	diagnosticParser  DiagnosticParser
+	runtimeConfig     wazero.RuntimeConfig
+	outputStore       OutputStore
+	echoCode          bool
+	codeHash          bool
+	rawCode           bool
+	resultSchema      *jsonschema.Schema
+	resolvedSchema    *jsonschema.Resolved
+	args              []string
+	stackOverflowCode bool
+	pagesUsed         bool
+	failOnStderr      bool
+	moduleTypes       []string
+	sharedFS          wazero.FSConfig
+	resultEncoding    string
+	sniffContentType  bool
+	compileTimeout    time.Duration
+	queueWait         bool
+	onQueueWait       func(time.Duration)
+	reuseBuffers      bool
+	onHostFunctions   func([]HostFunction)
+	onModuleSurface   func(ModuleSurface)
+	softTimeoutGrace  time.Duration
+	unescapedHTML     bool
+	jsonLinesFallback bool
+	requireObject     bool
+	deterministic     bool
+	seed              uint64
+	stdoutBufferSize  int
+	maxOutputLines    int
+	maxResultElements int
+	rejectDuplicates  bool
+	probe             bool // see ContextWithProbe
+	customClock       bool
+	flushGrace        time.Duration
+	invalidExitCodes  []int
+	name              string
+	labels            map[string]string
+	prefix            string // of log lines, see logPrefix
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{
+		successExitCodes: map[uint32]struct{}{0: {}},
+		clock:            time.Now,
+		protocol:         EngineProtocolV1,
+		stdoutBufferSize: DefaultStdoutBufferSize,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.deterministic && !cfg.customClock {
+		cfg.clock = deterministicClock
+	}
+	cfg.prefix = cfg.logPrefix()
+	return cfg
+}
+
+// WithSuccessExitCodes sets the exit codes which mean a successful run.
+// The stdout of a run exiting with one of these codes is parsed as JSON;
+// any other code is reported as an error. Defaults to 0 only. proc_exit takes
+// a u32, so engines may exit with codes beyond the 8 bits POSIX shells keep;
+// NewEvaluator rejects codes outside of 0 to math.MaxUint32.
+func WithSuccessExitCodes(codes ...int) Option {
+	return func(c *config) {
+		c.successExitCodes = make(map[uint32]struct{}, len(codes))
+		c.invalidExitCodes = nil
+		for _, code := range codes {
+			if code < 0 || uint64(code) > maxExitCode {
+				c.invalidExitCodes = append(c.invalidExitCodes, code)
+				continue
+			}
+			c.successExitCodes[uint32(code)] = struct{}{}
+		}
+	}
+}
+
+// maxExitCode is the largest exit code a WASI command can report.
+const maxExitCode = math.MaxUint32
+
+func validateSuccessExitCodes(invalid []int) error {
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid success exit codes %v: want codes from 0 to %d", invalid, maxExitCode)
+	}
+	return nil
+}
+
+// ErrorCodePolicyViolation is the ErrorDto code of code rejected by the code policy.
+const ErrorCodePolicyViolation = -2
+
+// WithCodePolicy sets a hook which checks the code before it is evaluated.
+// Code for which the policy returns an error is rejected without running the engine.
+//
+// A policy is a best-effort guardrail, not a security boundary;
+// the WASM sandbox and its limits are what actually confine the code.
+func WithCodePolicy(policy func(code string) error) Option {
+	return func(c *config) { c.codePolicy = policy }
+}
+
+// DenyPattern returns a code policy rejecting code which matches the pattern.
+// Being a plain text match, it is easily bypassed and must not be relied on for security.
+func DenyPattern(pattern *regexp.Regexp) func(string) error {
+	return func(code string) error {
+		if loc := pattern.FindStringIndex(code); loc != nil {
+			return fmt.Errorf("code matches denied pattern %q at offset %d", pattern.String(), loc[0])
+		}
+		return nil
+	}
+}
+
+// WithLockOSThread pins each evaluation to one OS thread for its whole run,
+// for engine builds which misbehave when moved across threads.
+// It costs throughput: the locked thread can not run other goroutines meanwhile,
+// so the scheduler may need to spawn extra threads under concurrent load.
+func WithLockOSThread() Option {
+	return func(c *config) { c.lockOSThread = true }
+}
+
+// WithClock replaces time.Now as the source of the evaluation timestamps,
+// also WithDeterministic.
+func WithClock(clock func() time.Time) Option {
+	return func(c *config) {
+		c.clock = clock
+		c.customClock = true
+	}
+}
+
+// WithTimestamp sets EvaluatedAt of every result to the start time of the evaluation.
+func WithTimestamp() Option {
+	return func(c *config) { c.timestamp = true }
+}
+
+// WithAppliedLimits sets AppliedLimits of every result to the limits the
+// evaluation actually ran under, after any clamping by the caller.
+func WithAppliedLimits() Option {
+	return func(c *config) { c.appliedLimits = true }
+}
+
+// ErrorCodeInputTooLarge is the ErrorDto code of code exceeding the stdin size limit.
+const ErrorCodeInputTooLarge = -6
+
+// WithMaxStdinBytes rejects code larger than limit bytes before it reaches the engine.
+// It bounds what the engine is fed, independent of any transport limit.
+func WithMaxStdinBytes(limit int) Option {
+	return func(c *config) { c.maxStdinBytes = limit }
+}
+
+// WithRawResult skips decoding the engine output: Result is the stdout as a
+// json.RawMessage, only checked to be syntactically valid JSON. This saves
+// building the value tree when the result is just passed on.
+func WithRawResult() Option {
+	return func(c *config) { c.rawResult = true }
+}
+
+// WithTextResult makes Result the stdout as a plain string without any JSON
+// parsing, for engines which do not print JSON. Failures are then only
+// detected from the exit code; stdout itself can not make an evaluation fail.
+func WithTextResult() Option {
+	return func(c *config) { c.textResult = true }
+}
+
+// WithUnwrappedResult makes results of successful evaluations marshal to the
+// bare decoded value, e.g. 42 instead of {"result":42,...}. Failed evaluations
+// keep the envelope so the error stays visible. As the bare value need not be
+// an object, such results can not be renamed with FieldNames or be used as MCP
+// structured content.
+func WithUnwrappedResult() Option {
+	return func(c *config) { c.unwrappedResult = true }
+}
+
+// WithUnescapedHTML makes results marshal <, > and & in strings as they are
+// instead of as \u003c, \u003e and \u0026, so HTML fragments stay readable
+// in the JSON text. json.Marshal escapes them again when it embeds the
+// result in other JSON; use a json.Encoder with SetEscapeHTML(false) there.
+func WithUnescapedHTML() Option {
+	return func(c *config) { c.unescapedHTML = true }
+}
+
+// WithOnCompiled calls f with the time NewEvaluator spent compiling the module.
+func WithOnCompiled(f func(time.Duration)) Option {
+	return func(c *config) { c.onCompiled = f }
+}
+
+// WithInterpreter runs the module with the wazero interpreter instead of
+// compiling it to machine code. Startup is faster and uses less memory, but
+// evaluations run many times slower. Without it the compiler is used where
+// wazero supports the platform, and the interpreter elsewhere.
+func WithInterpreter() Option {
+	return func(c *config) { c.interpreter = true }
+}
+
+// WithParseErrorDetails adds the exit code and an excerpt of stderr to the
+// error of a successful run whose stdout is not valid JSON. Such failures are
+// often engine errors printed to stderr while stdout stayed empty.
+func WithParseErrorDetails() Option {
+	return func(c *config) { c.parseErrorDetails = true }
+}
+
+// WithRuntimeConfig makes NewEvaluator start from rc, e.g. to enable core
+// features or a compilation cache, instead of the default configuration.
+// WithInterpreter is ignored then; choose the engine when creating rc.
+// The memory limit passed to NewEvaluator and WithCloseOnContextDone(true),
+// which timeouts, cancellation and the CPU time limit rely on, are still
+// applied on top and override the same settings of rc.
+func WithRuntimeConfig(rc wazero.RuntimeConfig) Option {
+	return func(c *config) { c.runtimeConfig = rc }
+}
+
+// ErrorCodeUnexpectedStderr is the ErrorDto code of a successful run which
+// wrote to stderr, reported WithFailOnStderr.
+const ErrorCodeUnexpectedStderr = -15
+
+// WithFailOnStderr reports a run exiting successfully but writing anything
+// to stderr as an error, with stderr as the message and the exit code kept
+// in ExitCode. It suits strict validation of scripts, where warnings and
+// console.error output should fail as well.
+func WithFailOnStderr() Option {
+	return func(c *config) { c.failOnStderr = true }
+}
+
+// WithPagesUsed sets PagesUsed of every result to the memory pages the
+// evaluation grew its memory to, which against the memory limit shows the
+// headroom left. Every evaluation runs in a fresh instance, so it is the peak
+// of that evaluation alone.
+func WithPagesUsed() Option {
+	return func(c *config) { c.pagesUsed = true }
+}
+
+// MaxEchoCodeBytes bounds the code echoed WithEchoCode.
+const MaxEchoCodeBytes = 4096
+
+// WithEchoCode sets Code of every result to the evaluated code, cut to
+// MaxEchoCodeBytes, so logged results show what produced them.
+func WithEchoCode() Option {
+	return func(c *config) { c.echoCode = true }
+}
+
+// WithCodeHash sets CodeSHA256 of every result to the hex SHA-256 of the
+// evaluated code, to correlate results without keeping the source.
+func WithCodeHash() Option {
+	return func(c *config) { c.codeHash = true }
+}
+
+// engineProgramName is the program name the engine sees before WithArgs.
+const engineProgramName = "jseval"
+
+// WithArgs passes command line arguments to the engine, e.g. a stack depth
+// limit such as "--max-stack=1000" for engines which support one. Engines
+// run without arguments by default.
+func WithArgs(args ...string) Option {
+	return func(c *config) { c.args = args }
+}
+
+func (c *config) isSuccess(exitCode uint32) bool {
+	_, ok := c.successExitCodes[exitCode]
+	return ok
+}
@@ -0,0 +1,144 @@
+package jseval
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// OutputStore keeps outputs too large to hold in memory. With
+// WithOutputStore the stdout of an evaluation is written to the store as it
+// runs, and a successful result carries OutputRef instead of Result.
+type OutputStore interface {
+	// Create starts a new output.
+	Create(ctx context.Context) (OutputWriter, error)
+}
+
+// OutputWriter receives the stdout of one evaluation.
+type OutputWriter interface {
+	io.Writer
+	// Commit keeps the output and returns the reference to retrieve it by.
+	Commit() (ref string, err error)
+	// Abort drops the output of a failed evaluation.
+	Abort()
+}
+
+// WithOutputStore streams stdout into the store instead of buffering it.
+// The output is neither parsed nor validated as JSON, and the engine protocol
+// envelope is not unwrapped: it is stored as the engine wrote it. Outputs of
+// failed evaluations are dropped.
+func WithOutputStore(store OutputStore) Option {
+	return func(c *config) { c.outputStore = store }
+}
+
+// TempFileStore is an OutputStore keeping each output in a file of its
+// directory, removed once older than its TTL. Expired outputs are swept
+// whenever an output is created or opened.
+type TempFileStore struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+}
+
+var outputRefPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// ErrOutputNotFound means the output does not exist or has expired.
+var ErrOutputNotFound = errors.New("output not found")
+
+// NewTempFileStore creates the directory if needed.
+func NewTempFileStore(dir string, ttl time.Duration) (*TempFileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create the output directory %s: %w", dir, err)
+	}
+	return &TempFileStore{dir: dir, ttl: ttl, now: time.Now}, nil
+}
+
+func (s *TempFileStore) Create(context.Context) (OutputWriter, error) {
+	s.sweep()
+
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, fmt.Errorf("failed to create an output reference: %w", err)
+	}
+	ref := hex.EncodeToString(id[:])
+	f, err := os.OpenFile(filepath.Join(s.dir, ref+".partial"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the output file: %w", err)
+	}
+	return &tempFileOutput{File: f, ref: ref, path: filepath.Join(s.dir, ref)}, nil
+}
+
+// Open returns a committed output which has not expired.
+func (s *TempFileStore) Open(ref string) (*os.File, error) {
+	s.sweep()
+	if !outputRefPattern.MatchString(ref) {
+		return nil, ErrOutputNotFound
+	}
+	f, err := os.Open(filepath.Join(s.dir, ref))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrOutputNotFound
+	}
+	return f, err
+}
+
+// sweep removes the outputs older than the TTL, including abandoned partial ones.
+func (s *TempFileStore) sweep() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		log.Printf("failed to list the output directory %s: %v", s.dir, err)
+		return
+	}
+	expiry := s.now().Add(-s.ttl)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.IsDir() || info.ModTime().After(expiry) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("failed to remove the expired output %s: %v", entry.Name(), err)
+		}
+	}
+}
+
+type tempFileOutput struct {
+	*os.File
+	ref  string
+	path string
+}
+
+func (o *tempFileOutput) Commit() (string, error) {
+	if err := o.Close(); err != nil {
+		_ = os.Remove(o.Name())
+		return "", fmt.Errorf("failed to write the output file: %w", err)
+	}
+	if err := os.Rename(o.Name(), o.path); err != nil {
+		_ = os.Remove(o.Name())
+		return "", fmt.Errorf("failed to commit the output file: %w", err)
+	}
+	return o.ref, nil
+}
+
+func (o *tempFileOutput) Abort() {
+	_ = o.Close()
+	_ = os.Remove(o.Name())
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
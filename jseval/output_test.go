@@ -0,0 +1,75 @@
+package jseval
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWithOutputStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewTempFileStore(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("NewTempFileStore() returned an unexpected error: %v", err)
+	}
+
+	output := largeJSONArray(100)
+	evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(output, -1), 1, WithOutputStore(store))
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	result := evaluator(ctx, "")
+	if result.Error != nil || result.Result != nil || result.OutputRef == "" {
+		t.Fatalf("expected only a reference, got: %+v", result)
+	}
+	if result.OutputBytes != len(output) {
+		t.Errorf("unexpected OutputBytes. Got: %d, Want: %d", result.OutputBytes, len(output))
+	}
+
+	f, err := store.Open(result.OutputRef)
+	if err != nil {
+		t.Fatalf("Open() returned an unexpected error: %v", err)
+	}
+	stored, err := io.ReadAll(f)
+	_ = f.Close()
+	if err != nil || string(stored) != output {
+		t.Errorf("unexpected stored output (%d bytes, %v)", len(stored), err)
+	}
+
+	if _, err := store.Open("../../etc/passwd"); !errors.Is(err, ErrOutputNotFound) {
+		t.Errorf("expected an invalid reference to be refused, got: %v", err)
+	}
+
+	store.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if _, err := store.Open(result.OutputRef); !errors.Is(err, ErrOutputNotFound) {
+		t.Errorf("expected the output to expire, got: %v", err)
+	}
+}
+
+func TestWithOutputStoreFailure(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewTempFileStore(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("NewTempFileStore() returned an unexpected error: %v", err)
+	}
+
+	evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(`{"partial":`, 1), 1, WithOutputStore(store))
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	if result := evaluator(ctx, ""); result.Error == nil || result.OutputRef != "" {
+		t.Fatalf("expected a failure without a reference, got: %+v", result)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected the output of the failed evaluation to be removed, found %d files", len(entries))
+	}
+}
@@ -0,0 +1,55 @@
+package jseval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrorCodeTooManyOutputLines is the ErrorDto code of an evaluation stopped
+// by the output line limit.
+const ErrorCodeTooManyOutputLines = -19
+
+var errTooManyOutputLines = errors.New("output line limit exceeded")
+
+// WithMaxOutputLines stops an evaluation as soon as it writes more than limit
+// lines, i.e. newlines, to stdout, failing it with ErrorCodeTooManyOutputLines.
+// It catches code printing millions of short lines early, before the output
+// grows large enough for size limits to notice. A final line without a
+// newline is not counted.
+func WithMaxOutputLines(limit int) Option {
+	return func(c *config) { c.maxOutputLines = limit }
+}
+
+// lineLimitWriter passes writes on to w until more than limit lines were
+// written, then fails them and cancels the evaluation with
+// errTooManyOutputLines.
+type lineLimitWriter struct {
+	w      io.Writer
+	limit  int
+	lines  int
+	cancel context.CancelCauseFunc
+}
+
+func (l *lineLimitWriter) Write(p []byte) (int, error) {
+	if l.lines > l.limit {
+		return 0, errTooManyOutputLines
+	}
+	l.lines += bytes.Count(p, []byte{'\n'})
+	if l.lines > l.limit {
+		l.cancel(errTooManyOutputLines)
+		return 0, errTooManyOutputLines
+	}
+	return l.w.Write(p)
+}
+
+// limitOutputLines wraps stdout WithMaxOutputLines, returning the context
+// the limit cancels and a function releasing it.
+func (c *config) limitOutputLines(ctx context.Context, stdout io.Writer) (context.Context, io.Writer, func()) {
+	if c.maxOutputLines <= 0 {
+		return ctx, stdout, func() {}
+	}
+	limited, cancel := context.WithCancelCause(ctx)
+	return limited, &lineLimitWriter{w: stdout, limit: c.maxOutputLines, cancel: cancel}, func() { cancel(nil) }
+}
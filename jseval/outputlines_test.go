@@ -0,0 +1,32 @@
+package jseval
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWithMaxOutputLines(t *testing.T) {
+	ctx := fixtureExitCtx(context.Background(), 0)
+	evaluator := newFixtureEvaluator(t, WithMaxOutputLines(100), WithTextResult())
+
+	t.Run("ManyLines", func(t *testing.T) {
+		result := evaluator(ctx, strings.Repeat("1\n", 100_000))
+		if result.Error == nil || result.Error.Code != ErrorCodeTooManyOutputLines || !result.Error.Terminated {
+			t.Fatalf("expected the line limit to stop the evaluation, got: %+v", result.Error)
+		}
+		if limit := result.Error.Details["max_output_lines"]; limit != 100 {
+			t.Errorf("max_output_lines = %v, want 100", limit)
+		}
+		if result.OutputBytes >= 8192 {
+			t.Errorf("expected the output to stop early, got %d bytes", result.OutputBytes)
+		}
+	})
+
+	t.Run("AtLimit", func(t *testing.T) {
+		code := strings.Repeat("1\n", 100) + "last line without a newline"
+		if result := evaluator(ctx, code); result.Error != nil || result.Result != code {
+			t.Errorf("expected 100 lines to pass, got: %+v", result)
+		}
+	})
+}
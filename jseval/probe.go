@@ -0,0 +1,75 @@
+package jseval
+
+import (
+	"context"
+	"time"
+)
+
+// FeatureProbe is a feature-detection snippet.
+// The feature is supported when the snippet evaluates to true.
+type FeatureProbe struct {
+	Name string
+	Code string
+}
+
+// DefaultFeatureProbes detects commonly relied-on language features.
+var DefaultFeatureProbes = []FeatureProbe{
+	{Name: "BigInt", Code: "typeof BigInt === 'function'"},
+	{Name: "Promise", Code: "typeof Promise === 'function'"},
+	{Name: "Symbol", Code: "typeof Symbol === 'function'"},
+	{Name: "Proxy", Code: "typeof Proxy === 'function'"},
+	{Name: "Intl", Code: "typeof Intl === 'object'"},
+	{Name: "optionalChaining", Code: "({ a: { b: 1 } })?.a?.b === 1"},
+	{Name: "nullishCoalescing", Code: "(null ?? true) === true"},
+	{Name: "arrowFunctions", Code: "(() => true)()"},
+	{Name: "classes", Code: "(class { m() { return true } }).prototype.m()"},
+	{Name: "templateLiterals", Code: "`${1}` === '1'"},
+}
+
+type probeKey struct{}
+
+// ContextWithProbe marks the evaluations run with it as checks of the engine
+// rather than of user code, as ProbeCapabilities runs them. Their Result is
+// the decoded JSON value even for evaluators created WithRawResult,
+// WithTextResult or WithOutputStore, and WithRequireObjectResult and
+// WithResultSchema do not apply, so the checks see the answer of the engine
+// whatever shape the options give results. A run exiting with a failure
+// code fails with ExitCode set, and one exiting successfully with output
+// which is not JSON with ExitCode and Stderr set as WithParseErrorDetails,
+// telling failures of the engine itself apart from errors of the code.
+func ContextWithProbe(ctx context.Context) context.Context {
+	return context.WithValue(ctx, probeKey{}, true)
+}
+
+func isProbe(ctx context.Context) bool {
+	probe, _ := ctx.Value(probeKey{}).(bool)
+	return probe
+}
+
+// probeConfig returns the config of evaluations run ContextWithProbe.
+func (c *config) probeConfig() *config {
+	probe := *c
+	probe.rawResult = false
+	probe.textResult = false
+	probe.outputStore = nil
+	probe.requireObject = false
+	probe.resultSchema = nil
+	probe.resolvedSchema = nil
+	probe.parseErrorDetails = true
+	probe.probe = true
+	return &probe
+}
+
+// ProbeCapabilities evaluates each probe with the given timeout and
+// reports which features are supported by the engine. The probes run
+// ContextWithProbe, so the options of the evaluator do not change the report.
+func ProbeCapabilities(ctx context.Context, evaluator Evaluator, probes []FeatureProbe, timeout time.Duration) map[string]bool {
+	capabilities := make(map[string]bool, len(probes))
+	for _, probe := range probes {
+		probeCtx, cancel := context.WithTimeout(ContextWithProbe(ctx), timeout)
+		result := evaluator(probeCtx, probe.Code)
+		cancel()
+		capabilities[probe.Name] = result.Error == nil && result.Result == true
+	}
+	return capabilities
+}
@@ -0,0 +1,57 @@
+package jseval
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+func TestProbeCapabilitiesWithResultOptions(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewTempFileStore(t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatalf("NewTempFileStore() returned an unexpected error: %v", err)
+	}
+	probes := []FeatureProbe{{Name: "answer", Code: "true"}}
+
+	for name, opts := range map[string][]Option{
+		"none":          nil,
+		"text":          {WithTextResult()},
+		"raw":           {WithRawResult()},
+		"outputStore":   {WithOutputStore(store)},
+		"requireObject": {WithRequireObjectResult()},
+		"schema":        {WithResultSchema(&jsonschema.Schema{Type: "object"})},
+	} {
+		evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm("true", -1), 1, opts...)
+		if err != nil {
+			t.Fatalf("%s: NewEvaluator() returned an unexpected error: %v", name, err)
+		}
+		capabilities := ProbeCapabilities(ctx, evaluator, probes, time.Second)
+		if !capabilities["answer"] {
+			t.Errorf("%s: the probe failed: %v", name, capabilities)
+		}
+
+		// Evaluations outside of probes keep the shape of the options.
+		if name == "requireObject" {
+			if result := evaluator(ctx, "true"); result.Error == nil || result.Error.Code != ErrorCodeResultNotObject {
+				t.Errorf("%s: expected the option to apply outside probes, got: %+v", name, result)
+			}
+		}
+		_ = cleanup()
+	}
+}
+
+func TestContextWithProbeParseErrorDetails(t *testing.T) {
+	evaluator, cleanup, err := NewEvaluator(context.Background(), writeAndExitWasm("not json", -1), 1)
+	if err != nil {
+		t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	result := evaluator(ContextWithProbe(context.Background()), "")
+	if result.Error == nil || result.Error.ExitCode == nil {
+		t.Fatalf("expected the parse error details for a probe, got: %+v", result.Error)
+	}
+}
@@ -0,0 +1,107 @@
+package jseval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Engine protocol versions, selecting how code is passed to the engine and
+// how its output is read.
+//
+//   - EngineProtocolV1, the default: the code is the whole of stdin and stdout
+//     is the JSON result. Failures are reported by exiting non-zero with the
+//     message on stderr.
+//   - EngineProtocolV2: stdin is the JSON object {"code": "..."} and stdout is
+//     the JSON object {"result": ...} on success or {"error": {"message": "..."}}
+//     when the code threw. A reported error has the code
+//     ErrorCodeEngineReported; non-zero exits are still failures as in V1.
+const (
+	EngineProtocolV1 = 1
+	EngineProtocolV2 = 2
+)
+
+// ErrorCodeEngineReported is the error code of errors an engine reports in
+// its EngineProtocolV2 output. It is negative like the other codes set by the
+// host, so that it is not mistaken for an engine exiting with status 1.
+const ErrorCodeEngineReported = -22
+
+// WithEngineProtocol selects the protocol version the engine speaks.
+// NewEvaluator fails for unknown versions.
+func WithEngineProtocol(version int) Option {
+	return func(c *config) { c.protocol = version }
+}
+
+type protocolV2Input struct {
+	Code       string `json:"code"`
+	ModuleType string `json:"moduleType,omitempty"`
+}
+
+type protocolV2Output struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func validateProtocol(version int) error {
+	switch version {
+	case EngineProtocolV1, EngineProtocolV2:
+		return nil
+	default:
+		return fmt.Errorf("unsupported engine protocol version %d", version)
+	}
+}
+
+// engineInput returns the stdin of the engine for the code. Scripts have no
+// moduleType so that their input is the same as before module types.
+func (c *config) engineInput(jsCode, moduleType string) ([]byte, error) {
+	if c.protocol == EngineProtocolV2 {
+		input := protocolV2Input{Code: jsCode}
+		if moduleType != ModuleTypeScript {
+			input.ModuleType = moduleType
+		}
+		return json.Marshal(input)
+	}
+	return []byte(jsCode), nil
+}
+
+// engineOutput returns the result part of the stdout of a successful run, or
+// the error the engine reported in it.
+func (c *config) engineOutput(output []byte) ([]byte, *ErrorDto) {
+	if c.protocol != EngineProtocolV2 {
+		return output, nil
+	}
+
+	var envelope protocolV2Output
+	if err := json.Unmarshal(output, &envelope); err != nil {
+		c.logf("Failed to parse the protocol v2 envelope from WASM stdout: %v. Raw output: %s", err, string(output))
+		return nil, &ErrorDto{Code: -1, Message: "Failed to parse successful WASM output as JSON"}
+	}
+	if envelope.Error != nil {
+		return nil, &ErrorDto{Code: ErrorCodeEngineReported, Message: envelope.Error.Message}
+	}
+	if envelope.Result == nil {
+		return []byte("null"), nil
+	}
+	return envelope.Result, nil
+}
+
+// CheckEngineProtocol evaluates a trivial expression and reports an error
+// when the result shows that the engine does not speak the protocol the
+// evaluator was created with. It runs ContextWithProbe, so options shaping
+// results such as WithRawResult do not make it fail.
+func CheckEngineProtocol(ctx context.Context, evaluator Evaluator, timeout time.Duration) error {
+	probeCtx, cancel := context.WithTimeout(ContextWithProbe(ctx), timeout)
+	defer cancel()
+
+	result := evaluator(probeCtx, "1 + 1")
+	if result.Error != nil {
+		return fmt.Errorf("protocol probe failed: %s", result.Error.Message)
+	}
+	if result.Result != 2.0 {
+		return fmt.Errorf("protocol probe returned %v instead of 2", result.Result)
+	}
+	return nil
+}
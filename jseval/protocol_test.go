@@ -0,0 +1,116 @@
+package jseval
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithEngineProtocol(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("UnknownVersion", func(t *testing.T) {
+		if _, _, err := NewEvaluator(ctx, writeAndExitWasm("1", -1), 1, WithEngineProtocol(3)); err == nil {
+			t.Fatal("expected an error for an unknown protocol version")
+		}
+	})
+
+	t.Run("Input", func(t *testing.T) {
+		stdin, err := newConfig([]Option{WithEngineProtocol(EngineProtocolV2)}).engineInput(`"a"`, ModuleTypeScript)
+		if err != nil {
+			t.Fatalf("engineInput() returned an unexpected error: %v", err)
+		}
+		if string(stdin) != `{"code":"\"a\""}` {
+			t.Errorf("unexpected engine input: %s", stdin)
+		}
+	})
+
+	tests := []struct {
+		name       string
+		stdout     string
+		wantResult interface{}
+		wantError  *ErrorDto
+	}{
+		{name: "Result", stdout: `{"result":[1]}`, wantResult: []interface{}{1.0}},
+		{name: "Error", stdout: `{"error":{"message":"ReferenceError: x"}}`, wantError: &ErrorDto{Code: ErrorCodeEngineReported, Message: "ReferenceError: x"}},
+		{name: "V1Output", stdout: `2`, wantError: &ErrorDto{Code: -1, Message: "Failed to parse successful WASM output as JSON"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(tt.stdout, -1), 1, WithEngineProtocol(EngineProtocolV2))
+			if err != nil {
+				t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+			}
+			defer func() { _ = cleanup() }()
+
+			result := evaluator(ctx, "x")
+			if tt.wantError != nil {
+				if result.Error == nil || !reflect.DeepEqual(result.Error, tt.wantError) {
+					t.Errorf("unexpected error. Got: %+v, Want: %+v", result.Error, tt.wantError)
+				}
+				return
+			}
+			if result.Error != nil {
+				t.Fatalf("unexpected error: %+v", result.Error)
+			}
+			got, _ := result.Result.([]interface{})
+			if len(got) != 1 || got[0] != 1.0 {
+				t.Errorf("unexpected result: %#v", result.Result)
+			}
+		})
+	}
+}
+
+func TestEngineReportedErrorCode(t *testing.T) {
+	ctx := context.Background()
+	codes := map[string]int{}
+	for name, wasm := range map[string][]byte{
+		"reported": writeAndExitWasm(`{"error":{"message":"boom"}}`, -1),
+		"exited":   writeAndExitWasm(`{"error":{"message":"boom"}}`, 1),
+	} {
+		evaluator, cleanup, err := NewEvaluator(ctx, wasm, 1, WithEngineProtocol(EngineProtocolV2))
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+		result := evaluator(ctx, "x")
+		_ = cleanup()
+		if result.Error == nil {
+			t.Fatalf("%s: expected an error, got: %+v", name, result)
+		}
+		codes[name] = result.Error.Code
+	}
+	if codes["reported"] != ErrorCodeEngineReported || codes["exited"] != 1 {
+		t.Errorf("codes = %v, want %d for the reported error and 1 for the exit", codes, ErrorCodeEngineReported)
+	}
+}
+
+func TestCheckEngineProtocol(t *testing.T) {
+	ctx := context.Background()
+
+	for name, tt := range map[string]struct {
+		stdout  string
+		opts    []Option
+		wantErr bool
+	}{
+		"Match":    {stdout: `{"result":2}`},
+		"Mismatch": {stdout: `2`, wantErr: true},
+		// Options shaping results do not apply to the probe.
+		"RawResult":     {stdout: `{"result":2}`, opts: []Option{WithRawResult()}},
+		"TextResult":    {stdout: `{"result":2}`, opts: []Option{WithTextResult()}},
+		"RequireObject": {stdout: `{"result":2}`, opts: []Option{WithRequireObjectResult()}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := append([]Option{WithEngineProtocol(EngineProtocolV2)}, tt.opts...)
+			evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(tt.stdout, -1), 1, opts...)
+			if err != nil {
+				t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+			}
+			defer func() { _ = cleanup() }()
+
+			if err := CheckEngineProtocol(ctx, evaluator, time.Second); (err != nil) != tt.wantErr {
+				t.Errorf("CheckEngineProtocol() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package jseval
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"io"
+	"math/rand/v2"
+	"time"
+)
+
+type seedKey struct{}
+
+// DeterministicTime is the time of every evaluation WithDeterministic.
+var DeterministicTime = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func deterministicClock() time.Time { return DeterministicTime }
+
+// WithDeterministic makes the randomness the engine reads through WASI
+// random_get reproducible: every evaluation reads the same stream, derived
+// from seed, so the same code gives the same output. Engines seed
+// Math.random and implement crypto.getRandomValues from random_get, so both
+// repeat. The random values are then predictable and must not be used for
+// anything security sensitive; without this option random_get reads
+// crypto/rand. The timestamps of WithTimestamp are DeterministicTime then,
+// unless WithClock sets another clock; the clocks the engine reads are not
+// affected.
+func WithDeterministic(seed uint64) Option {
+	return func(c *config) {
+		c.deterministic = true
+		c.seed = seed
+	}
+}
+
+// ContextWithSeed returns a context which replaces the seed of
+// WithDeterministic for the evaluations run with it, e.g. to reproduce one
+// run of a randomized test. It has no effect on other evaluators.
+func ContextWithSeed(ctx context.Context, seed uint64) context.Context {
+	return context.WithValue(ctx, seedKey{}, seed)
+}
+
+// randSource returns the random_get source of an evaluation run with ctx.
+// It is always set, as the wazero default is a fixed deterministic source.
+func (c *config) randSource(ctx context.Context) io.Reader {
+	if !c.deterministic {
+		return cryptorand.Reader
+	}
+	seed := c.seed
+	if s, ok := ctx.Value(seedKey{}).(uint64); ok {
+		seed = s
+	}
+	var key [32]byte
+	binary.LittleEndian.PutUint64(key[:], seed)
+	return rand.NewChaCha8(key)
+}
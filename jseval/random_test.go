@@ -0,0 +1,127 @@
+package jseval
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// randomWriteWasm builds a WASI command module which reads n bytes with
+// random_get, as crypto.getRandomValues does, and writes them to stdout.
+func randomWriteWasm(n int) []byte {
+	const dataOffset = 16
+
+	types := []byte{3}
+	types = append(types, 0x60, 4, 0x7f, 0x7f, 0x7f, 0x7f, 1, 0x7f) // fd_write
+	types = append(types, 0x60, 2, 0x7f, 0x7f, 1, 0x7f)             // random_get
+	types = append(types, 0x60, 0, 0)                               // _start
+
+	imports := []byte{2}
+	imports = append(imports, wasmName("wasi_snapshot_preview1")...)
+	imports = append(imports, wasmName("fd_write")...)
+	imports = append(imports, 0x00, 0)
+	imports = append(imports, wasmName("wasi_snapshot_preview1")...)
+	imports = append(imports, wasmName("random_get")...)
+	imports = append(imports, 0x00, 1)
+
+	exports := []byte{2}
+	exports = append(exports, wasmName("memory")...)
+	exports = append(exports, 0x02, 0)
+	exports = append(exports, wasmName("_start")...)
+	exports = append(exports, 0x00, 2)
+
+	// random_get(buf=dataOffset, len=n); fd_write(1, iovs=0, iovs_len=1, nwritten=8)
+	body := []byte{0}
+	body = append(body, i32Const(dataOffset)...)
+	body = append(body, i32Const(int32(n))...)
+	body = append(body, 0x10, 1, 0x1a)
+	body = append(body, 0x41, 1, 0x41, 0, 0x41, 1, 0x41, 8, 0x10, 0, 0x1a, 0x0b)
+	code := append([]byte{1}, uleb128(uint32(len(body)))...)
+	code = append(code, body...)
+
+	// iovec{buf: dataOffset, len: n}
+	segment := make([]byte, 8)
+	binary.LittleEndian.PutUint32(segment[0:], dataOffset)
+	binary.LittleEndian.PutUint32(segment[4:], uint32(n))
+	data := []byte{1, 0, 0x41, 0, 0x0b}
+	data = append(data, uleb128(uint32(len(segment)))...)
+	data = append(data, segment...)
+
+	wasm := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	wasm = append(wasm, wasmSection(1, types)...)
+	wasm = append(wasm, wasmSection(2, imports)...)
+	wasm = append(wasm, wasmSection(3, []byte{1, 2})...)
+	wasm = append(wasm, wasmSection(5, []byte{1, 0, 1})...)
+	wasm = append(wasm, wasmSection(7, exports)...)
+	wasm = append(wasm, wasmSection(10, code)...)
+	wasm = append(wasm, wasmSection(11, data)...)
+	return wasm
+}
+
+func TestWithDeterministic(t *testing.T) {
+	ctx := context.Background()
+	randomBytes := func(t *testing.T, ctx context.Context, opts ...Option) []string {
+		t.Helper()
+		opts = append(opts, WithTextResult(), WithResultEncoding(ResultEncodingBase64))
+		evaluator, cleanup, err := NewEvaluator(ctx, randomWriteWasm(32), 1, opts...)
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+		defer func() { _ = cleanup() }()
+		var outputs []string
+		for range 2 {
+			result := evaluator(ctx, "")
+			if result.Error != nil {
+				t.Fatalf("unexpected error: %+v", result.Error)
+			}
+			outputs = append(outputs, result.Result.(string))
+		}
+		return outputs
+	}
+
+	seeded := randomBytes(t, ctx, WithDeterministic(42))
+	if seeded[0] != seeded[1] {
+		t.Errorf("expected the same random bytes for the same seed, got %s and %s", seeded[0], seeded[1])
+	}
+	if again := randomBytes(t, ctx, WithDeterministic(42)); again[0] != seeded[0] {
+		t.Errorf("expected the seed to give the same bytes across evaluators, got %s and %s", again[0], seeded[0])
+	}
+	if other := randomBytes(t, ctx, WithDeterministic(43)); other[0] == seeded[0] {
+		t.Errorf("expected another seed to give other bytes, got %s for both", other[0])
+	}
+	if perCall := randomBytes(t, ContextWithSeed(ctx, 43), WithDeterministic(42)); perCall[0] == seeded[0] {
+		t.Errorf("expected ContextWithSeed to replace the seed, got %s", perCall[0])
+	}
+	if secure := randomBytes(t, ContextWithSeed(ctx, 42)); secure[0] == secure[1] {
+		t.Errorf("expected fresh random bytes without WithDeterministic, got %s twice", secure[0])
+	}
+}
+
+func TestWithDeterministicTimestamp(t *testing.T) {
+	ctx := context.Background()
+	evaluatedAt := func(opts ...Option) time.Time {
+		t.Helper()
+		evaluator, cleanup, err := NewEvaluator(ctx, writeAndExitWasm("1", -1), 1, append(opts, WithTimestamp())...)
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+		defer func() { _ = cleanup() }()
+		return evaluator(ctx, "").EvaluatedAt
+	}
+
+	first, second := evaluatedAt(WithDeterministic(1)), evaluatedAt(WithDeterministic(2))
+	if !first.Equal(DeterministicTime) || !second.Equal(first) {
+		t.Errorf("expected deterministic runs at %v, got %v and %v", DeterministicTime, first, second)
+	}
+
+	fixed := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	for _, opts := range [][]Option{
+		{WithDeterministic(1), WithClock(func() time.Time { return fixed })},
+		{WithClock(func() time.Time { return fixed }), WithDeterministic(1)},
+	} {
+		if got := evaluatedAt(opts...); !got.Equal(fixed) {
+			t.Errorf("expected WithClock to take precedence, got %v", got)
+		}
+	}
+}
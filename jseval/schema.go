@@ -0,0 +1,51 @@
+package jseval
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// ErrorCodeResultSchemaViolation is the ErrorDto code of a result not
+// matching the schema set WithResultSchema.
+const ErrorCodeResultSchemaViolation = -12
+
+// WithResultSchema validates the result of every successful evaluation
+// against schema. A result not matching it is reported as an error with
+// ErrorCodeResultSchemaViolation naming the violation, e.g. a missing
+// property, so callers can rely on the shape of what scripts return.
+// Outputs kept WithOutputStore are not validated. NewEvaluator fails when
+// the schema is invalid.
+func WithResultSchema(schema *jsonschema.Schema) Option {
+	return func(c *config) { c.resultSchema = schema }
+}
+
+// resolveResultSchema prepares the schema set WithResultSchema for validation.
+func (c *config) resolveResultSchema() error {
+	if c.resultSchema == nil {
+		return nil
+	}
+	resolved, err := c.resultSchema.Resolve(nil)
+	if err != nil {
+		return fmt.Errorf("invalid result schema: %w", err)
+	}
+	c.resolvedSchema = resolved
+	return nil
+}
+
+// checkResultSchema returns the error of a result violating the result schema.
+func (c *config) checkResultSchema(result any) *ErrorDto {
+	if c.resolvedSchema == nil {
+		return nil
+	}
+	if raw, ok := result.(json.RawMessage); ok {
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return &ErrorDto{Code: -1, Message: fmt.Sprintf("failed to decode the result for schema validation: %v", err)}
+		}
+	}
+	if err := c.resolvedSchema.Validate(result); err != nil {
+		return &ErrorDto{Code: ErrorCodeResultSchemaViolation, Message: fmt.Sprintf("result schema violation: %v", err)}
+	}
+	return nil
+}
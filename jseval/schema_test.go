@@ -0,0 +1,60 @@
+package jseval
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+func TestWithResultSchema(t *testing.T) {
+	schema := &jsonschema.Schema{
+		Type:     "object",
+		Required: []string{"name", "count"},
+		Properties: map[string]*jsonschema.Schema{
+			"name":  {Type: "string"},
+			"count": {Type: "integer"},
+		},
+	}
+
+	for _, tc := range []struct {
+		name   string
+		output string
+		opts   []Option
+		valid  bool
+	}{
+		{"Matching", `{"name":"a","count":2}`, nil, true},
+		{"MatchingRaw", `{"name":"a","count":2}`, []Option{WithRawResult()}, true},
+		{"MissingField", `{"name":"a"}`, nil, false},
+		{"WrongType", `{"name":"a","count":"2"}`, nil, false},
+		{"NotAnObject", `[1]`, nil, false},
+		{"WrongTypeRaw", `{"name":1,"count":2}`, []Option{WithRawResult()}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			evaluator := newFixtureEvaluator(t, append(tc.opts, WithResultSchema(schema))...)
+			result := evaluator(t.Context(), tc.output)
+			if tc.valid {
+				if result.Error != nil {
+					t.Fatalf("expected %s to match, got %+v", tc.output, result.Error)
+				}
+				return
+			}
+			if result.Error == nil || result.Error.Code != ErrorCodeResultSchemaViolation {
+				t.Fatalf("expected a schema violation for %s, got %+v", tc.output, result)
+			}
+			if !strings.HasPrefix(result.Error.Message, "result schema violation: ") {
+				t.Errorf("unexpected message: %s", result.Error.Message)
+			}
+			if result.Result != nil {
+				t.Errorf("expected no result with a violation, got %v", result.Result)
+			}
+		})
+	}
+}
+
+func TestWithResultSchemaInvalid(t *testing.T) {
+	invalid := &jsonschema.Schema{Type: "object", Types: []string{"array"}}
+	if _, _, err := NewEvaluator(t.Context(), writeAndExitWasm(`1`, -1), 1, WithResultSchema(invalid)); err == nil {
+		t.Fatal("expected NewEvaluator to reject an invalid schema")
+	}
+}
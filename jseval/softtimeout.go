@@ -0,0 +1,39 @@
+package jseval
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WithSoftTimeout lets evaluations run for grace beyond the deadline of their
+// context before the module is closed, so code finishing just after the
+// deadline still returns its result, marked SoftTimeout. Cancellation for any
+// other reason still closes the module at once.
+//
+// Every evaluation hitting its deadline holds its memory and a CPU core for
+// up to grace longer, and a runaway script always runs for the full grace, so
+// size grace to the overrun which is acceptable, not to the longest script.
+func WithSoftTimeout(grace time.Duration) Option {
+	return func(c *config) { c.softTimeoutGrace = grace }
+}
+
+// withSoftTimeout returns a context which ends grace after the deadline of
+// ctx, or with ctx when it is canceled otherwise, along with that deadline.
+// Without a deadline ctx is returned as is.
+func (c *config) withSoftTimeout(ctx context.Context) (context.Context, time.Time, func()) {
+	deadline, ok := ctx.Deadline()
+	if !ok || c.softTimeoutGrace <= 0 {
+		return ctx, time.Time{}, func() {}
+	}
+	soft, cancel := context.WithDeadline(context.WithoutCancel(ctx), deadline.Add(c.softTimeoutGrace))
+	stop := context.AfterFunc(ctx, func() {
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			cancel()
+		}
+	})
+	return soft, deadline, func() {
+		stop()
+		cancel()
+	}
+}
@@ -0,0 +1,161 @@
+package jseval
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// sleepThenWriteWasm builds a WASI command module which sleeps with
+// poll_oneoff and then writes output to stdout. Unlike a busy loop the sleep
+// is not interrupted by the context; the module is closed once it wakes up.
+func sleepThenWriteWasm(sleep time.Duration, output string) []byte {
+	const (
+		dataOffset         = 16
+		subscriptionOffset = 256
+		eventOffset        = 320
+		neventsOffset      = 360
+	)
+
+	types := []byte{2}
+	types = append(types, 0x60, 4, 0x7f, 0x7f, 0x7f, 0x7f, 1, 0x7f) // fd_write, poll_oneoff
+	types = append(types, 0x60, 0, 0)                               // _start
+
+	imports := []byte{2}
+	for _, name := range []string{"fd_write", "poll_oneoff"} {
+		imports = append(imports, wasmName("wasi_snapshot_preview1")...)
+		imports = append(imports, wasmName(name)...)
+		imports = append(imports, 0x00, 0)
+	}
+
+	exports := []byte{2}
+	exports = append(exports, wasmName("memory")...)
+	exports = append(exports, 0x02, 0)
+	exports = append(exports, wasmName("_start")...)
+	exports = append(exports, 0x00, 2)
+
+	body := []byte{0}
+	body = append(body, i32Const(subscriptionOffset)...)
+	body = append(body, i32Const(eventOffset)...)
+	body = append(body, i32Const(1)...)
+	body = append(body, i32Const(neventsOffset)...)
+	body = append(body, 0x10, 1, 0x1a) // poll_oneoff, drop
+	body = append(body, i32Const(1)...)
+	body = append(body, i32Const(0)...)
+	body = append(body, i32Const(1)...)
+	body = append(body, i32Const(8)...)
+	body = append(body, 0x10, 0, 0x1a, 0x0b) // fd_write, drop, end
+	code := append([]byte{1}, uleb128(uint32(len(body)))...)
+	code = append(code, body...)
+
+	// iovec{buf: dataOffset, len: len(output)} followed by the payload.
+	segment := make([]byte, dataOffset, dataOffset+len(output))
+	binary.LittleEndian.PutUint32(segment[0:], dataOffset)
+	binary.LittleEndian.PutUint32(segment[4:], uint32(len(output)))
+	segment = append(segment, output...)
+	// A relative monotonic clock subscription of sleep.
+	subscription := make([]byte, 48)
+	binary.LittleEndian.PutUint32(subscription[16:], 1)
+	binary.LittleEndian.PutUint64(subscription[24:], uint64(sleep.Nanoseconds()))
+
+	data := []byte{2}
+	for _, s := range []struct {
+		offset  int32
+		payload []byte
+	}{{0, segment}, {subscriptionOffset, subscription}} {
+		data = append(data, 0)
+		data = append(data, i32Const(s.offset)...)
+		data = append(data, 0x0b)
+		data = append(data, uleb128(uint32(len(s.payload)))...)
+		data = append(data, s.payload...)
+	}
+
+	wasm := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	wasm = append(wasm, wasmSection(1, types)...)
+	wasm = append(wasm, wasmSection(2, imports)...)
+	wasm = append(wasm, wasmSection(3, []byte{1, 1})...)
+	wasm = append(wasm, wasmSection(5, []byte{1, 0, 1})...)
+	wasm = append(wasm, wasmSection(7, exports)...)
+	wasm = append(wasm, wasmSection(10, code)...)
+	wasm = append(wasm, wasmSection(11, data)...)
+	return wasm
+}
+
+func TestWithSoftTimeout(t *testing.T) {
+	ctx := context.Background()
+	newEvaluator := func(t *testing.T, wasm []byte, opts ...Option) Evaluator {
+		t.Helper()
+		evaluator, cleanup, err := NewEvaluator(ctx, wasm, 1, opts...)
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+		t.Cleanup(func() { _ = cleanup() })
+		return evaluator
+	}
+	sleeper := sleepThenWriteWasm(60*time.Millisecond, "true")
+
+	t.Run("FinishesWithinGrace", func(t *testing.T) {
+		evaluator := newEvaluator(t, sleeper, WithSoftTimeout(time.Second))
+		timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		result := evaluator(timeoutCtx, "")
+		if result.Error != nil || result.Result != true {
+			t.Fatalf("expected the result despite the timeout, got: %+v", result)
+		}
+		if !result.SoftTimeout {
+			t.Error("expected SoftTimeout to be set")
+		}
+	})
+
+	t.Run("FinishesInTime", func(t *testing.T) {
+		evaluator := newEvaluator(t, sleeper, WithSoftTimeout(time.Second))
+		timeoutCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		if result := evaluator(timeoutCtx, ""); result.Error != nil || result.SoftTimeout {
+			t.Errorf("expected a plain result, got: %+v", result)
+		}
+	})
+
+	t.Run("KilledWithoutGrace", func(t *testing.T) {
+		evaluator := newEvaluator(t, sleeper)
+		timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		if result := evaluator(timeoutCtx, ""); result.Error == nil || !result.Error.Terminated {
+			t.Errorf("expected the timeout to terminate the evaluation, got: %+v", result)
+		}
+	})
+
+	t.Run("RunawayKilledAfterGrace", func(t *testing.T) {
+		evaluator := newEvaluator(t, busyLoopWasm(), WithSoftTimeout(50*time.Millisecond))
+		timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		startedAt := time.Now()
+		result := evaluator(timeoutCtx, "")
+		if result.Error == nil || result.Error.Code != int(sys.ExitCodeDeadlineExceeded) || result.SoftTimeout {
+			t.Fatalf("expected the deadline to terminate the evaluation, got: %+v", result)
+		}
+		if elapsed := time.Since(startedAt); elapsed < 70*time.Millisecond {
+			t.Errorf("terminated after %v, before the grace ended", elapsed)
+		}
+		if grace := result.Error.Details["grace_ms"]; grace != int64(50) {
+			t.Errorf("grace_ms = %v, want 50", grace)
+		}
+	})
+
+	t.Run("CancelIsImmediate", func(t *testing.T) {
+		evaluator := newEvaluator(t, busyLoopWasm(), WithSoftTimeout(10*time.Second))
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		time.AfterFunc(20*time.Millisecond, cancel)
+		startedAt := time.Now()
+		result := evaluator(timeoutCtx, "")
+		if result.Error == nil || result.Error.Code != int(sys.ExitCodeContextCanceled) {
+			t.Fatalf("expected the cancellation to terminate the evaluation, got: %+v", result)
+		}
+		if elapsed := time.Since(startedAt); elapsed > 5*time.Second {
+			t.Errorf("cancellation took %v", elapsed)
+		}
+	})
+}
@@ -0,0 +1,29 @@
+package jseval
+
+import "regexp"
+
+// ErrorCodeStackOverflow is the ErrorDto code of an evaluation which ran out
+// of call stack, reported WithStackOverflowCode.
+const ErrorCodeStackOverflow = -14
+
+// stackOverflow matches the stack exhaustion errors of common engines:
+// "Maximum call stack size exceeded" (V8 style, also QuickJS and boa),
+// "too much recursion" (SpiderMonkey style) and "stack overflow".
+var stackOverflow = regexp.MustCompile(`(?i)maximum call stack size exceeded|too much recursion|stack overflow`)
+
+// WithStackOverflowCode reports failures whose message is a stack overflow,
+// from the engine or from the wazero call stack itself, with
+// ErrorCodeStackOverflow instead of the exit code, which is kept in ExitCode.
+// It pairs with a stack depth limit passed to the engine WithArgs.
+func WithStackOverflowCode() Option {
+	return func(c *config) { c.stackOverflowCode = true }
+}
+
+// markStackOverflow recodes failure when it is a stack overflow.
+func (c *config) markStackOverflow(failure *ErrorDto, exitCode *uint32) {
+	if !c.stackOverflowCode || !stackOverflow.MatchString(failure.Message) {
+		return
+	}
+	failure.ExitCode = exitCode
+	failure.Code = ErrorCodeStackOverflow
+}
@@ -0,0 +1,65 @@
+package jseval
+
+import (
+	"testing"
+)
+
+// recursionWasm is a module whose _start calls itself forever.
+func recursionWasm() []byte {
+	wasm := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	wasm = append(wasm, wasmSection(1, []byte{1, 0x60, 0, 0})...)
+	wasm = append(wasm, wasmSection(3, []byte{1, 0})...)
+	wasm = append(wasm, wasmSection(7, append(append([]byte{1}, wasmName("_start")...), 0x00, 0))...)
+	body := []byte{0, 0x10, 0x00, 0x0b} // call 0 end
+	wasm = append(wasm, wasmSection(10, append([]byte{1, byte(len(body))}, body...))...)
+	return wasm
+}
+
+func TestWithStackOverflowCode(t *testing.T) {
+	ctx := t.Context()
+	one := uint32(1)
+	for _, tc := range []struct {
+		name     string
+		wasm     []byte
+		exitCode *uint32
+	}{
+		{"Engine", writeFdAndExitWasm(2, "RangeError: Maximum call stack size exceeded\n", 1), &one},
+		{"Runtime", recursionWasm(), nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			plain, cleanupPlain, err := NewEvaluator(ctx, tc.wasm, 1)
+			if err != nil {
+				t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+			}
+			defer func() { _ = cleanupPlain() }()
+			if result := plain(ctx, ""); result.Error == nil || result.Error.Code == ErrorCodeStackOverflow {
+				t.Fatalf("expected the plain error code by default, got %+v", result.Error)
+			}
+
+			evaluator, cleanup, err := NewEvaluator(ctx, tc.wasm, 1, WithStackOverflowCode())
+			if err != nil {
+				t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+			}
+			defer func() { _ = cleanup() }()
+			result := evaluator(ctx, "")
+			if result.Error == nil || result.Error.Code != ErrorCodeStackOverflow {
+				t.Fatalf("expected a stack overflow, got %+v", result.Error)
+			}
+			if (tc.exitCode == nil) != (result.Error.ExitCode == nil) ||
+				(tc.exitCode != nil && *tc.exitCode != *result.Error.ExitCode) {
+				t.Errorf("unexpected exit code: %v", result.Error.ExitCode)
+			}
+		})
+	}
+
+	t.Run("OtherErrorsKept", func(t *testing.T) {
+		evaluator, cleanup, err := NewEvaluator(ctx, writeFdAndExitWasm(2, "TypeError: x is undefined", 1), 1, WithStackOverflowCode())
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+		defer func() { _ = cleanup() }()
+		if result := evaluator(ctx, ""); result.Error == nil || result.Error.Code != 1 {
+			t.Errorf("expected the exit code for other errors, got %+v", result.Error)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package jseval
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// ModuleSurface is what the compiled engine imports from and exports to the host.
+type ModuleSurface struct {
+	Imports []ModuleItem `json:"imports"`
+	Exports []ModuleItem `json:"exports"`
+}
+
+// ModuleItem is an import or export of the engine module.
+type ModuleItem struct {
+	// Module is the module an import is taken from; empty for exports.
+	Module string `json:"module,omitempty"`
+	Name   string `json:"name"`
+
+	// Kind is "function" or "memory".
+	Kind string `json:"kind"`
+
+	// Params and Results are the value types of a function, e.g. "i32".
+	Params  []string `json:"params,omitempty"`
+	Results []string `json:"results,omitempty"`
+}
+
+// WithOnModuleSurface calls f with the functions and memories the engine
+// imports and exports, sorted by module and name, once NewEvaluator compiled
+// the module. It shows why an engine does not run, e.g. a missing _start
+// export or imports of host modules other than WASI preview1.
+func WithOnModuleSurface(f func(ModuleSurface)) Option {
+	return func(c *config) { c.onModuleSurface = f }
+}
+
+// moduleSurface lists the imports and exports of compiled.
+func moduleSurface(compiled wazero.CompiledModule) ModuleSurface {
+	surface := ModuleSurface{Imports: []ModuleItem{}, Exports: []ModuleItem{}}
+	for _, def := range compiled.ImportedFunctions() {
+		module, name, _ := def.Import()
+		surface.Imports = append(surface.Imports, functionItem(module, name, def))
+	}
+	for _, def := range compiled.ImportedMemories() {
+		module, name, _ := def.Import()
+		surface.Imports = append(surface.Imports, ModuleItem{Module: module, Name: name, Kind: "memory"})
+	}
+	for name, def := range compiled.ExportedFunctions() {
+		surface.Exports = append(surface.Exports, functionItem("", name, def))
+	}
+	for name := range compiled.ExportedMemories() {
+		surface.Exports = append(surface.Exports, ModuleItem{Name: name, Kind: "memory"})
+	}
+	byName := func(a, b ModuleItem) int {
+		return cmp.Or(cmp.Compare(a.Module, b.Module), cmp.Compare(a.Name, b.Name))
+	}
+	slices.SortFunc(surface.Imports, byName)
+	slices.SortFunc(surface.Exports, byName)
+	return surface
+}
+
+func functionItem(module, name string, def api.FunctionDefinition) ModuleItem {
+	item := ModuleItem{Module: module, Name: name, Kind: "function"}
+	for _, t := range def.ParamTypes() {
+		item.Params = append(item.Params, api.ValueTypeName(t))
+	}
+	for _, t := range def.ResultTypes() {
+		item.Results = append(item.Results, api.ValueTypeName(t))
+	}
+	return item
+}
@@ -0,0 +1,29 @@
+package jseval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWithOnModuleSurface(t *testing.T) {
+	var surface ModuleSurface
+	newFixtureEvaluator(t, WithOnModuleSurface(func(s ModuleSurface) { surface = s }))
+
+	fdIO := []string{"i32", "i32", "i32", "i32"}
+	want := ModuleSurface{
+		Imports: []ModuleItem{
+			{Module: "wasi_snapshot_preview1", Name: "environ_get", Kind: "function", Params: []string{"i32", "i32"}, Results: []string{"i32"}},
+			{Module: "wasi_snapshot_preview1", Name: "environ_sizes_get", Kind: "function", Params: []string{"i32", "i32"}, Results: []string{"i32"}},
+			{Module: "wasi_snapshot_preview1", Name: "fd_read", Kind: "function", Params: fdIO, Results: []string{"i32"}},
+			{Module: "wasi_snapshot_preview1", Name: "fd_write", Kind: "function", Params: fdIO, Results: []string{"i32"}},
+			{Module: "wasi_snapshot_preview1", Name: "proc_exit", Kind: "function", Params: []string{"i32"}},
+		},
+		Exports: []ModuleItem{
+			{Name: "_start", Kind: "function"},
+			{Name: "memory", Kind: "memory"},
+		},
+	}
+	if !reflect.DeepEqual(surface, want) {
+		t.Errorf("surface = %+v, want %+v", surface, want)
+	}
+}
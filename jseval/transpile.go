@@ -0,0 +1,93 @@
+package jseval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// ErrorCodeTranspileFailed is the ErrorDto code of source which could not be
+// transpiled to JavaScript, as opposed to JavaScript failing at runtime.
+const ErrorCodeTranspileFailed = -5
+
+// Languages accepted in JsEvalToolInput.Language.
+const (
+	LanguageJavaScript = "js"
+	LanguageTypeScript = "ts"
+)
+
+// Transpiler converts source code of another language into JavaScript.
+type Transpiler func(ctx context.Context, source string) (string, error)
+
+// NewWasmTranspiler returns a Transpiler running a WASI command, such as a
+// TypeScript compiler built for wasm32-wasip1, which reads the source on stdin
+// and writes the JavaScript to stdout. A non-zero exit is a transpile error
+// with stderr as its message.
+func NewWasmTranspiler(ctx context.Context, wasmBinary []byte, memoryLimitPages uint32) (Transpiler, func() error, error) {
+	rConfig := wazero.NewRuntimeConfig().WithCloseOnContextDone(true).WithMemoryLimitPages(memoryLimitPages)
+	r := wazero.NewRuntimeWithConfig(ctx, rConfig)
+	cleanup := func() error { return r.Close(context.Background()) }
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+		_ = cleanup()
+		return nil, nil, fmt.Errorf("failed to instantiate wasi_snapshot_preview1: %w", err)
+	}
+
+	compiled, err := r.CompileModule(ctx, wasmBinary)
+	if err != nil {
+		_ = cleanup()
+		return nil, nil, fmt.Errorf("failed to compile transpiler WASM module: %w", err)
+	}
+
+	transpile := func(tCtx context.Context, source string) (string, error) {
+		var stdoutBuf, stderrBuf bytes.Buffer
+		moduleConfig := wazero.NewModuleConfig().
+			WithStdin(strings.NewReader(source)).
+			WithStdout(&stdoutBuf).
+			WithStderr(&stderrBuf)
+
+		instance, e := r.InstantiateModule(tCtx, compiled, moduleConfig)
+		if instance != nil {
+			defer func() { _ = instance.Close(tCtx) }()
+		}
+
+		if e != nil {
+			var exitErr *sys.ExitError
+			if errors.As(e, &exitErr) {
+				return "", fmt.Errorf("transpiler exited with code %d: %s", exitErr.ExitCode(), stripANSI(stderrBuf.String()))
+			}
+			return "", fmt.Errorf("transpiler failed: %w", e)
+		}
+		return stdoutBuf.String(), nil
+	}
+
+	return transpile, cleanup, nil
+}
+
+// TranspileInput returns the JavaScript for the input, transpiling it first
+// when its language requires it. A nil ErrorDto means the code is ready to run.
+func TranspileInput(ctx context.Context, input JsEvalToolInput, transpilers map[string]Transpiler) (string, *ErrorDto) {
+	if input.Language == "" || input.Language == LanguageJavaScript {
+		return input.Code, nil
+	}
+
+	transpile, ok := transpilers[input.Language]
+	if !ok {
+		return "", &ErrorDto{
+			Code:    ErrorCodeTranspileFailed,
+			Message: fmt.Sprintf("no transpiler is configured for language %q", input.Language),
+			Details: map[string]interface{}{"language": input.Language},
+		}
+	}
+	js, err := transpile(ctx, input.Code)
+	if err != nil {
+		return "", &ErrorDto{Code: ErrorCodeTranspileFailed, Message: err.Error()}
+	}
+	return js, nil
+}
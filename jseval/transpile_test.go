@@ -0,0 +1,76 @@
+package jseval
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTranspileInput(t *testing.T) {
+	ctx := context.Background()
+	transpilers := map[string]Transpiler{
+		LanguageTypeScript: func(_ context.Context, source string) (string, error) {
+			if source == "let x: = 1" {
+				return "", errors.New("syntax error")
+			}
+			return "let x = 1", nil
+		},
+	}
+
+	tests := []struct {
+		name     string
+		input    JsEvalToolInput
+		wantCode string
+		wantErr  bool
+	}{
+		{name: "DefaultIsJavaScript", input: JsEvalToolInput{Code: "1"}, wantCode: "1"},
+		{name: "ExplicitJavaScript", input: JsEvalToolInput{Code: "1", Language: "js"}, wantCode: "1"},
+		{name: "TypeScriptIsTranspiled", input: JsEvalToolInput{Code: "let x: number = 1", Language: "ts"}, wantCode: "let x = 1"},
+		{name: "TranspileError", input: JsEvalToolInput{Code: "let x: = 1", Language: "ts"}, wantErr: true},
+		{name: "NoTranspiler", input: JsEvalToolInput{Code: "x", Language: "coffee"}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			code, errDto := TranspileInput(ctx, tc.input, transpilers)
+			if tc.wantErr {
+				if errDto == nil || errDto.Code != ErrorCodeTranspileFailed {
+					t.Fatalf("expected a transpile error, got: %+v", errDto)
+				}
+				return
+			}
+			if errDto != nil {
+				t.Fatalf("unexpected error: %+v", errDto)
+			}
+			if code != tc.wantCode {
+				t.Errorf("unexpected code. Got: %q, Want: %q", code, tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestNewWasmTranspiler(t *testing.T) {
+	ctx := context.Background()
+	transpile, cleanup, err := NewWasmTranspiler(ctx, writeAndExitWasm("var x = 1;", -1), 1)
+	if err != nil {
+		t.Fatalf("NewWasmTranspiler() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanup() }()
+
+	js, err := transpile(ctx, "var x: number = 1;")
+	if err != nil {
+		t.Fatalf("transpile() returned an unexpected error: %v", err)
+	}
+	if js != "var x = 1;" {
+		t.Errorf("unexpected output: %q", js)
+	}
+
+	failing, cleanupFailing, err := NewWasmTranspiler(ctx, writeAndExitWasm("", 1), 1)
+	if err != nil {
+		t.Fatalf("NewWasmTranspiler() returned an unexpected error: %v", err)
+	}
+	defer func() { _ = cleanupFailing() }()
+	if _, err := failing(ctx, "broken"); err == nil {
+		t.Error("transpile() was expected to fail on a non-zero exit")
+	}
+}
@@ -0,0 +1,55 @@
+package jseval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// EvaluationError is the error of a failed evaluation returned by
+// EvaluateTyped, carrying the ErrorDto of the result.
+type EvaluationError struct {
+	ErrorDto
+}
+
+func (e *EvaluationError) Error() string {
+	return fmt.Sprintf("evaluation failed with code %d: %s", e.Code, e.Message)
+}
+
+// ResultTypeError reports a successful result which does not unmarshal into
+// the type EvaluateTyped was asked for.
+type ResultTypeError struct {
+	Type reflect.Type
+	Err  error
+}
+
+func (e *ResultTypeError) Error() string {
+	return fmt.Sprintf("result does not fit %v: %v", e.Type, e.Err)
+}
+
+func (e *ResultTypeError) Unwrap() error { return e.Err }
+
+// EvaluateTyped evaluates code and unmarshals the result into a T, as
+// encoding/json would unmarshal the result JSON. A failed evaluation is an
+// *EvaluationError and a result not fitting T a *ResultTypeError. Evaluators
+// created WithRawResult skip building the intermediate value tree.
+func EvaluateTyped[T any](ctx context.Context, evaluate Evaluator, code string) (T, error) {
+	var typed T
+	result := evaluate(ctx, code)
+	if result.Error != nil {
+		return typed, &EvaluationError{ErrorDto: *result.Error}
+	}
+
+	encoded, ok := result.Result.(json.RawMessage)
+	if !ok {
+		var err error
+		if encoded, err = json.Marshal(result.Result); err != nil {
+			return typed, &ResultTypeError{Type: reflect.TypeFor[T](), Err: err}
+		}
+	}
+	if err := json.Unmarshal(encoded, &typed); err != nil {
+		return typed, &ResultTypeError{Type: reflect.TypeFor[T](), Err: err}
+	}
+	return typed, nil
+}
@@ -0,0 +1,71 @@
+package jseval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func ExampleEvaluateTyped() {
+	ctx := context.Background()
+	// The stand-in engine prints this result for any code.
+	evaluate, cleanup, err := NewEvaluator(ctx, writeAndExitWasm(`{"name":"widget","tags":["a","b"],"price":9.5}`, -1), 1)
+	if err != nil {
+		panic(err)
+	}
+	defer func() { _ = cleanup() }()
+
+	type product struct {
+		Name  string   `json:"name"`
+		Tags  []string `json:"tags"`
+		Price float64  `json:"price"`
+	}
+	p, err := EvaluateTyped[product](ctx, evaluate, `({name: "widget", tags: ["a", "b"], price: 9.5})`)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s %v %.2f\n", p.Name, p.Tags, p.Price)
+	// Output: widget [a b] 9.50
+}
+
+func TestEvaluateTyped(t *testing.T) {
+	ctx := context.Background()
+	newEvaluator := func(t *testing.T, wasm []byte, opts ...Option) Evaluator {
+		t.Helper()
+		evaluator, cleanup, err := NewEvaluator(ctx, wasm, 1, opts...)
+		if err != nil {
+			t.Fatalf("NewEvaluator() returned an unexpected error: %v", err)
+		}
+		t.Cleanup(func() { _ = cleanup() })
+		return evaluator
+	}
+
+	t.Run("Raw", func(t *testing.T) {
+		got, err := EvaluateTyped[[]int](ctx, newEvaluator(t, writeAndExitWasm("[1,2,3]", -1), WithRawResult()), "")
+		if err != nil || len(got) != 3 || got[2] != 3 {
+			t.Errorf("got %v, %v", got, err)
+		}
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		_, err := EvaluateTyped[int](ctx, newEvaluator(t, writeAndExitWasm(`"text"`, -1)), "")
+		var typeErr *ResultTypeError
+		if !errors.As(err, &typeErr) || typeErr.Type.String() != "int" {
+			t.Fatalf("expected a ResultTypeError for int, got: %v", err)
+		}
+		var jsonErr *json.UnmarshalTypeError
+		if !errors.As(err, &jsonErr) {
+			t.Errorf("expected the json error to be unwrapped, got: %v", err)
+		}
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		_, err := EvaluateTyped[int](ctx, newEvaluator(t, writeFdAndExitWasm(2, "Uncaught boom", 1)), "")
+		var evalErr *EvaluationError
+		if !errors.As(err, &evalErr) || evalErr.Code != 1 || evalErr.Message != "Uncaught boom" {
+			t.Errorf("expected an EvaluationError with code 1, got: %v", err)
+		}
+	})
+}
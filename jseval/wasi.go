@@ -0,0 +1,41 @@
+package jseval
+
+import (
+	"bytes"
+	"errors"
+)
+
+// WASI versions an engine can be built against.
+const (
+	WASIPreview1 = "preview1"
+	WASIPreview2 = "preview2"
+)
+
+// ErrWASIPreview2Unsupported means the binary is a component, as WASI preview2
+// engines are, which wazero can not run. Engines have to be built for
+// wasi_snapshot_preview1 until it supports the component model.
+var ErrWASIPreview2Unsupported = errors.New("WASI preview2 components are not supported, build the engine for preview1")
+
+// The 4 bytes after the magic number: the version of core modules, and the
+// version and layer of components.
+var (
+	coreModuleVersion = []byte{0x01, 0x00, 0x00, 0x00}
+	componentLayer    = []byte{0x0d, 0x00, 0x01, 0x00}
+)
+
+// DetectWASIVersion tells from its header whether the binary is a core module,
+// importing wasi_snapshot_preview1, or a component as used by preview2. It
+// returns "" for binaries which are neither.
+func DetectWASIVersion(wasm []byte) string {
+	if !bytes.HasPrefix(wasm, wasmMagic) || len(wasm) < len(wasmMagic)+4 {
+		return ""
+	}
+	switch header := wasm[len(wasmMagic) : len(wasmMagic)+4]; {
+	case bytes.Equal(header, coreModuleVersion):
+		return WASIPreview1
+	case bytes.Equal(header, componentLayer):
+		return WASIPreview2
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,31 @@
+package jseval
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDetectWASIVersion(t *testing.T) {
+	component := []byte{0x00, 0x61, 0x73, 0x6d, 0x0d, 0x00, 0x01, 0x00}
+
+	for name, tt := range map[string]struct {
+		wasm []byte
+		want string
+	}{
+		"CoreModule": {wasm: writeAndExitWasm("1", -1), want: WASIPreview1},
+		"Component":  {wasm: component, want: WASIPreview2},
+		"NotWasm":    {wasm: []byte("(module)"), want: ""},
+		"Truncated":  {wasm: []byte{0x00, 0x61, 0x73, 0x6d}, want: ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := DetectWASIVersion(tt.wasm); got != tt.want {
+				t.Errorf("DetectWASIVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := NewEvaluator(context.Background(), component, 1); !errors.Is(err, ErrWASIPreview2Unsupported) {
+		t.Errorf("expected NewEvaluator() to reject the component, got: %v", err)
+	}
+}